@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/backup"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+
+	"github.com/spf13/cobra"
+)
+
+var backupDir *string
+var backupImages *bool
+var backupKeep *int
+
+// backupCmd 备份数据库
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "备份数据库，可选包含图片目录",
+	Long: `使用VACUUM INTO对数据库做一致的在线快照，与manifest一起打包为tar.gz文件，服务运行时也可以执行。
+备份完成后按保留数量删除旧的备份。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config.InitDB()
+
+		// 未指定的选项使用配置文件中的值
+		opts := backup.Options{
+			Dir:           config.GetBackupDir(),
+			IncludeImages: config.AppConfig.Backup.IncludeImages,
+			ImageDir:      config.GetImageDir(),
+			Keep:          config.GetBackupKeep(),
+		}
+		if cmd.Flags().Changed("dir") {
+			opts.Dir = *backupDir
+		}
+		if cmd.Flags().Changed("images") {
+			opts.IncludeImages = *backupImages
+		}
+		if cmd.Flags().Changed("keep") {
+			opts.Keep = *backupKeep
+		}
+
+		path, err := backup.Create(config.DB, opts)
+		if err != nil {
+			logging.Fatal("备份失败", "error", err)
+		}
+		slog.Info("备份完成", "path", path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+
+	backupDir = backupCmd.Flags().StringP("dir", "d", "", "备份目录，默认为配置中的backup.dir")
+	backupImages = backupCmd.Flags().Bool("images", false, "包含图片目录，默认为配置中的backup.include_images")
+	backupKeep = backupCmd.Flags().Int("keep", 0, "保留最近的备份数量，0为不清理，默认为配置中的backup.keep")
+}
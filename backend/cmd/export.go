@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bufio"
+	"log/slog"
+	"os"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/catalog"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+
+	"github.com/spf13/cobra"
+)
+
+var exportFormat *string
+var exportOutput *string
+
+// exportCmd 导出电影、人物、类型或合集
+var exportCmd = &cobra.Command{
+	Use:   "export <movies|people|genres|collections>",
+	Short: "导出电影、人物、类型或合集数据",
+	Long: `以JSON、NDJSON或CSV格式导出数据，电影包括类型、演职人员和图片，回收站中的数据不导出。
+未指定输出文件时写到标准输出，未指定格式时根据输出文件的扩展名判断，默认为JSON。`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := *exportFormat
+		if format == "" {
+			format = catalog.FormatFromPath(*exportOutput)
+		}
+		if format == "" {
+			format = catalog.FormatJSON
+		}
+		if err := catalog.Valid(args[0], format); err != nil {
+			logging.Fatal("导出参数错误", "error", err)
+		}
+
+		out := os.Stdout
+		if *exportOutput != "" {
+			file, err := os.Create(*exportOutput)
+			if err != nil {
+				logging.Fatal("创建文件失败", "path", *exportOutput, "error", err)
+			}
+			defer file.Close()
+			out = file
+		}
+
+		config.InitDB()
+
+		w := bufio.NewWriter(out)
+		count, err := catalog.Export(config.DB, w, args[0], format)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			logging.Fatal("导出失败", "entity", args[0], "error", err)
+		}
+		slog.Info("导出完成", "entity", args[0], "count", count)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportFormat = exportCmd.Flags().StringP("format", "f", "", "导出格式: json、ndjson、csv")
+	exportOutput = exportCmd.Flags().StringP("output", "o", "", "输出文件，默认为标准输出")
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/catalog"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+
+	"github.com/spf13/cobra"
+)
+
+var importFormat *string
+var importInput *string
+var importMode *string
+var importDryRun *bool
+
+// importCmd 导入电影、人物、类型或合集
+var importCmd = &cobra.Command{
+	Use:   "import <movies|people|genres|collections>",
+	Short: "导入电影、人物、类型或合集数据",
+	Long: `从JSON、NDJSON或CSV导入数据，已有的数据按ID或TMDB ID更新，否则创建，完成后输出导入报告。
+未指定输入文件时从标准输入读取，未指定格式时根据输入文件的扩展名判断。
+使用 --dry-run 只检查数据，不写入数据库。`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := *importFormat
+		if format == "" {
+			format = catalog.FormatFromPath(*importInput)
+		}
+		if format == "" {
+			logging.Fatal("无法根据文件名判断格式，请使用 --format 指定", "input", *importInput)
+		}
+		if err := catalog.Valid(args[0], format); err != nil {
+			logging.Fatal("导入参数错误", "error", err)
+		}
+
+		in := os.Stdin
+		if *importInput != "" {
+			file, err := os.Open(*importInput)
+			if err != nil {
+				logging.Fatal("打开文件失败", "path", *importInput, "error", err)
+			}
+			defer file.Close()
+			in = file
+		}
+
+		config.InitDB()
+
+		report, err := catalog.Import(config.DB, bufio.NewReader(in), args[0], catalog.Options{
+			Format: format,
+			Mode:   *importMode,
+			DryRun: *importDryRun,
+			Actor:  "import:" + time.Now().Format("20060102150405"),
+		})
+		if report != nil {
+			data, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(data))
+		}
+		if err != nil {
+			logging.Fatal("导入失败", "entity", args[0], "error", err)
+		}
+		if report.Invalid > 0 || report.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importFormat = importCmd.Flags().StringP("format", "f", "", "导入格式: json、ndjson、csv")
+	importInput = importCmd.Flags().StringP("input", "i", "", "输入文件，默认为标准输入")
+	importMode = importCmd.Flags().StringP("mode", "m", catalog.ModeID, "匹配已有数据的方式: id、tmdb_id")
+	importDryRun = importCmd.Flags().Bool("dry-run", false, "只检查数据，不写入数据库")
+}
@@ -4,46 +4,74 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"errors"
+	"log/slog"
+
 	"github.com/Estella0129/theater/backend/config"
-	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/logging"
 	"github.com/spf13/cobra"
-	"golang.org/x/crypto/bcrypt"
 )
 
+var initAdminUsername *string
+var initAdminEmail *string
+var initAdminForce *bool
+var initAdminPassword passwordFlags
+
 // initAdminUserCmd represents the initAdminUser command
 var initAdminUserCmd = &cobra.Command{
 	Use:   "initAdminUser",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
+	Short: "初始化管理员账号",
+	Long: `创建管理员账号，邮箱和密码未通过参数指定时在终端中提示输入。
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+管理员已存在时默认拒绝执行，使用--force重置其密码并将角色设为admin。`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		config.InitDB()
 
-		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("admin1"), bcrypt.DefaultCost)
-		admin := &models.User{
-			Username: "admin", Password: string(hashedPassword), Email: "admin@cc.cc", Role: "admin",
+		user, err := findUser(*initAdminUsername)
+		if err != nil && !errors.Is(err, errUserNotFound) {
+			logging.Fatal("查找管理员失败", "username", *initAdminUsername, "error", err)
+		}
+		if err == nil {
+			if !*initAdminForce {
+				logging.Fatal("用户已存在，使用--force重置其密码", "username", user.Username)
+			}
+			password, err := initAdminPassword.resolve()
+			if err != nil {
+				logging.Fatal("重置管理员失败", "username", user.Username, "error", err)
+			}
+			hashed, err := hashPassword(password)
+			if err != nil {
+				logging.Fatal("重置管理员失败", "username", user.Username, "error", err)
+			}
+			if err := updateUser(user, map[string]interface{}{"password": hashed, "role": "admin"}); err != nil {
+				logging.Fatal("重置管理员失败", "username", user.Username, "error", err)
+			}
+			slog.Info("已重置管理员的密码", "username", user.Username)
+			return
+		}
+
+		email, err := promptValue(*initAdminEmail, "邮箱", "email")
+		if err != nil {
+			logging.Fatal("创建管理员失败", "error", err)
 		}
-		config.DB.FirstOrCreate(admin)
-		admin.Password = string(hashedPassword)
-		admin.Role = "admin"
-		config.DB.Save(admin)
+		password, err := initAdminPassword.resolve()
+		if err != nil {
+			logging.Fatal("创建管理员失败", "error", err)
+		}
+		user, err = createUser(*initAdminUsername, email, password, "admin")
+		if err != nil {
+			logging.Fatal("创建管理员失败", "username", *initAdminUsername, "error", err)
+		}
+		slog.Info("已创建管理员", "username", user.Username, "id", user.ID)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(initAdminUserCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// initAdminUserCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// initAdminUserCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	initAdminUsername = initAdminUserCmd.Flags().String("username", "admin", "管理员用户名")
+	initAdminEmail = initAdminUserCmd.Flags().String("email", "", "管理员邮箱，未指定时提示输入")
+	initAdminForce = initAdminUserCmd.Flags().Bool("force", false, "管理员已存在时重置其密码")
+	initAdminPassword = addPasswordFlags(initAdminUserCmd)
 }
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+	"github.com/Estella0129/theater/backend/pkg/migrate"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// migrateCmd 数据库迁移
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "管理数据库迁移",
+	Long: `查看和执行带版本号的数据库迁移，已执行的版本记录在schema_migrations表中。
+有未执行的迁移时server等命令会拒绝启动，升级程序后先运行 migrate up。
+之前由AutoMigrate创建的数据库可以直接运行 migrate up，基线迁移只会补充缺少的表和字段。`,
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "查看迁移的执行状态",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openMigrationDB()
+		statuses, err := migrate.Statuses(db)
+		if err != nil {
+			logging.Fatal("获取迁移状态失败", "error", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Unknown {
+				state += " (unknown)"
+			}
+			fmt.Printf("%4d  %-32s %s\n", status.Version, status.Name, state)
+		}
+	},
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "执行所有未执行的迁移",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		count, err := migrate.Up(openMigrationDB())
+		if err != nil {
+			logging.Fatal("迁移失败", "error", err)
+		}
+		slog.Info("迁移完成", "count", count)
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [数量]",
+	Short: "回滚最近执行的迁移，默认回滚1个",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		steps := 1
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				logging.Fatal("回滚数量应为正整数", "steps", args[0])
+			}
+			steps = n
+		}
+		count, err := migrate.Down(openMigrationDB(), steps)
+		if err != nil {
+			logging.Fatal("回滚失败", "error", err)
+		}
+		slog.Info("回滚完成", "count", count)
+	},
+}
+
+var migrateToCmd = &cobra.Command{
+	Use:   "to <版本>",
+	Short: "迁移到指定版本，高于该版本的已执行迁移会被回滚",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < 0 {
+			logging.Fatal("版本应为非负整数", "version", args[0])
+		}
+		count, err := migrate.To(openMigrationDB(), version)
+		if err != nil {
+			logging.Fatal("迁移失败", "version", version, "error", err)
+		}
+		slog.Info("迁移完成", "version", version, "count", count)
+	},
+}
+
+// openMigrationDB 连接数据库但不检查迁移状态
+func openMigrationDB() *gorm.DB {
+	config.Migrations = config.MigrationsIgnore
+	config.InitDB()
+	migrate.Logger = slog.Default()
+	return config.DB
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateStatusCmd, migrateUpCmd, migrateDownCmd, migrateToCmd)
+}
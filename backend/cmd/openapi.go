@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Estella0129/theater/backend/handlers"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+	"github.com/Estella0129/theater/backend/pkg/openapi"
+	"github.com/gin-gonic/gin"
+
+	"github.com/spf13/cobra"
+)
+
+var openapiOutput *string
+
+// openapiCmd 输出OpenAPI文档
+var openapiCmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "输出OpenAPI文档",
+	Long: `输出前端和管理后台接口的OpenAPI 3文档，与服务的/api/docs/openapi.json相同，不需要数据库。
+可以用来生成类型化的客户端，例如:
+
+  backend openapi -o openapi.json && npx openapi-typescript openapi.json -o src/api/schema.d.ts`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := json.MarshalIndent(handlers.OpenAPI(), "", "  ")
+		if err != nil {
+			logging.Fatal("生成文档失败", "error", err)
+		}
+		data = append(data, '\n')
+
+		if *openapiOutput == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(*openapiOutput, data, 0644); err != nil {
+			logging.Fatal("写入文件失败", "path", *openapiOutput, "error", err)
+		}
+	},
+}
+
+// openapiCheckCmd 检查路由与文档是否一致
+var openapiCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "检查每个接口都有文档",
+	Long:  `比较服务注册的路由与OpenAPI文档，有路由没有文档或文档中的接口不存在时以非零状态退出，可以在CI中运行。`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		missing, stale, total := compareRoutes(newRouter(), handlers.OpenAPI())
+		for _, route := range missing {
+			fmt.Fprintf(os.Stderr, "没有文档: %s\n", route)
+		}
+		for _, operation := range stale {
+			fmt.Fprintf(os.Stderr, "路由不存在: %s\n", operation)
+		}
+		if len(missing) > 0 || len(stale) > 0 {
+			return fmt.Errorf("接口文档与路由不一致: %d个路由没有文档，%d个文档接口不存在", len(missing), len(stale))
+		}
+		fmt.Printf("%d个接口都有文档\n", total)
+		return nil
+	},
+}
+
+// compareRoutes 比较路由与OpenAPI文档，返回没有文档的路由、路由不存在的文档接口和API路由总数
+func compareRoutes(r *gin.Engine, doc *openapi.Document) (missing, stale []string, total int) {
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		path, ok := strings.CutPrefix(route.Path, handlers.APIBasePath)
+		if !ok {
+			continue
+		}
+		registered[route.Method+" "+openapi.Path(path)] = true
+		if !doc.Has(route.Method, path) {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	for _, operation := range doc.Operations() {
+		if !registered[operation] {
+			stale = append(stale, operation)
+		}
+	}
+	return missing, stale, len(registered)
+}
+
+func init() {
+	rootCmd.AddCommand(openapiCmd)
+	openapiCmd.AddCommand(openapiCheckCmd)
+
+	openapiOutput = openapiCmd.Flags().StringP("output", "o", "", "输出文件，默认为标准输出")
+}
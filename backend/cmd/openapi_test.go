@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Estella0129/theater/backend/handlers"
+)
+
+// TestOpenAPICoversRoutes 每个/api/v1下的路由都要在handlers.OpenAPI中有文档，文档中也不能有不存在的接口
+func TestOpenAPICoversRoutes(t *testing.T) {
+	missing, stale, total := compareRoutes(newRouter(), handlers.OpenAPI())
+	for _, route := range missing {
+		t.Errorf("路由没有文档: %s", route)
+	}
+	for _, operation := range stale {
+		t.Errorf("文档中的接口没有路由: %s", operation)
+	}
+	if total == 0 {
+		t.Fatal("没有找到API路由")
+	}
+}
+
+// TestOpenAPIReferences 文档中的$ref都能找到对应的定义，operationId不重复，生成客户端时才不会出错
+func TestOpenAPIReferences(t *testing.T) {
+	data, err := json.Marshal(handlers.OpenAPI())
+	if err != nil {
+		t.Fatalf("序列化文档失败: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	components := doc["components"].(map[string]interface{})
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+				if len(parts) != 2 {
+					t.Errorf("无法解析的引用: %s", ref)
+				} else if group, _ := components[parts[0]].(map[string]interface{}); group[parts[1]] == nil {
+					t.Errorf("引用不存在: %s", ref)
+				}
+			}
+			for _, item := range v {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(doc)
+
+	ids := map[string]bool{}
+	for path, item := range doc["paths"].(map[string]interface{}) {
+		for method, op := range item.(map[string]interface{}) {
+			id := op.(map[string]interface{})["operationId"].(string)
+			if ids[id] {
+				t.Errorf("%s %s的operationId %s重复", method, path, id)
+			}
+			ids[id] = true
+		}
+	}
+}
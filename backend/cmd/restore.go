@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/backup"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreImages *bool
+var restoreVerifyOnly *bool
+
+// restoreCmd 从备份恢复数据库
+var restoreCmd = &cobra.Command{
+	Use:   "restore <备份文件>",
+	Short: "从备份恢复数据库",
+	Long: `校验备份文件的校验和、数据库完整性和必需的表，通过后替换数据库文件，原数据库保留为<数据库文件>.before-restore-<时间>。
+恢复前需要先停止服务。使用 --verify-only 只校验备份文件。`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if *restoreVerifyOnly {
+			manifest, err := backup.Verify(args[0])
+			if err != nil {
+				logging.Fatal("校验失败", "file", args[0], "error", err)
+			}
+			slog.Info("校验通过", "created_at", manifest.CreatedAt, "images", manifest.Images)
+			return
+		}
+
+		dbPath, err := config.GetSQLitePath()
+		if err != nil {
+			logging.Fatal("恢复失败", "file", args[0], "error", err)
+		}
+		manifest, err := backup.Restore(args[0], backup.RestoreOptions{
+			DBPath:        dbPath,
+			ImageDir:      config.GetImageDir(),
+			RestoreImages: *restoreImages,
+		})
+		if err != nil {
+			logging.Fatal("恢复失败", "file", args[0], "error", err)
+		}
+		slog.Info("恢复完成", "file", args[0], "created_at", manifest.CreatedAt)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	restoreImages = restoreCmd.Flags().Bool("images", true, "备份包含图片时同时恢复图片")
+	restoreVerifyOnly = restoreCmd.Flags().Bool("verify-only", false, "只校验备份文件，不恢复")
+}
@@ -6,9 +6,13 @@ package cmd
 import (
 	"os"
 
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/logging"
 	"github.com/spf13/cobra"
 )
 
+var cfgFile string
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "backend",
@@ -22,6 +26,15 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+
+	// 执行任何子命令前加载配置并设置日志，--help不需要配置文件
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if err := config.Load(cfgFile); err != nil {
+			return err
+		}
+		return logging.Setup(config.AppConfig.Log.Level, config.AppConfig.Log.Format)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -38,7 +51,7 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.backend.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "配置文件路径，默认为"+config.DefaultConfigPath+"，不存在时只使用默认值和THEATER_开头的环境变量")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -4,141 +4,319 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"syscall"
 
 	"github.com/Estella0129/theater/backend/config"
 	"github.com/Estella0129/theater/backend/handlers"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+	"github.com/Estella0129/theater/backend/pkg/metrics"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"github.com/Estella0129/theater/backend/pkg/security"
+	"github.com/Estella0129/theater/backend/pkg/server"
+	"github.com/Estella0129/theater/backend/web"
 	"github.com/gin-gonic/gin"
 
 	"github.com/spf13/cobra"
 )
 
+var serverMigrate *bool
+var serverIgnorePendingMigrations *bool
+
 // serverCmd represents the server command
 var serverCmd = &cobra.Command{
 	Use:   "server",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
+	Short: "启动HTTP服务",
+	Long: `启动HTTP服务，监听server.addr或server.socket，配置server.tls后使用HTTPS。
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+收到SIGINT或SIGTERM时停止接收新请求，在server.shutdown_timeout内等待处理中的请求和后台任务结束。`,
+	// 服务需要签发和校验token，其他命令不要求配置jwt.secret
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return config.CheckJWTSecret()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		// 初始化数据库连接
+		// 初始化数据库连接，默认有未执行的迁移时拒绝启动
+		if *serverMigrate {
+			config.Migrations = config.MigrationsApply
+		} else if *serverIgnorePendingMigrations {
+			config.Migrations = config.MigrationsIgnore
+		}
 		config.InitDB()
 
-		// 创建Gin路由引擎
-		r := gin.Default()
+		if err := metrics.RegisterDB(config.DB); err != nil {
+			logging.Fatal("注册数据库监控失败", "error", err)
+		}
+
+		r := newRouter()
+
+		// 启动HTTP服务器，收到SIGINT或SIGTERM时等待处理中的请求和后台任务结束后退出
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err := server.Run(ctx, r)
+		if closeErr := config.CloseDB(); closeErr != nil {
+			slog.Error("关闭数据库失败", "error", closeErr)
+		}
+		if err != nil {
+			logging.Fatal("服务异常退出", "error", err)
+		}
+		slog.Info("服务已停止")
+	},
+}
+
+// newRouter 创建Gin路由引擎并注册全部路由，openapi check命令使用同一份路由检查接口文档
+func newRouter() *gin.Engine {
+	// 创建Gin路由引擎，访问日志和panic都记录到slog，非debug级别时不输出gin的路由调试信息
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := gin.New()
+	if err := r.SetTrustedProxies(config.AppConfig.Server.TrustedProxies); err != nil {
+		logging.Fatal("设置可信代理失败", "error", err)
+	}
+	r.Use(logging.Middleware(), logging.Recovery(), metrics.Middleware())
+
+	// 安全响应头、跨域和CSRF检查，在限流之前以便429响应也带有跨域响应头
+	securityCfg := config.AppConfig.Security
+	r.Use(security.Headers(securityCfg.Headers), security.CORS(securityCfg.CORS))
+	if securityCfg.CSRF {
+		r.Use(security.CSRF(securityCfg.CORS))
+	}
+	handlers.InitRateLimits()
+
+	// 存活、就绪检查和监控指标
+	r.GET("/healthz", handlers.Healthz)
+	r.GET("/readyz", handlers.Readyz)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
 
-		// 设置API路由
-		v1 := r.Group("/api/v1")
+	// 接口文档
+	r.GET("/api/docs", handlers.APIDocs)                       // 文档页面
+	r.GET("/api/docs/openapi.json", handlers.OpenAPISpec)      // OpenAPI文档
+	r.GET("/api/docs/swagger-init.js", handlers.APIDocsScript) // 文档页面的初始化脚本
+
+	// 设置API路由，每个IP和登录用户分别限流
+	v1 := r.Group(handlers.APIBasePath, handlers.RateLimit())
+	{
+		// 前端接口路由组
+		frontend := v1.Group("/frontend")
 		{
-			// 前端接口路由组
-			frontend := v1.Group("/frontend")
-			{
-				// 用户相关路由
-				frontend.POST("/users/register", handlers.RegisterUser) // 用户注册
-				frontend.POST("/users/login", handlers.LoginUser)       // 用户登录
-				frontend.GET("/users/:id", handlers.GetUser)            // 获取用户详情
-
-				// 电影相关路由
-				frontend.GET("/movies", handlers.GetMovies)    // 获取电影列表
-				frontend.GET("/movies/:id", handlers.GetMovie) // 获取单个电影详情
-				frontend.GET("/genres", handlers.GetGenres)    // 获取所有电影类型
-
-				// 人物相关路由
-				frontend.GET("/peoples", handlers.GetPeoples)    // 获取人物列表
-				frontend.GET("/peoples/:id", handlers.GetPeople) // 获取单个人物详情
-			}
+			// 用户相关路由
+			frontend.POST("/users/register", handlers.RegisterRateLimit(), handlers.RegisterUser) // 用户注册
+			frontend.POST("/users/login", handlers.LoginRateLimit(), handlers.LoginUser)          // 用户登录
+			frontend.GET("/users/:id", handlers.GetUser)                                          // 获取用户详情
+
+			// 电影相关路由
+			frontend.GET("/movies", handlers.GetMovies)    // 获取电影列表
+			frontend.GET("/movies/:id", handlers.GetMovie) // 获取单个电影详情
+			frontend.GET("/genres", handlers.GetGenres)    // 获取所有电影类型
+
+			// 人物相关路由
+			frontend.GET("/peoples", handlers.GetPeoples)                               // 获取人物列表
+			frontend.GET("/peoples/:id", handlers.GetPeople)                            // 获取单个人物详情
+			frontend.GET("/peoples/:id/filmography", handlers.GetPeopleFilmography)     // 获取人物作品年表
+			frontend.GET("/peoples/:id/known-for", handlers.GetPeopleKnownFor)          // 获取人物代表作
+			frontend.GET("/peoples/:id/collaborators", handlers.GetPeopleCollaborators) // 获取人物常合作者
+
+			// 合集相关路由
+			frontend.GET("/collections", handlers.GetCollections)    // 获取合集列表
+			frontend.GET("/collections/:id", handlers.GetCollection) // 获取合集详情及电影
+		}
+
+		// 管理后台接口路由组
+		admin := v1.Group("/admin")
+		{
+
+			admin.POST("/upload-image", handlers.UploadImage) // 上传图片
+
+			// 用户管理路由
+			admin.POST("/users", handlers.CreateUser)                          // 管理员创建用户
+			admin.GET("/users", handlers.GetUsers)                             // 获取用户列表
+			admin.GET("/users/:id", handlers.GetAdminUser)                     // 获取用户详情
+			admin.PUT("/users/:id", handlers.UpdateUser)                       // 更新用户信息
+			admin.DELETE("/users/:id", handlers.DeleteUser)                    // 删除用户
+			admin.PATCH("/users/:id/toggle-freeze", handlers.ToggleFreezeUser) // 切换用户冻结状态
+			admin.PATCH("/users/:id/update_password", handlers.UpdatePassword) // 修改用户密码
+
+			// 电影管理路由
+			admin.POST("/movies", handlers.CreateMovie)       // 创建电影
+			admin.GET("/movies", handlers.GetAdminMovies)     // 获取电影列表
+			admin.GET("/movies/:id", handlers.GetAdminMovie)  // 获取电影详情
+			admin.PUT("/movies/:id", handlers.UpdateMovie)    // 更新电影信息
+			admin.PATCH("/movies/:id", handlers.PatchMovie)   // 部分更新电影信息
+			admin.DELETE("/movies/:id", handlers.DeleteMovie) // 删除电影
+
+			// 人物管理路由
+			admin.POST("/people", handlers.CreatePeople)            // 创建人物
+			admin.GET("/people", handlers.GetAdminPeople)           // 获取人物列表
+			admin.GET("/people/:id", handlers.GetAdminPeopleDetail) // 获取人物详情
+			admin.PUT("/people/:id", handlers.UpdatePeople)         // 更新人物信息
+			admin.DELETE("/people/:id", handlers.DeletePeople)
+			// 类型管理路由
+			admin.POST("/genres", handlers.CreateGenre)       // 创建类型
+			admin.GET("/genres", handlers.GetAdminGenres)     // 获取类型列表
+			admin.GET("/genres/:id", handlers.GetAdminGenre)  // 获取类型详情
+			admin.PUT("/genres/:id", handlers.UpdateGenre)    // 更新类型信息
+			admin.DELETE("/genres/:id", handlers.DeleteGenre) // 删除类型
 
-			// 管理后台接口路由组
-			admin := v1.Group("/admin")
+			// 合集管理路由
+			admin.POST("/collections", handlers.CreateCollection)       // 创建合集
+			admin.GET("/collections", handlers.GetCollections)          // 获取合集列表
+			admin.GET("/collections/:id", handlers.GetCollection)       // 获取合集详情
+			admin.PUT("/collections/:id", handlers.UpdateCollection)    // 更新合集信息
+			admin.DELETE("/collections/:id", handlers.DeleteCollection) // 删除合集
+
+			// 修改记录路由
+			admin.GET("/movies/:id/revisions", handlers.GetRevisions(revision.Movie))           // 电影修改记录
+			admin.GET("/people/:id/revisions", handlers.GetRevisions(revision.People))          // 人物修改记录
+			admin.GET("/genres/:id/revisions", handlers.GetRevisions(revision.Genre))           // 类型修改记录
+			admin.GET("/collections/:id/revisions", handlers.GetRevisions(revision.Collection)) // 合集修改记录
+			admin.GET("/revisions/:id", handlers.GetRevision)                                   // 修改记录详情及差异
+			admin.POST("/revisions/:id/rollback", handlers.RollbackRevision)                    // 回滚到指定修改记录
+
+			// 字段锁管理
+			admin.GET("/movies/:id/locks", handlers.GetFieldLocks(revision.Movie))                   // 电影字段锁及同步冲突
+			admin.POST("/movies/:id/locks", handlers.LockFields(revision.Movie))                     // 锁定电影字段
+			admin.DELETE("/movies/:id/locks/:field", handlers.UnlockField(revision.Movie))           // 解除电影字段锁
+			admin.GET("/people/:id/locks", handlers.GetFieldLocks(revision.People))                  // 人物字段锁及同步冲突
+			admin.POST("/people/:id/locks", handlers.LockFields(revision.People))                    // 锁定人物字段
+			admin.DELETE("/people/:id/locks/:field", handlers.UnlockField(revision.People))          // 解除人物字段锁
+			admin.GET("/genres/:id/locks", handlers.GetFieldLocks(revision.Genre))                   // 类型字段锁及同步冲突
+			admin.POST("/genres/:id/locks", handlers.LockFields(revision.Genre))                     // 锁定类型字段
+			admin.DELETE("/genres/:id/locks/:field", handlers.UnlockField(revision.Genre))           // 解除类型字段锁
+			admin.GET("/collections/:id/locks", handlers.GetFieldLocks(revision.Collection))         // 合集字段锁及同步冲突
+			admin.POST("/collections/:id/locks", handlers.LockFields(revision.Collection))           // 锁定合集字段
+			admin.DELETE("/collections/:id/locks/:field", handlers.UnlockField(revision.Collection)) // 解除合集字段锁
+			admin.GET("/sync-conflicts", handlers.GetSyncConflicts)                                  // 同步时跳过的冲突
+
+			// 回收站，type为movies、people、genres、collections或users
+			admin.GET("/trash/:type", handlers.GetTrash)                  // 回收站列表
+			admin.POST("/trash/:type/:id/restore", handlers.RestoreTrash) // 从回收站恢复
+			admin.DELETE("/trash/:type/:id", handlers.PurgeTrash)         // 永久删除
+
+			// 批量操作，请求中提供ids或filter，数量较多时在后台任务中执行
+			admin.POST("/bulk/movies/delete", handlers.BulkDeleteMovies)      // 批量删除电影
+			admin.POST("/bulk/movies/restore", handlers.BulkRestoreMovies)    // 批量恢复电影
+			admin.POST("/bulk/movies/genres", handlers.BulkUpdateMovieGenres) // 批量添加或移除类型
+			admin.POST("/bulk/movies/resync", handlers.BulkResyncMovies)      // 批量从TMDB重新同步
+			admin.POST("/bulk/people/delete", handlers.BulkDeletePeople)      // 批量删除人物
+			admin.POST("/bulk/people/restore", handlers.BulkRestorePeople)    // 批量恢复人物
+			admin.POST("/bulk/people/resync", handlers.BulkResyncPeople)      // 批量从TMDB重新同步人物详情
+			admin.POST("/bulk/users/freeze", handlers.BulkFreezeUsers)        // 批量冻结或解冻用户
+			admin.POST("/bulk/users/role", handlers.BulkUpdateUserRoles)      // 批量修改用户角色
+			admin.GET("/jobs/:id", handlers.GetJob)                           // 后台任务进度和结果
+
+			// 需要管理员登录的接口，涉及整个数据库或密码哈希等敏感数据
+			restricted := admin.Group("", handlers.AuthMiddleware(), handlers.AdminMiddleware())
 			{
+				// 导入导出
+				restricted.GET("/export/:entity", handlers.ExportCatalog)  // 导出电影、人物、类型或合集
+				restricted.POST("/import/:entity", handlers.ImportCatalog) // 导入电影、人物、类型或合集
 
-				admin.POST("/upload-image", handlers.UploadImage) // 上传图片
-
-				// 用户管理路由
-				admin.POST("/users", handlers.CreateUser)                          // 管理员创建用户
-				admin.GET("/users", handlers.GetUsers)                             // 获取用户列表
-				admin.PUT("/users/:id", handlers.UpdateUser)                       // 更新用户信息
-				admin.DELETE("/users/:id", handlers.DeleteUser)                    // 删除用户
-				admin.PATCH("/users/:id/toggle-freeze", handlers.ToggleFreezeUser) // 切换用户冻结状态
-				admin.PATCH("/users/:id/update_password", handlers.UpdatePassword) // 修改用户密码
-
-				// 电影管理路由
-				admin.POST("/movies", handlers.CreateMovie)       // 创建电影
-				admin.GET("/movies", handlers.GetAdminMovies)     // 获取电影列表
-				admin.PUT("/movies/:id", handlers.UpdateMovie)    // 更新电影信息
-				admin.DELETE("/movies/:id", handlers.DeleteMovie) // 删除电影
-
-				// 人物管理路由
-				admin.POST("/people", handlers.CreatePeople)    // 创建人物
-				admin.GET("/people", handlers.GetAdminPeople)   // 获取人物列表
-				admin.PUT("/people/:id", handlers.UpdatePeople) // 更新人物信息
-				admin.DELETE("/people/:id", handlers.DeletePeople)
-				// 类型管理路由
-				admin.POST("/genres", handlers.CreateGenre)       // 创建类型
-				admin.GET("/genres", handlers.GetAdminGenres)     // 获取类型列表
-				admin.PUT("/genres/:id", handlers.UpdateGenre)    // 更新类型信息
-				admin.DELETE("/genres/:id", handlers.DeleteGenre) // 删除类型
+				// 备份，恢复需要停止服务后使用restore命令
+				restricted.POST("/backups", handlers.CreateBackup)        // 在后台任务中备份数据库
+				restricted.GET("/backups", handlers.GetBackups)           // 备份列表
+				restricted.GET("/backups/:name", handlers.DownloadBackup) // 下载备份文件
 			}
 		}
+	}
+
+	// 添加中间件处理图片下载
+	r.Use(func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/images/") {
+			filename := strings.TrimPrefix(c.Request.URL.Path, "/images/")
+			localPath, ok := tmdbImagePath(config.GetImageDir(), filename)
+			if !ok {
+				// 不是TMDB的图片文件名，如上传的图片，只由静态文件处理，不从TMDB下载
+				c.Next()
+				return
+			}
 
-		// 添加中间件处理图片下载
-		r.Use(func(c *gin.Context) {
-			if strings.HasPrefix(c.Request.URL.Path, "/images/") {
-				filename := strings.TrimPrefix(c.Request.URL.Path, "/images/")
-				localPath := "./images/" + filename
-
-				// 检查文件是否存在
-				if _, err := os.Stat(localPath); os.IsNotExist(err) {
-					// 文件不存在，从TMDB下载
-					imageUrl := "https://image.tmdb.org/t/p/original/" + filename
-					resp, err := http.Get(imageUrl)
-					if err != nil || resp.StatusCode != http.StatusOK {
-						c.AbortWithStatus(http.StatusNotFound)
-						return
-					}
-					defer resp.Body.Close()
-
-					// 确保目录存在
-					if error := os.MkdirAll(filepath.Dir(localPath), 0755); error != nil {
-						c.AbortWithStatus(http.StatusInternalServerError)
-						return
-					}
-
-					// 保存文件
-					out, error := os.Create(localPath)
-					if error != nil {
-						c.AbortWithStatus(http.StatusInternalServerError)
-						return
-					}
-					defer out.Close()
-
-					if _, error := io.Copy(out, resp.Body); error != nil {
-						c.AbortWithStatus(http.StatusInternalServerError)
-						return
-					}
-
-					// 立即返回下载的文件内容
-					http.ServeFile(c.Writer, c.Request, localPath)
-					c.Abort()
+			// 检查文件是否存在
+			if _, err := os.Stat(localPath); os.IsNotExist(err) {
+				// 文件不存在，从TMDB下载，每个IP限流避免被用来大量下载
+				if !handlers.AllowImageDownload(c) {
 					return
+				}
+				imageUrl := config.GetTMDBImageURL("/" + filename)
+				resp, err := http.Get(imageUrl)
+				if err != nil || resp.StatusCode != http.StatusOK {
+					metrics.ImageCacheRequests.WithLabelValues("error").Inc()
+					c.AbortWithStatus(http.StatusNotFound)
+					return
+				}
+				defer resp.Body.Close()
+
+				// 确保目录存在
+				if error := os.MkdirAll(filepath.Dir(localPath), 0755); error != nil {
+					metrics.ImageCacheRequests.WithLabelValues("error").Inc()
+					c.AbortWithStatus(http.StatusInternalServerError)
+					return
+				}
 
-					// 重新尝试读取本地文件
+				// 保存文件
+				out, error := os.Create(localPath)
+				if error != nil {
+					metrics.ImageCacheRequests.WithLabelValues("error").Inc()
+					c.AbortWithStatus(http.StatusInternalServerError)
+					return
+				}
+				defer out.Close()
 
+				if _, error := io.Copy(out, resp.Body); error != nil {
+					metrics.ImageCacheRequests.WithLabelValues("error").Inc()
+					c.AbortWithStatus(http.StatusInternalServerError)
+					return
 				}
+				metrics.ImageCacheRequests.WithLabelValues("miss").Inc()
+
+				// 立即返回下载的文件内容
+				http.ServeFile(c.Writer, c.Request, localPath)
+				c.Abort()
+				return
+
+				// 重新尝试读取本地文件
+
 			}
-			c.Next()
-		}).StaticFS("/images", gin.Dir("./images", false))
+			metrics.ImageCacheRequests.WithLabelValues("hit").Inc()
+		}
+		c.Next()
+	}).StaticFS("/images", gin.Dir(config.GetImageDir(), false))
 
-		// 启动HTTP服务器
-		r.Run(":8080")
-	},
+	// 前端页面，未匹配的非API路径返回index.html由前端路由处理；不提供页面时返回JSON的404
+	r.NoRoute(web.NotFound)
+	if config.AppConfig.Frontend.Enabled {
+		frontend, err := web.FS(config.AppConfig.Frontend.Dir)
+		if err != nil {
+			logging.Fatal("加载前端页面失败", "dir", config.AppConfig.Frontend.Dir, "error", err)
+		}
+		if frontend != nil {
+			r.NoRoute(web.Handler(frontend))
+		}
+	}
+	return r
+}
+
+// tmdbImageName TMDB图片的文件名，只有一级路径
+var tmdbImageName = regexp.MustCompile(`^[A-Za-z0-9_-]+\.(jpg|jpeg|png|svg)$`)
+
+// tmdbImagePath 图片在本地图片目录中的路径，filename不是TMDB图片的文件名或路径不在目录中时返回false
+func tmdbImagePath(dir, filename string) (string, bool) {
+	if !tmdbImageName.MatchString(filename) {
+		return "", false
+	}
+	localPath := filepath.Join(dir, filename)
+	rel, err := filepath.Rel(dir, localPath)
+	if err != nil || rel != filename {
+		return "", false
+	}
+	return localPath, true
 }
 
 func init() {
@@ -153,4 +331,6 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// serverCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	serverMigrate = serverCmd.Flags().Bool("migrate", false, "启动前执行未执行的数据库迁移")
+	serverIgnorePendingMigrations = serverCmd.Flags().Bool("ignore-pending-migrations", false, "有未执行的数据库迁移时仍然启动")
 }
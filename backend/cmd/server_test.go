@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTMDBImagePath(t *testing.T) {
+	dir := filepath.Join("data", "images")
+	tests := []struct {
+		filename string
+		ok       bool
+	}{
+		{"kqjL17yufvn9OVLyXYpvtyrFfak.jpg", true},
+		{"abc_DEF-123.jpeg", true},
+		{"logo.png", true},
+		{"logo.svg", true},
+		{"poster.webp", false},
+		{"poster", false},
+		{"../config/config.yaml", false},
+		{"..%2Fsecret.jpg", false},
+		{"w500/poster.jpg", false},
+		{"/etc/passwd.jpg", false},
+		{"poster.jpg/", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		path, ok := tmdbImagePath(dir, tt.filename)
+		if ok != tt.ok {
+			t.Errorf("tmdbImagePath(%q) = %v，应为%v", tt.filename, ok, tt.ok)
+			continue
+		}
+		if ok && path != filepath.Join(dir, tt.filename) {
+			t.Errorf("tmdbImagePath(%q)的路径为%q", tt.filename, path)
+		}
+	}
+}
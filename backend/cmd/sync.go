@@ -5,8 +5,9 @@ package cmd
 
 import (
 	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/logging"
 	"github.com/Estella0129/theater/backend/pkg/sync"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -31,20 +32,22 @@ to quickly create a Cobra application.`,
 		// 初始化数据库
 		config.InitDB()
 
-		_ = sync.Genre()
+		if err := sync.Genre(); err != nil {
+			slog.Warn("同步类型失败", "error", err)
+		}
 
 		if *manual {
 			// 手动执行同步
 			if err := sync.SyncMovies(); err != nil {
-				log.Fatalf("同步失败: %v", err)
+				logging.Fatal("同步失败", "error", err)
 			}
-			log.Println("同步成功")
+			slog.Info("同步成功")
 			return
 		}
 
 		// 定时同步
 		duration := time.Duration(*interval) * time.Minute
-		log.Printf("启动定时同步服务，间隔 %v", duration)
+		slog.Info("启动定时同步服务", "interval", duration)
 		ticker := time.NewTicker(duration)
 		defer ticker.Stop()
 
@@ -52,7 +55,7 @@ to quickly create a Cobra application.`,
 			select {
 			case <-ticker.C:
 				if err := sync.SyncMovies(); err != nil {
-					log.Printf("同步失败: %v", err)
+					slog.Error("同步失败", "error", err)
 				}
 			}
 		}
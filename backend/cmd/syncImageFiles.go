@@ -6,6 +6,7 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"sync"
@@ -55,13 +56,15 @@ to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		config.InitDB()
 
-		var movieImages []models.MovieImage
-		config.DB.Find(&movieImages)
-		//config.DB.Limit(20).Find(&movieImages)
+		// 电影图片和人物图片
+		var imagePaths []string
+		config.DB.Model(&models.MovieImage{}).Distinct().Pluck("image_file_path", &imagePaths)
+		var peopleImagePaths []string
+		config.DB.Model(&models.PeopleImage{}).Distinct().Pluck("image_file_path", &peopleImagePaths)
+		imagePaths = append(imagePaths, peopleImagePaths...)
 
-		baseUrl := "https://image.tmdb.org/t/p/original"
 		// 图片存储目录
-		imageDir := "images"
+		imageDir := config.GetImageDir()
 
 		// 创建图片目录(如果不存在)
 		if _, err := os.Stat(imageDir); os.IsNotExist(err) {
@@ -71,36 +74,36 @@ to quickly create a Cobra application.`,
 			}
 		}
 
-		total := len(movieImages)
+		total := len(imagePaths)
 		var wg sync.WaitGroup
 		workerLimit := make(chan struct{}, 10)
 
-		for index, image := range movieImages {
+		for index, imagePath := range imagePaths {
 			wg.Add(1)
 			workerLimit <- struct{}{}
 
-			go func(index int, image models.MovieImage) {
+			go func(index int, imagePath string) {
 				defer func() {
 					<-workerLimit
 					wg.Done()
 				}()
 
 				// 拼接本地文件路径
-				localPath := imageDir + "/" + image.ImageFilePath
+				localPath := imageDir + "/" + imagePath
 
 				// 检查文件是否已存在
 				if _, err := os.Stat(localPath); os.IsNotExist(err) {
 					// 文件不存在，下载图片
-					imageUrl := baseUrl + image.ImageFilePath
+					imageUrl := config.GetTMDBImageURL(imagePath)
 					err := downloadImage(imageUrl, localPath)
 					if err != nil {
 						// 记录下载失败
-						println("下载失败:", imageUrl, "错误:", err.Error())
+						slog.Warn("下载图片失败", "url", imageUrl, "error", err)
 						return
 					}
-					println(fmt.Sprintf("%d/%d 下载成功: %s", index+1, total, imageUrl))
+					slog.Info("下载图片成功", "url", imageUrl, "index", index+1, "total", total)
 				}
-			}(index, image)
+			}(index, imagePath)
 		}
 		wg.Wait()
 	},
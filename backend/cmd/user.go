@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+	"gorm.io/gorm"
+)
+
+// minPasswordLength 命令行设置的密码的最小长度
+const minPasswordLength = 8
+
+// generatedPasswordLength 自动生成的密码长度
+const generatedPasswordLength = 20
+
+// passwordChars 自动生成密码使用的字符，去掉了容易混淆的字符
+const passwordChars = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+
+// validRoles 可以设置的用户角色
+var validRoles = map[string]bool{"user": true, "admin": true}
+
+// errUserNotFound 用户不存在
+var errUserNotFound = errors.New("用户不存在")
+
+// stdin 按行读取标准输入，非终端输入时密码也从这里读取
+var stdin = bufio.NewReader(os.Stdin)
+
+// passwordFlags 设置密码的命令共用的参数
+type passwordFlags struct {
+	password *string
+	generate *bool
+}
+
+func addPasswordFlags(cmd *cobra.Command) passwordFlags {
+	return passwordFlags{
+		password: cmd.Flags().String("password", "", "密码，会出现在进程列表和shell历史中，建议省略后按提示输入"),
+		generate: cmd.Flags().Bool("generate-password", false, "生成随机密码并输出"),
+	}
+}
+
+// resolve 根据参数得到密码：指定的密码、生成的随机密码或提示输入
+func (f passwordFlags) resolve() (string, error) {
+	if *f.generate {
+		if *f.password != "" {
+			return "", errors.New("--password和--generate-password不能同时使用")
+		}
+		password, err := generatePassword()
+		if err != nil {
+			return "", err
+		}
+		fmt.Printf("生成的密码: %s\n", password)
+		return password, nil
+	}
+
+	password := *f.password
+	if password == "" {
+		var err error
+		if password, err = promptPassword(); err != nil {
+			return "", err
+		}
+	}
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("密码至少需要%d个字符", minPasswordLength)
+	}
+	return password, nil
+}
+
+func generatePassword() (string, error) {
+	b := make([]byte, generatedPasswordLength)
+	max := big.NewInt(int64(len(passwordChars)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("生成密码失败: %v", err)
+		}
+		b[i] = passwordChars[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// promptPassword 在终端中不回显地输入两次密码，非终端时从标准输入读取一行
+func promptPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.New("未提供密码，请使用--password、--generate-password或从标准输入提供")
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "密码: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "确认密码: ")
+	confirm, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(password) != string(confirm) {
+		return "", errors.New("两次输入的密码不一致")
+	}
+	return string(password), nil
+}
+
+// promptValue 未通过参数指定时在终端中提示输入，非终端时返回错误
+func promptValue(value, label, flag string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("请使用--%s指定%s", flag, label)
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+	line, err := stdin.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if value = strings.TrimSpace(line); value == "" {
+		return "", fmt.Errorf("%s不能为空", label)
+	}
+	return value, nil
+}
+
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("密码加密失败: %v", err)
+	}
+	return string(hashed), nil
+}
+
+// findUser 按用户名查找用户，包括已删除的用户
+func findUser(username string) (*models.User, error) {
+	var user models.User
+	err := config.DB.Unscoped().Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", errUserNotFound, username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt.Valid {
+		return nil, fmt.Errorf("用户%s在回收站中，请先恢复", username)
+	}
+	return &user, nil
+}
+
+// createUser 创建用户，password为明文
+func createUser(username, email, password, role string) (*models.User, error) {
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{Username: username, Email: email, Password: hashed, Role: role}
+	if err := config.DB.Create(user).Error; err != nil {
+		if dialect.IsDuplicateKey(err) {
+			return nil, fmt.Errorf("用户名%s或邮箱%s已被使用", username, email)
+		}
+		return nil, fmt.Errorf("创建用户失败: %v", err)
+	}
+	return user, nil
+}
+
+// updateUser 更新用户的字段并增加版本号，使其他人持有的ETag失效
+func updateUser(user *models.User, fields map[string]interface{}) error {
+	fields["version"] = gorm.Expr("version + 1")
+	return config.DB.Model(user).Updates(fields).Error
+}
+
+// userCmd 用户管理
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "管理用户",
+}
+
+var userCreateEmail *string
+var userCreateRole *string
+var userCreatePassword passwordFlags
+
+var userCreateCmd = &cobra.Command{
+	Use:   "create <用户名>",
+	Short: "创建用户",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !validRoles[*userCreateRole] {
+			logging.Fatal("角色只能是user或admin", "role", *userCreateRole)
+		}
+		config.InitDB()
+
+		email, err := promptValue(*userCreateEmail, "邮箱", "email")
+		if err != nil {
+			logging.Fatal("创建用户失败", "error", err)
+		}
+		password, err := userCreatePassword.resolve()
+		if err != nil {
+			logging.Fatal("创建用户失败", "error", err)
+		}
+		user, err := createUser(args[0], email, password, *userCreateRole)
+		if err != nil {
+			logging.Fatal("创建用户失败", "username", args[0], "error", err)
+		}
+		slog.Info("已创建用户", "username", user.Username, "id", user.ID, "role", user.Role)
+	},
+}
+
+var userSetPassword passwordFlags
+
+var userSetPasswordCmd = &cobra.Command{
+	Use:   "set-password <用户名>",
+	Short: "修改用户密码",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config.InitDB()
+
+		user, err := findUser(args[0])
+		if err != nil {
+			logging.Fatal("修改密码失败", "username", args[0], "error", err)
+		}
+		password, err := userSetPassword.resolve()
+		if err != nil {
+			logging.Fatal("修改密码失败", "username", user.Username, "error", err)
+		}
+		hashed, err := hashPassword(password)
+		if err != nil {
+			logging.Fatal("修改密码失败", "username", user.Username, "error", err)
+		}
+		if err := updateUser(user, map[string]interface{}{"password": hashed}); err != nil {
+			logging.Fatal("修改密码失败", "username", user.Username, "error", err)
+		}
+		slog.Info("已修改用户的密码", "username", user.Username)
+	},
+}
+
+var userSetRoleCmd = &cobra.Command{
+	Use:   "set-role <用户名> <user|admin>",
+	Short: "修改用户角色",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		role := args[1]
+		if !validRoles[role] {
+			logging.Fatal("角色只能是user或admin", "role", role)
+		}
+		config.InitDB()
+
+		user, err := findUser(args[0])
+		if err != nil {
+			logging.Fatal("修改角色失败", "username", args[0], "error", err)
+		}
+		if user.Role == role {
+			slog.Info("用户的角色没有变化", "username", user.Username, "role", role)
+			return
+		}
+		if err := updateUser(user, map[string]interface{}{"role": role}); err != nil {
+			logging.Fatal("修改角色失败", "username", user.Username, "error", err)
+		}
+		slog.Info("已修改用户的角色", "username", user.Username, "role", role)
+	},
+}
+
+var userListRole *string
+var userListDeleted *bool
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出用户",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config.InitDB()
+
+		dbQuery := config.DB.Model(&models.User{})
+		if *userListDeleted {
+			dbQuery = dbQuery.Unscoped()
+		}
+		if *userListRole != "" {
+			dbQuery = dbQuery.Where("role = ?", *userListRole)
+		}
+		var users []models.User
+		if err := dbQuery.Omit("password").Order("id").Find(&users).Error; err != nil {
+			logging.Fatal("获取用户列表失败", "error", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tROLE\tFROZEN\tDELETED\tCREATED_AT")
+		for _, user := range users {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%t\t%t\t%s\n", user.ID, user.Username, user.Email, user.Role,
+				user.IsFrozen, user.DeletedAt.Valid, user.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(userCmd)
+	userCmd.AddCommand(userCreateCmd, userSetPasswordCmd, userSetRoleCmd, userListCmd)
+
+	userCreateEmail = userCreateCmd.Flags().String("email", "", "邮箱，未指定时提示输入")
+	userCreateRole = userCreateCmd.Flags().String("role", "user", "角色: user、admin")
+	userCreatePassword = addPasswordFlags(userCreateCmd)
+
+	userSetPassword = addPasswordFlags(userSetPasswordCmd)
+
+	userListRole = userListCmd.Flags().String("role", "", "只列出指定角色的用户")
+	userListDeleted = userListCmd.Flags().Bool("deleted", false, "包括回收站中的用户")
+}
@@ -2,38 +2,551 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"net"
+	"net/url"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"github.com/Estella0129/theater/backend/pkg/logging"
 	"gopkg.in/yaml.v2"
 )
 
+// Config 应用配置，加载顺序为默认值、配置文件、环境变量，后面的覆盖前面的。
+// 每个配置项都可以用环境变量THEATER_<分组>_<名称>覆盖，如THEATER_TMDB_API_TOKEN、THEATER_SERVER_ADDR
 type Config struct {
+	Server struct {
+		Addr string `yaml:"addr"` // 监听地址，默认为:8080
+		// Unix socket路径，配置后监听该socket而不是addr，用于在反向代理后运行
+		Socket     string `yaml:"socket"`
+		SocketMode string `yaml:"socket_mode"` // socket文件权限，八进制，默认为0660
+
+		// 超时时间，如30s、2m，0为不限制
+		ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"` // 读取请求头，默认为10s
+		ReadTimeout       time.Duration `yaml:"read_timeout"`        // 读取整个请求，包括上传和导入的文件，默认为5m
+		WriteTimeout      time.Duration `yaml:"write_timeout"`       // 写入响应，包括导出和下载备份，默认为10m
+		IdleTimeout       time.Duration `yaml:"idle_timeout"`        // keep-alive连接的空闲时间，默认为2m
+		ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`    // 停止时等待处理中的请求和后台任务，默认为30s
+
+		// 可信的反向代理地址或网段，只有来自这些地址的请求才使用X-Forwarded-For中的客户端IP，
+		// 默认不信任任何代理。监听Unix socket时总是使用反向代理传入的客户端IP
+		TrustedProxies []string `yaml:"trusted_proxies"`
+
+		TLS struct {
+			CertFile string `yaml:"cert_file"`
+			KeyFile  string `yaml:"key_file"`
+			// ACME自动申请证书，与cert_file、key_file不能同时使用
+			ACME struct {
+				Domains      []string `yaml:"domains"` // 申请证书的域名，环境变量中用逗号分隔
+				Email        string   `yaml:"email"`
+				DirectoryURL string   `yaml:"directory_url"` // ACME服务地址，默认为Let's Encrypt，测试时可使用本地的Pebble或step-ca
+				CAFile       string   `yaml:"ca_file"`       // 信任的ACME服务根证书，用于本地测试CA
+				CacheDir     string   `yaml:"cache_dir"`     // 证书缓存目录，默认为acme-cache
+				HTTPAddr     string   `yaml:"http_addr"`     // 处理HTTP-01验证并将HTTP请求重定向到HTTPS的监听地址，如:80，为空时只使用TLS-ALPN-01
+			} `yaml:"acme"`
+		} `yaml:"tls"`
+	} `yaml:"server"`
+	Log struct {
+		Level     string        `yaml:"level"`      // debug、info、warn、error，默认为info，debug时输出所有SQL
+		Format    string        `yaml:"format"`     // text或json，默认为text
+		SlowQuery time.Duration `yaml:"slow_query"` // 超过该时间的SQL记录为慢查询，默认为200ms，0为不记录
+	} `yaml:"log"`
+	Database struct {
+		Driver string `yaml:"driver"` // sqlite、postgres或mysql，默认为sqlite
+		// 连接字符串，SQLite为数据库文件路径，默认为theater.db；
+		// PostgreSQL如 host=localhost user=theater password=xxx dbname=theater port=5432 sslmode=disable；
+		// MySQL如 theater:xxx@tcp(localhost:3306)/theater?charset=utf8mb4&parseTime=True&loc=Local
+		DSN string `yaml:"dsn"`
+	} `yaml:"database"`
+	Images struct {
+		Dir string `yaml:"dir"` // 本地图片目录，默认为images
+	} `yaml:"images"`
+	Frontend struct {
+		Enabled bool `yaml:"enabled"` // 是否提供前端页面，前端单独部署时关闭，默认开启
+		// 前端构建产物目录，如../frontend/dist；为空时使用编译时嵌入的页面，没有嵌入时不提供页面
+		Dir string `yaml:"dir"`
+	} `yaml:"frontend"`
 	TMDB struct {
 		APIToken string `yaml:"api_token"`
+		APIURL   string `yaml:"api_url"`   // API地址，默认为https://api.themoviedb.org/3
+		ImageURL string `yaml:"image_url"` // 原图地址，默认为https://image.tmdb.org/t/p/original
+		Language string `yaml:"language"`  // 请求数据的语言，默认为zh-CN
 	} `yaml:"tmdb"`
 	JWT struct {
+		// 签名登录凭证的密钥，必须配置且至少32个字符，可以用openssl rand -base64 48生成
 		Secret string `yaml:"secret"`
 	} `yaml:"jwt"`
+	Sync struct {
+		MaxPages int `yaml:"max_pages"` // 每次同步的热门电影页数，每页20部，默认为10
+		MaxCast  int `yaml:"max_cast"`  // 每部电影同步的演员数量，0为默认值，负数为不限制
+		MaxCrew  int `yaml:"max_crew"`  // 每部电影每个部门同步的职员数量，0为默认值，负数为不限制
+		// 人物详情的刷新间隔(天)，超过该时间的人物在同步时重新请求详情
+		PeopleRefreshDays int `yaml:"people_refresh_days"`
+	} `yaml:"sync"`
+	Security struct {
+		CORS    CORS            `yaml:"cors"`
+		Headers SecurityHeaders `yaml:"headers"`
+		// 带有Cookie的POST、PUT、PATCH、DELETE请求必须来自同源页面或cors.allowed_origins，默认开启。
+		// 目前登录凭证在Authorization请求头中，改为Cookie会话后用于防止CSRF
+		CSRF bool `yaml:"csrf"`
+	} `yaml:"security"`
+	RateLimit struct {
+		// 限流状态保存位置，memory或database，默认为memory；多个实例需要共享限制时使用database
+		Store    string      `yaml:"store"`
+		IP       TokenBucket `yaml:"ip"`       // 每个IP的全部API请求
+		User     TokenBucket `yaml:"user"`     // 每个登录用户的全部API请求
+		Login    TokenBucket `yaml:"login"`    // 每个IP的登录请求
+		Register TokenBucket `yaml:"register"` // 每个IP的注册请求
+		Images   TokenBucket `yaml:"images"`   // 每个IP从TMDB下载本地没有的图片
+		// 同一IP对同一用户名连续登录失败后锁定，每次锁定后再失败锁定时间翻倍，登录成功后重新计数。
+		// 同一用户名在所有IP上的失败另外计数，使用更高的次数，防止从大量IP分散猜测密码
+		Lockout struct {
+			MaxFailures     int           `yaml:"max_failures"`      // 同一IP连续失败该次数后锁定，0为不锁定，默认为5
+			UserMaxFailures int           `yaml:"user_max_failures"` // 所有IP合计连续失败该次数后锁定该用户名，0为不锁定，默认为20
+			Duration        time.Duration `yaml:"duration"`          // 第一次锁定的时间，默认为1m
+			MaxDuration     time.Duration `yaml:"max_duration"`      // 最长锁定时间，默认为1h
+		} `yaml:"lockout"`
+	} `yaml:"rate_limit"`
+	Backup struct {
+		Dir           string `yaml:"dir"`            // 备份目录
+		Keep          int    `yaml:"keep"`           // 保留最近的备份数量，0为默认值，负数为不清理
+		IncludeImages bool   `yaml:"include_images"` // 备份时是否包含图片目录
+	} `yaml:"backup"`
+}
+
+// TokenBucket 令牌桶限流，每period补充rate个令牌，最多积累burst个，每个请求消耗一个。rate为0时不限制
+type TokenBucket struct {
+	Rate   int           `yaml:"rate"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+}
+
+// CORS 跨域访问配置，allowed_origins为空时不允许跨域请求
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`   // 允许的来源，如http://localhost:5173，*为任意来源
+	AllowedMethods   []string      `yaml:"allowed_methods"`   // 允许的请求方法
+	AllowedHeaders   []string      `yaml:"allowed_headers"`   // 允许的请求头
+	ExposedHeaders   []string      `yaml:"exposed_headers"`   // 页面可以读取的响应头
+	AllowCredentials bool          `yaml:"allow_credentials"` // 是否允许携带Cookie，不能与*同时使用
+	MaxAge           time.Duration `yaml:"max_age"`           // 浏览器缓存预检结果的时间
 }
 
-var AppConfig Config
+// SecurityHeaders 每个响应都带有的安全响应头，值为空时不设置
+type SecurityHeaders struct {
+	ContentSecurityPolicy string        `yaml:"content_security_policy"`
+	FrameOptions          string        `yaml:"frame_options"`   // X-Frame-Options，DENY或SAMEORIGIN
+	ReferrerPolicy        string        `yaml:"referrer_policy"` // Referrer-Policy
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age"`    // HTTPS请求的Strict-Transport-Security有效期，0为不设置
+}
+
+// DefaultConfigPath 未指定--config时读取的配置文件，文件不存在时只使用默认值和环境变量
+const DefaultConfigPath = "./config/config.yaml"
+
+// envPrefix 覆盖配置的环境变量前缀
+const envPrefix = "THEATER"
+
+// 服务的默认超时时间和socket权限
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 5 * time.Minute
+	DefaultWriteTimeout      = 10 * time.Minute
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultShutdownTimeout   = 30 * time.Second
+	DefaultSocketMode        = "0660"
+	DefaultACMECacheDir      = "acme-cache"
+)
+
+// 日志的默认配置
+const (
+	DefaultLogLevel  = "info"
+	DefaultLogFormat = "text"
+	DefaultSlowQuery = 200 * time.Millisecond
+)
+
+// 服务、图片和TMDB的默认配置
+const (
+	DefaultAddr         = ":8080"
+	DefaultImageDir     = "images"
+	DefaultTMDBAPIURL   = "https://api.themoviedb.org/3"
+	DefaultTMDBImageURL = "https://image.tmdb.org/t/p/original"
+	DefaultTMDBLanguage = "zh-CN"
+	DefaultSyncMaxPages = 10
+)
+
+// 每部电影默认同步的演职人员数量
+const (
+	DefaultMaxCast = 12
+	DefaultMaxCrew = 12
+
+	DefaultPeopleRefreshDays = 30
+)
+
+// 默认的跨域和安全响应头配置。CSP允许/api/docs从jsDelivr加载Swagger UI，图片可以来自TMDB等HTTPS地址
+var (
+	DefaultCORSMethods        = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	DefaultCORSHeaders        = []string{"Authorization", "Content-Type", "If-Match", "If-None-Match", "Accept-Language", "X-Request-ID"}
+	DefaultCORSExposedHeaders = []string{"ETag", "Location", "Retry-After", "Content-Disposition", "X-Request-ID"}
+)
+
+const (
+	DefaultCORSMaxAge            = 10 * time.Minute
+	DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self' https://cdn.jsdelivr.net; " +
+		"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; img-src 'self' data: https:; font-src 'self' data:; " +
+		"connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+	DefaultFrameOptions   = "DENY"
+	DefaultReferrerPolicy = "strict-origin-when-cross-origin"
+	DefaultHSTSMaxAge     = 180 * 24 * time.Hour
+)
+
+// 限流状态的保存位置
+const (
+	RateLimitMemory   = "memory"
+	RateLimitDatabase = "database"
+)
 
-// JWTSecret 用于JWT token签名的密钥
+// 默认的限流和登录锁定配置
+var (
+	DefaultIPRateLimit       = TokenBucket{Rate: 300, Period: time.Minute, Burst: 100}
+	DefaultUserRateLimit     = TokenBucket{Rate: 600, Period: time.Minute, Burst: 200}
+	DefaultLoginRateLimit    = TokenBucket{Rate: 10, Period: time.Minute, Burst: 10}
+	DefaultRegisterRateLimit = TokenBucket{Rate: 5, Period: time.Hour, Burst: 5}
+	DefaultImagesRateLimit   = TokenBucket{Rate: 60, Period: time.Minute, Burst: 60}
+)
+
+const (
+	DefaultLockoutMaxFailures     = 5
+	DefaultLockoutUserMaxFailures = 20
+	DefaultLockoutDuration        = time.Minute
+	DefaultLockoutMaxDuration     = time.Hour
+)
+
+// 备份的默认目录和保留数量
+const (
+	DefaultBackupDir  = "backups"
+	DefaultBackupKeep = 7
+)
+
+// MinJWTSecretLength JWT签名密钥的最小长度，HS256的密钥不应短于哈希长度
+const MinJWTSecretLength = 32
+
+var AppConfig = defaultConfig()
+
+// JWTSecret 用于JWT token签名的密钥，由Load设置
 var JWTSecret string
 
-func init() {
-	// 读取配置文件
-	yamlFile, err := os.ReadFile("./config/config.yaml")
-	if err != nil {
-		log.Fatalf("读取配置文件失败: %v", err)
+// CheckJWTSecret 检查jwt.secret的长度，只有启动服务和签发token时需要，其他命令不要求配置密钥
+func CheckJWTSecret() error {
+	if len(JWTSecret) < MinJWTSecretLength {
+		return fmt.Errorf("jwt.secret至少需要%d个字符，可以用环境变量THEATER_JWT_SECRET配置", MinJWTSecretLength)
+	}
+	return nil
+}
+
+// defaultConfig 返回带默认值的配置，数值配置的默认值由对应的Get函数处理
+func defaultConfig() Config {
+	var c Config
+	c.Server.Addr = DefaultAddr
+	c.Server.SocketMode = DefaultSocketMode
+	c.Server.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	c.Server.ReadTimeout = DefaultReadTimeout
+	c.Server.WriteTimeout = DefaultWriteTimeout
+	c.Server.IdleTimeout = DefaultIdleTimeout
+	c.Server.ShutdownTimeout = DefaultShutdownTimeout
+	c.Server.TLS.ACME.CacheDir = DefaultACMECacheDir
+	c.Log.Level = DefaultLogLevel
+	c.Log.Format = DefaultLogFormat
+	c.Log.SlowQuery = DefaultSlowQuery
+	c.Database.Driver = dialect.SQLite
+	c.Images.Dir = DefaultImageDir
+	c.Frontend.Enabled = true
+	c.TMDB.APIURL = DefaultTMDBAPIURL
+	c.TMDB.ImageURL = DefaultTMDBImageURL
+	c.TMDB.Language = DefaultTMDBLanguage
+	c.Security.CORS.AllowedMethods = DefaultCORSMethods
+	c.Security.CORS.AllowedHeaders = DefaultCORSHeaders
+	c.Security.CORS.ExposedHeaders = DefaultCORSExposedHeaders
+	c.Security.CORS.MaxAge = DefaultCORSMaxAge
+	c.Security.Headers.ContentSecurityPolicy = DefaultContentSecurityPolicy
+	c.Security.Headers.FrameOptions = DefaultFrameOptions
+	c.Security.Headers.ReferrerPolicy = DefaultReferrerPolicy
+	c.Security.Headers.HSTSMaxAge = DefaultHSTSMaxAge
+	c.Security.CSRF = true
+	c.RateLimit.Store = RateLimitMemory
+	c.RateLimit.IP = DefaultIPRateLimit
+	c.RateLimit.User = DefaultUserRateLimit
+	c.RateLimit.Login = DefaultLoginRateLimit
+	c.RateLimit.Register = DefaultRegisterRateLimit
+	c.RateLimit.Images = DefaultImagesRateLimit
+	c.RateLimit.Lockout.MaxFailures = DefaultLockoutMaxFailures
+	c.RateLimit.Lockout.UserMaxFailures = DefaultLockoutUserMaxFailures
+	c.RateLimit.Lockout.Duration = DefaultLockoutDuration
+	c.RateLimit.Lockout.MaxDuration = DefaultLockoutMaxDuration
+	c.Backup.Dir = DefaultBackupDir
+	return c
+}
+
+// Load 加载配置：默认值、配置文件、环境变量，然后校验。
+// path为空时读取DefaultConfigPath，该文件不存在时不报错；指定的文件不存在时返回错误
+func Load(path string) error {
+	c := defaultConfig()
+
+	explicit := path != ""
+	if !explicit {
+		path = DefaultConfigPath
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.UnmarshalStrict(data, &c); err != nil {
+			return fmt.Errorf("解析配置文件%s失败: %v", path, err)
+		}
+	case explicit || !os.IsNotExist(err):
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+
+	if err := applyEnv(envPrefix, reflect.ValueOf(&c).Elem()); err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	AppConfig = c
+	JWTSecret = c.JWT.Secret
+	return nil
+}
+
+// applyEnv 用环境变量覆盖配置，变量名为前缀加上大写的yaml字段名
+func applyEnv(prefix string, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := prefix + "_" + strings.ToUpper(strings.Split(field.Tag.Get("yaml"), ",")[0])
+		value := v.Field(i)
+
+		if value.Kind() == reflect.Struct {
+			if err := applyEnv(name, value); err != nil {
+				return err
+			}
+			continue
+		}
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if value.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(env)
+			if err != nil {
+				return fmt.Errorf("环境变量%s应为时间长度，如30s: %s", name, env)
+			}
+			value.SetInt(int64(d))
+			continue
+		}
+		switch value.Kind() {
+		case reflect.String:
+			value.SetString(env)
+		case reflect.Int:
+			n, err := strconv.Atoi(env)
+			if err != nil {
+				return fmt.Errorf("环境变量%s应为整数: %s", name, env)
+			}
+			value.SetInt(int64(n))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(env)
+			if err != nil {
+				return fmt.Errorf("环境变量%s应为true或false: %s", name, env)
+			}
+			value.SetBool(b)
+		case reflect.Slice:
+			var items []string
+			for _, item := range strings.Split(env, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			value.Set(reflect.ValueOf(items))
+		}
+	}
+	return nil
+}
+
+// Validate 校验配置，返回所有错误
+func (c *Config) Validate() error {
+	var errs []string
+	if c.Server.Addr == "" && c.Server.Socket == "" {
+		errs = append(errs, "server.addr和server.socket不能都为空")
+	}
+	if _, err := strconv.ParseUint(c.Server.SocketMode, 8, 32); err != nil {
+		errs = append(errs, fmt.Sprintf("server.socket_mode应为八进制权限，如0660: %q", c.Server.SocketMode))
+	}
+	for name, value := range map[string]time.Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.shutdown_timeout":    c.Server.ShutdownTimeout,
+	} {
+		if value < 0 {
+			errs = append(errs, name+"不能为负数")
+		}
+	}
+	tls := c.Server.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		errs = append(errs, "server.tls.cert_file和server.tls.key_file需要同时配置")
+	}
+	if len(tls.ACME.Domains) > 0 {
+		if tls.CertFile != "" {
+			errs = append(errs, "server.tls.acme不能与server.tls.cert_file同时使用")
+		}
+		if tls.ACME.CacheDir == "" {
+			errs = append(errs, "server.tls.acme.cache_dir不能为空")
+		}
+		if tls.ACME.DirectoryURL != "" {
+			if u, err := url.Parse(tls.ACME.DirectoryURL); err != nil || u.Scheme != "https" || u.Host == "" {
+				errs = append(errs, fmt.Sprintf("server.tls.acme.directory_url应为https地址: %q", tls.ACME.DirectoryURL))
+			}
+		}
+	} else if tls.ACME.Email != "" || tls.ACME.DirectoryURL != "" || tls.ACME.CAFile != "" || tls.ACME.HTTPAddr != "" {
+		errs = append(errs, "使用ACME时需要配置server.tls.acme.domains")
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, "log.level: "+err.Error())
+	}
+	if c.Log.Format != logging.FormatText && c.Log.Format != logging.FormatJSON {
+		errs = append(errs, fmt.Sprintf("log.format应为text或json: %q", c.Log.Format))
+	}
+	if c.Log.SlowQuery < 0 {
+		errs = append(errs, "log.slow_query不能为负数")
+	}
+	switch c.Database.Driver {
+	case dialect.SQLite:
+	case dialect.Postgres, dialect.MySQL:
+		if c.Database.DSN == "" {
+			errs = append(errs, fmt.Sprintf("使用%s时需要配置database.dsn", c.Database.Driver))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("database.driver不支持%q，可选 sqlite、postgres、mysql", c.Database.Driver))
+	}
+	if c.Images.Dir == "" {
+		errs = append(errs, "images.dir不能为空")
+	}
+	for name, value := range map[string]string{"tmdb.api_url": c.TMDB.APIURL, "tmdb.image_url": c.TMDB.ImageURL} {
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("%s应为http或https地址: %q", name, value))
+		}
+	}
+	if c.TMDB.Language == "" {
+		errs = append(errs, "tmdb.language不能为空")
+	}
+	if c.Sync.MaxPages < 0 {
+		errs = append(errs, "sync.max_pages不能为负数")
+	}
+	if c.Sync.PeopleRefreshDays < 0 {
+		errs = append(errs, "sync.people_refresh_days不能为负数")
+	}
+	if c.Backup.Dir == "" {
+		errs = append(errs, "backup.dir不能为空")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Sprintf("server.trusted_proxies应为IP地址或网段: %q", proxy))
+			}
+		}
+	}
+	cors := c.Security.CORS
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			if cors.AllowCredentials {
+				errs = append(errs, "security.cors.allowed_origins为*时不能开启allow_credentials")
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			errs = append(errs, fmt.Sprintf("security.cors.allowed_origins应为*或协议加域名，如https://example.com: %q", origin))
+		}
+	}
+	if cors.MaxAge < 0 {
+		errs = append(errs, "security.cors.max_age不能为负数")
+	}
+	if c.Security.Headers.HSTSMaxAge < 0 {
+		errs = append(errs, "security.headers.hsts_max_age不能为负数")
+	}
+	if c.RateLimit.Store != RateLimitMemory && c.RateLimit.Store != RateLimitDatabase {
+		errs = append(errs, fmt.Sprintf("rate_limit.store应为memory或database: %q", c.RateLimit.Store))
+	}
+	for name, bucket := range map[string]TokenBucket{
+		"rate_limit.ip":       c.RateLimit.IP,
+		"rate_limit.user":     c.RateLimit.User,
+		"rate_limit.login":    c.RateLimit.Login,
+		"rate_limit.register": c.RateLimit.Register,
+		"rate_limit.images":   c.RateLimit.Images,
+	} {
+		if bucket.Rate < 0 || bucket.Burst < 0 {
+			errs = append(errs, name+"的rate和burst不能为负数")
+		}
+		if bucket.Rate > 0 && (bucket.Period <= 0 || bucket.Burst == 0) {
+			errs = append(errs, name+"的period和burst需要大于0")
+		}
+	}
+	lockout := c.RateLimit.Lockout
+	if lockout.MaxFailures < 0 {
+		errs = append(errs, "rate_limit.lockout.max_failures不能为负数")
+	}
+	if lockout.UserMaxFailures < 0 {
+		errs = append(errs, "rate_limit.lockout.user_max_failures不能为负数")
+	}
+	if (lockout.MaxFailures > 0 || lockout.UserMaxFailures > 0) && (lockout.Duration <= 0 || lockout.MaxDuration < lockout.Duration) {
+		errs = append(errs, "rate_limit.lockout.duration需要大于0且不超过max_duration")
 	}
 
-	// 解析YAML
-	err = yaml.Unmarshal(yamlFile, &AppConfig)
-	if err != nil {
-		log.Fatalf("解析配置文件失败: %v", err)
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("配置错误:\n  %s", strings.Join(errs, "\n  "))
 	}
+	return nil
+}
+
+// GetServerAddr 获取服务监听地址
+func GetServerAddr() string {
+	return AppConfig.Server.Addr
+}
+
+// GetServerSocketMode 获取Unix socket文件权限
+func GetServerSocketMode() os.FileMode {
+	mode, _ := strconv.ParseUint(AppConfig.Server.SocketMode, 8, 32)
+	return os.FileMode(mode)
+}
+
+// GetImageDir 获取本地图片目录
+func GetImageDir() string {
+	return AppConfig.Images.Dir
+}
+
+// GetTMDBAPIURL 拼接TMDB API地址，path以/开头
+func GetTMDBAPIURL(path string) string {
+	return strings.TrimRight(AppConfig.TMDB.APIURL, "/") + path
+}
+
+// GetTMDBImageURL 拼接TMDB原图地址，filePath以/开头
+func GetTMDBImageURL(filePath string) string {
+	return strings.TrimRight(AppConfig.TMDB.ImageURL, "/") + filePath
+}
+
+// GetTMDBLanguage 获取请求TMDB数据的语言
+func GetTMDBLanguage() string {
+	return AppConfig.TMDB.Language
+}
+
+// GetSyncMaxPages 获取每次同步的热门电影页数
+func GetSyncMaxPages() int {
+	if AppConfig.Sync.MaxPages == 0 {
+		return DefaultSyncMaxPages
+	}
+	return AppConfig.Sync.MaxPages
 }
 
 // GetTMDBToken 从配置中获取TMDB API Token
@@ -44,11 +557,40 @@ func GetTMDBToken() (string, error) {
 	return AppConfig.TMDB.APIToken, nil
 }
 
-// GetJWTSecret 从配置中获取JWT签名密钥
-func GetJWTSecret() (string, error) {
-	if AppConfig.JWT.Secret == "" {
-		return "", fmt.Errorf("JWT签名密钥未配置")
+// GetSyncCreditLimits 获取每部电影同步的演员数量和每个部门的职员数量上限，负数表示不限制
+func GetSyncCreditLimits() (maxCast, maxCrew int) {
+	maxCast, maxCrew = AppConfig.Sync.MaxCast, AppConfig.Sync.MaxCrew
+	if maxCast == 0 {
+		maxCast = DefaultMaxCast
+	}
+	if maxCrew == 0 {
+		maxCrew = DefaultMaxCrew
+	}
+	return
+}
+
+// GetPeopleRefreshInterval 获取人物详情的刷新间隔
+func GetPeopleRefreshInterval() time.Duration {
+	days := AppConfig.Sync.PeopleRefreshDays
+	if days <= 0 {
+		days = DefaultPeopleRefreshDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GetBackupDir 获取备份目录
+func GetBackupDir() string {
+	return AppConfig.Backup.Dir
+}
+
+// GetBackupKeep 获取保留的备份数量，0表示不清理旧备份
+func GetBackupKeep() int {
+	keep := AppConfig.Backup.Keep
+	if keep == 0 {
+		return DefaultBackupKeep
+	}
+	if keep < 0 {
+		return 0
 	}
-	JWTSecret = AppConfig.JWT.Secret
-	return JWTSecret, nil
+	return keep
 }
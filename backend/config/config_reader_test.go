@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadJWTSecret(t *testing.T) {
+	t.Cleanup(func() {
+		AppConfig = defaultConfig()
+		JWTSecret = ""
+	})
+
+	// 不需要密钥的命令可以正常加载配置，启动服务前由CheckJWTSecret检查
+	for _, secret := range []string{"", "short"} {
+		t.Setenv("THEATER_JWT_SECRET", secret)
+		if err := Load(""); err != nil {
+			t.Errorf("密钥%q不应影响加载配置: %v", secret, err)
+		}
+		if err := CheckJWTSecret(); err == nil || !strings.Contains(err.Error(), "jwt.secret") {
+			t.Errorf("密钥%q应校验失败，实际为%v", secret, err)
+		}
+	}
+
+	secret := strings.Repeat("s", MinJWTSecretLength)
+	t.Setenv("THEATER_JWT_SECRET", secret)
+	if err := Load(""); err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if JWTSecret != secret {
+		t.Errorf("JWTSecret为%q，应为环境变量中的密钥", JWTSecret)
+	}
+	if err := CheckJWTSecret(); err != nil {
+		t.Errorf("密钥长度足够时不应校验失败: %v", err)
+	}
+}
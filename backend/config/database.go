@@ -1,38 +1,118 @@
 package config
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
+	"strings"
 
-	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+	"github.com/Estella0129/theater/backend/pkg/metrics"
+	"github.com/Estella0129/theater/backend/pkg/migrate"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
+// DBPath 未配置dsn时SQLite数据库文件的路径
+const DBPath = "theater.db"
+
+// GetDatabaseDriver 获取数据库驱动
+func GetDatabaseDriver() string {
+	return AppConfig.Database.Driver
+}
+
+// GetDatabaseDSN 获取数据库连接字符串，SQLite默认为DBPath
+func GetDatabaseDSN() string {
+	if AppConfig.Database.DSN == "" && GetDatabaseDriver() == dialect.SQLite {
+		return DBPath
+	}
+	return AppConfig.Database.DSN
+}
+
+// GetSQLitePath 获取SQLite数据库文件路径，未使用SQLite时返回错误
+func GetSQLitePath() (string, error) {
+	if driver := GetDatabaseDriver(); driver != dialect.SQLite {
+		return "", fmt.Errorf("当前数据库为%s，只有SQLite支持该操作", driver)
+	}
+	path := strings.TrimPrefix(GetDatabaseDSN(), "file:")
+	return strings.SplitN(path, "?", 2)[0], nil
+}
+
+// openDialector 根据配置的驱动创建gorm Dialector
+func openDialector() (gorm.Dialector, error) {
+	dsn := GetDatabaseDSN()
+	switch driver := GetDatabaseDriver(); driver {
+	case dialect.SQLite:
+		return sqlite.Open(dsn), nil
+	case dialect.Postgres, dialect.MySQL:
+		if dsn == "" {
+			return nil, fmt.Errorf("使用%s时需要配置database.dsn", driver)
+		}
+		if driver == dialect.Postgres {
+			return postgres.Open(dsn), nil
+		}
+		return mysql.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s，可选 sqlite、postgres、mysql", driver)
+	}
+}
+
+// 启动时对未执行的数据库迁移的处理方式
+const (
+	MigrationsRequire = "require" // 有未执行的迁移时退出
+	MigrationsApply   = "apply"   // 自动执行未执行的迁移
+	MigrationsIgnore  = "ignore"  // 不检查迁移，由migrate命令使用
+)
+
+// Migrations InitDB时对未执行迁移的处理方式，需要在InitDB前设置
+var Migrations = MigrationsRequire
+
 func InitDB() {
-	db, err := gorm.Open(sqlite.Open("theater.db"), &gorm.Config{Logger: logger.Default.LogMode(logger.Info)})
+	dialector, err := openDialector()
+	if err != nil {
+		logging.Fatal("连接数据库失败", "driver", GetDatabaseDriver(), "error", err)
+	}
+	// TranslateError将各数据库的唯一约束错误统一转换为gorm.ErrDuplicatedKey
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:         logging.GormLogger{SlowThreshold: AppConfig.Log.SlowQuery},
+		TranslateError: true,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-
-	// 自动迁移数据库表结构
-	log.Println("开始自动迁移数据库表结构...")
-	if err := db.AutoMigrate(
-		&models.MovieImage{},
-		&models.Movie{},
-		&models.User{},
-		&models.Genre{},
-		&models.MovieGenre{},
-		&models.Image{},
-		&models.People{},
-		&models.Credit{},
-	); err != nil {
-		log.Printf("自动迁移失败: %v\n", err)
-	} else {
-		log.Println("数据库表结构迁移成功")
+		logging.Fatal("连接数据库失败", "driver", GetDatabaseDriver(), "error", err)
+	}
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		logging.Fatal("注册数据库监控失败", "error", err)
+	}
+
+	switch Migrations {
+	case MigrationsApply:
+		migrate.Logger = slog.Default()
+		count, err := migrate.Up(db)
+		if err != nil {
+			logging.Fatal("数据库迁移失败", "error", err)
+		}
+		slog.Info("数据库迁移完成", "count", count)
+	case MigrationsRequire:
+		if err := migrate.Check(db); err != nil {
+			logging.Fatal("数据库结构不是最新", "error", err)
+		}
 	}
 
 	DB = db
 }
+
+// CloseDB 关闭数据库连接，SQLite会同时将WAL中的数据写回数据库文件
+func CloseDB() error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/backup"
+	"github.com/Estella0129/theater/backend/pkg/job"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBackup 在后台任务中备份数据库，images=true时包含图片目录
+func CreateBackup(c *gin.Context) {
+	includeImages := config.AppConfig.Backup.IncludeImages
+	if value, ok := c.GetQuery("images"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			apierror.Respond(c, apierror.Invalid().Wrap(err).WithField("images", "boolean", "must be a boolean", "images参数错误"))
+			return
+		}
+		includeImages = parsed
+	}
+
+	// gin.Context在请求结束后会被复用，不能在后台任务中使用
+	db := requestDB(c)
+	j := job.Start("backup", 1, func(j *job.Job) error {
+		path, err := backup.Create(db, backup.Options{
+			Dir:           config.GetBackupDir(),
+			IncludeImages: includeImages,
+			ImageDir:      config.GetImageDir(),
+			Keep:          config.GetBackupKeep(),
+		})
+		if err != nil && path == "" {
+			return err
+		}
+		result := job.Result{ID: filepath.Base(path), Status: job.ResultOK}
+		if err != nil {
+			// 备份已完成，只是清理旧备份失败
+			result.Message = err.Error()
+		}
+		j.Add(result)
+		return nil
+	})
+	respondJob(c, j)
+}
+
+// GetBackups 获取备份列表
+func GetBackups(c *gin.Context) {
+	backups, err := backup.List(config.GetBackupDir())
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to list backups", "获取备份列表失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": backups})
+}
+
+// DownloadBackup 下载备份文件
+func DownloadBackup(c *gin.Context) {
+	path, err := backup.Path(config.GetBackupDir(), c.Param("name"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid backup name", "备份文件名无效").Wrap(err))
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		apierror.Respond(c, apierror.NotFound("backup").Wrap(err))
+		return
+	}
+	c.FileAttachment(path, c.Param("name"))
+}
@@ -0,0 +1,607 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"github.com/Estella0129/theater/backend/pkg/job"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"github.com/Estella0129/theater/backend/pkg/sync"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// bulkInlineLimit 批量操作直接执行的最大数量，超过时在后台任务中分批执行
+const bulkInlineLimit = 100
+
+// validRoles 可以设置的用户角色
+var validRoles = map[string]bool{
+	"user":  true,
+	"admin": true,
+}
+
+// bulkSkip 单项数据无需处理的原因
+type bulkSkip string
+
+func (s bulkSkip) Error() string {
+	return string(s)
+}
+
+// bulkMovieFilter 批量操作电影时的筛选条件，与电影列表的筛选一致
+type bulkMovieFilter struct {
+	Query   string `json:"query"`
+	GenreID uint   `json:"genre_id"`
+}
+
+// bulkUserFilter 批量操作用户时的筛选条件
+type bulkUserFilter struct {
+	Query    string `json:"query"`
+	Role     string `json:"role"`
+	IsFrozen *bool  `json:"is_frozen"`
+}
+
+// bulkPeopleFilter 批量操作人物时的筛选条件
+type bulkPeopleFilter struct {
+	Query      string `json:"query"`
+	Department string `json:"department"` // known_for_department，如Acting、Directing
+}
+
+// empty 没有任何筛选条件
+func (f bulkMovieFilter) empty() bool {
+	return strings.TrimSpace(f.Query) == "" && f.GenreID == 0
+}
+
+// empty 没有任何筛选条件
+func (f bulkUserFilter) empty() bool {
+	return strings.TrimSpace(f.Query) == "" && f.Role == "" && f.IsFrozen == nil
+}
+
+// empty 没有任何筛选条件
+func (f bulkPeopleFilter) empty() bool {
+	return strings.TrimSpace(f.Query) == "" && f.Department == ""
+}
+
+// errBulkAll filter没有筛选条件时需要明确指定all，避免误操作全部数据
+const errBulkAll = bulkSkip("filter没有筛选条件，处理全部数据需要all为true")
+
+// bulkMovieRequest 批量操作电影的请求，ids和filter二选一，filter为空时需要all为true
+type bulkMovieRequest struct {
+	IDs    []uint           `json:"ids"`
+	Filter *bulkMovieFilter `json:"filter"`
+	All    bool             `json:"all"` // filter没有筛选条件时需要为true
+
+	GenreID uint   `json:"genre_id"` // 批量修改类型时使用
+	Action  string `json:"action"`   // 批量修改类型时使用，add或remove
+}
+
+// bulkUserRequest 批量操作用户的请求，ids和filter二选一，filter为空时需要all为true
+type bulkUserRequest struct {
+	IDs    []uint          `json:"ids"`
+	Filter *bulkUserFilter `json:"filter"`
+	All    bool            `json:"all"` // filter没有筛选条件时需要为true
+
+	Frozen *bool  `json:"frozen"` // 批量冻结时使用
+	Role   string `json:"role"`   // 批量修改角色时使用
+}
+
+// bulkPeopleRequest 批量操作人物的请求，ids和filter二选一，filter为空时需要all为true
+type bulkPeopleRequest struct {
+	IDs    []uint            `json:"ids"`
+	Filter *bulkPeopleFilter `json:"filter"`
+	All    bool              `json:"all"` // filter没有筛选条件时需要为true
+}
+
+// movieIDs 根据请求中的ids或filter得到要处理的电影ID，deleted为true时在回收站中筛选
+func (r bulkMovieRequest) movieIDs(deleted bool) ([]uint, error) {
+	if len(r.IDs) > 0 {
+		return uniqueIDs(r.IDs), nil
+	}
+	if r.Filter == nil && !r.All {
+		return nil, bulkSkip("请提供ids或filter")
+	}
+	if r.Filter == nil {
+		r.Filter = &bulkMovieFilter{}
+	}
+	if r.Filter.empty() && !r.All {
+		return nil, errBulkAll
+	}
+
+	dbQuery := config.DB.Model(&models.Movie{})
+	if deleted {
+		dbQuery = dbQuery.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if query := strings.TrimSpace(r.Filter.Query); query != "" {
+		dbQuery = dialect.Search(dbQuery, query, "title", "original_title")
+	}
+	if r.Filter.GenreID != 0 {
+		dbQuery = dbQuery.Where("id IN (?)", config.DB.Model(&models.MovieGenre{}).
+			Select("movie_id").Where("genre_id = ?", r.Filter.GenreID))
+	}
+
+	var ids []uint
+	err := dbQuery.Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// userIDs 根据请求中的ids或filter得到要处理的用户ID
+func (r bulkUserRequest) userIDs() ([]uint, error) {
+	if len(r.IDs) > 0 {
+		return uniqueIDs(r.IDs), nil
+	}
+	if r.Filter == nil && !r.All {
+		return nil, bulkSkip("请提供ids或filter")
+	}
+	if r.Filter == nil {
+		r.Filter = &bulkUserFilter{}
+	}
+	if r.Filter.empty() && !r.All {
+		return nil, errBulkAll
+	}
+
+	dbQuery := config.DB.Model(&models.User{})
+	if query := strings.TrimSpace(r.Filter.Query); query != "" {
+		dbQuery = dialect.Search(dbQuery, query, "username", "name", "email")
+	}
+	if r.Filter.Role != "" {
+		dbQuery = dbQuery.Where("role = ?", r.Filter.Role)
+	}
+	if r.Filter.IsFrozen != nil {
+		dbQuery = dbQuery.Where("is_frozen = ?", *r.Filter.IsFrozen)
+	}
+
+	var ids []uint
+	err := dbQuery.Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// peopleIDs 根据请求中的ids或filter得到要处理的人物ID，deleted为true时在回收站中筛选
+func (r bulkPeopleRequest) peopleIDs(deleted bool) ([]uint, error) {
+	if len(r.IDs) > 0 {
+		return uniqueIDs(r.IDs), nil
+	}
+	if r.Filter == nil && !r.All {
+		return nil, bulkSkip("请提供ids或filter")
+	}
+	if r.Filter == nil {
+		r.Filter = &bulkPeopleFilter{}
+	}
+	if r.Filter.empty() && !r.All {
+		return nil, errBulkAll
+	}
+
+	dbQuery := config.DB.Model(&models.People{})
+	if deleted {
+		dbQuery = dbQuery.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if query := strings.TrimSpace(r.Filter.Query); query != "" {
+		dbQuery = dialect.Search(dbQuery, query, "name", "original_name")
+	}
+	if r.Filter.Department != "" {
+		dbQuery = dbQuery.Where("known_for_department = ?", r.Filter.Department)
+	}
+
+	var ids []uint
+	err := dbQuery.Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// isSelf id是否是当前登录的用户，self为currentUserID的结果
+func isSelf(self string, id uint) bool {
+	return self != "" && self == strconv.FormatUint(uint64(id), 10)
+}
+
+// uniqueIDs 去掉重复的ID，保持原有顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// respondBulkIDsError 获取批量操作ID失败时的响应
+func respondBulkIDsError(c *gin.Context, err error) {
+	var skip bulkSkip
+	if errors.As(err, &skip) {
+		apierror.Respond(c, apierror.BadRequest("Invalid bulk request", skip.Error()))
+		return
+	}
+	apierror.Respond(c, apierror.Internal(err, "Failed to query bulk targets", "查询批量操作的数据失败"))
+}
+
+// runBulk 对每个ID执行op。数量不超过bulkInlineLimit时在一个事务中执行并直接返回结果，
+// 否则创建后台任务分批执行，每批一个事务，返回202和任务信息
+func runBulk(c *gin.Context, jobType string, ids []uint, op func(tx *gorm.DB, id uint) error) {
+	if len(ids) <= bulkInlineLimit {
+		results, err := runBulkBatch(ids, op)
+		if err != nil {
+			apierror.Respond(c, apierror.Internal(err, "Bulk operation failed", "批量操作失败"))
+			return
+		}
+		succeeded, skipped, failed := job.Count(results)
+		c.JSON(http.StatusOK, gin.H{
+			"total":     len(ids),
+			"succeeded": succeeded,
+			"skipped":   skipped,
+			"failed":    failed,
+			"results":   results,
+		})
+		return
+	}
+
+	j := job.Start(jobType, len(ids), func(j *job.Job) error {
+		for start := 0; start < len(ids); start += bulkInlineLimit {
+			if j.Context().Err() != nil {
+				return context.Cause(j.Context())
+			}
+			results, err := runBulkBatch(ids[start:min(start+bulkInlineLimit, len(ids))], op)
+			if err != nil {
+				return err
+			}
+			j.Add(results...)
+		}
+		return nil
+	})
+	respondJob(c, j)
+}
+
+// runBulkBatch 在一个事务中对每个ID执行op，每项使用嵌套事务，失败时只回滚该项
+func runBulkBatch(ids []uint, op func(tx *gorm.DB, id uint) error) ([]job.Result, error) {
+	results := make([]job.Result, 0, len(ids))
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range ids {
+			results = append(results, bulkResult(id, tx.Transaction(func(tx *gorm.DB) error {
+				return op(tx, id)
+			})))
+		}
+		return nil
+	})
+	return results, err
+}
+
+// bulkResult 将单项的执行结果转换为任务结果
+func bulkResult(id interface{}, err error) job.Result {
+	var skip bulkSkip
+	switch {
+	case err == nil:
+		return job.Result{ID: id, Status: job.ResultOK}
+	case errors.As(err, &skip):
+		return job.Result{ID: id, Status: job.ResultSkipped, Message: skip.Error()}
+	default:
+		return job.Result{ID: id, Status: job.ResultFailed, Error: err.Error()}
+	}
+}
+
+// respondJob 返回后台任务信息，Location指向任务查询地址
+func respondJob(c *gin.Context, j *job.Job) {
+	c.Header("Location", "/api/v1/admin/jobs/"+j.ID())
+	c.JSON(http.StatusAccepted, j.Info())
+}
+
+// GetJob 获取后台任务的进度和结果
+func GetJob(c *gin.Context) {
+	j, ok := job.Get(c.Param("id"))
+	if !ok {
+		apierror.Respond(c, apierror.NotFound("job"))
+		return
+	}
+	c.JSON(http.StatusOK, j.Info())
+}
+
+// BulkDeleteMovies 批量删除电影，电影移入回收站
+func BulkDeleteMovies(c *gin.Context) {
+	var req bulkMovieRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	ids, err := req.movieIDs(false)
+	if err != nil {
+		respondBulkIDsError(c, err)
+		return
+	}
+
+	actor := currentActor(c)
+	runBulk(c, "movies.delete", ids, func(tx *gorm.DB, id uint) error {
+		var movie models.Movie
+		if err := tx.First(&movie, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return bulkSkip("电影不存在或已删除")
+			}
+			return err
+		}
+		if err := tx.Delete(&movie).Error; err != nil {
+			return err
+		}
+		return revision.Record(tx, actor, revision.Movie, movie.ID, &movie, nil)
+	})
+}
+
+// BulkRestoreMovies 批量从回收站恢复电影
+func BulkRestoreMovies(c *gin.Context) {
+	var req bulkMovieRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	ids, err := req.movieIDs(true)
+	if err != nil {
+		respondBulkIDsError(c, err)
+		return
+	}
+
+	actor := currentActor(c)
+	runBulk(c, "movies.restore", ids, func(tx *gorm.DB, id uint) error {
+		err := restoreFromTrash(tx, trashEntities["movies"], fmt.Sprint(id), actor)
+		if err == errNotInTrash {
+			return bulkSkip(err.Error())
+		}
+		return err
+	})
+}
+
+// BulkUpdateMovieGenres 批量为电影添加或移除一个类型
+func BulkUpdateMovieGenres(c *gin.Context) {
+	var req bulkMovieRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if req.Action != "add" && req.Action != "remove" {
+		apierror.Respond(c, apierror.Invalid().WithField("action", "oneof", "must be one of: add remove", "action只能是add或remove"))
+		return
+	}
+	var genre models.Genre
+	if err := requestDB(c).First(&genre, req.GenreID).Error; err != nil {
+		apierror.Respond(c, apierror.Invalid().Wrap(err).WithField("genre_id", "exists", "genre does not exist", "电影类型不存在"))
+		return
+	}
+	ids, err := req.movieIDs(false)
+	if err != nil {
+		respondBulkIDsError(c, err)
+		return
+	}
+
+	actor := currentActor(c)
+	runBulk(c, "movies.genres."+req.Action, ids, func(tx *gorm.DB, id uint) error {
+		var movie models.Movie
+		if err := tx.Preload("Genres").First(&movie, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return bulkSkip("电影不存在或已删除")
+			}
+			return err
+		}
+
+		var result *gorm.DB
+		if req.Action == "add" {
+			result = tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.MovieGenre{MovieID: movie.ID, GenreID: uint(genre.ID)})
+		} else {
+			result = tx.Where("movie_id = ? AND genre_id = ?", movie.ID, genre.ID).Delete(&models.MovieGenre{})
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if req.Action == "add" {
+				return bulkSkip("电影已有该类型")
+			}
+			return bulkSkip("电影没有该类型")
+		}
+
+		// 类型属于电影的一部分，版本号加1使持有旧版本的编辑失效
+		if err := tx.Model(&models.Movie{ID: movie.ID}).UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+			return err
+		}
+		var after models.Movie
+		if err := tx.Preload("Genres").First(&after, id).Error; err != nil {
+			return err
+		}
+		return revision.Record(tx, actor, revision.Movie, movie.ID, &movie, &after)
+	})
+}
+
+// BulkResyncMovies 批量从TMDB重新同步电影，需要请求TMDB，总是在后台任务中执行
+func BulkResyncMovies(c *gin.Context) {
+	var req bulkMovieRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	ids, err := req.movieIDs(false)
+	if err != nil {
+		respondBulkIDsError(c, err)
+		return
+	}
+
+	movieIDs := make([]int, 0, len(ids))
+	for _, id := range ids {
+		movieIDs = append(movieIDs, int(id))
+	}
+
+	j := job.Start("movies.resync", len(movieIDs), func(j *job.Job) error {
+		return sync.ResyncMovies(j.Context(), "sync:"+j.ID(), movieIDs, func(movieID int, err error) {
+			if err == sync.ErrMovieDeleted {
+				err = bulkSkip(err.Error())
+			}
+			j.Add(bulkResult(movieID, err))
+		})
+	})
+	respondJob(c, j)
+}
+
+// BulkDeletePeople 批量删除人物，人物移入回收站
+func BulkDeletePeople(c *gin.Context) {
+	var req bulkPeopleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	ids, err := req.peopleIDs(false)
+	if err != nil {
+		respondBulkIDsError(c, err)
+		return
+	}
+
+	actor := currentActor(c)
+	runBulk(c, "people.delete", ids, func(tx *gorm.DB, id uint) error {
+		var people models.People
+		if err := tx.First(&people, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return bulkSkip("人物不存在或已删除")
+			}
+			return err
+		}
+		if err := tx.Delete(&people).Error; err != nil {
+			return err
+		}
+		return revision.Record(tx, actor, revision.People, people.ID, &people, nil)
+	})
+}
+
+// BulkRestorePeople 批量从回收站恢复人物
+func BulkRestorePeople(c *gin.Context) {
+	var req bulkPeopleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	ids, err := req.peopleIDs(true)
+	if err != nil {
+		respondBulkIDsError(c, err)
+		return
+	}
+
+	actor := currentActor(c)
+	runBulk(c, "people.restore", ids, func(tx *gorm.DB, id uint) error {
+		err := restoreFromTrash(tx, trashEntities["people"], fmt.Sprint(id), actor)
+		if err == errNotInTrash {
+			return bulkSkip(err.Error())
+		}
+		return err
+	})
+}
+
+// BulkResyncPeople 批量从TMDB重新同步人物详情，需要请求TMDB，总是在后台任务中执行
+func BulkResyncPeople(c *gin.Context) {
+	var req bulkPeopleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	ids, err := req.peopleIDs(false)
+	if err != nil {
+		respondBulkIDsError(c, err)
+		return
+	}
+
+	peopleIDs := make([]int, 0, len(ids))
+	for _, id := range ids {
+		peopleIDs = append(peopleIDs, int(id))
+	}
+
+	j := job.Start("people.resync", len(peopleIDs), func(j *job.Job) error {
+		return sync.ResyncPeople(j.Context(), "sync:"+j.ID(), peopleIDs, func(peopleID int, err error) {
+			switch {
+			case err == sync.ErrPeopleDeleted:
+				err = bulkSkip(err.Error())
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				err = bulkSkip("人物不存在")
+			}
+			j.Add(bulkResult(peopleID, err))
+		})
+	})
+	respondJob(c, j)
+}
+
+// BulkFreezeUsers 批量冻结或解冻用户
+func BulkFreezeUsers(c *gin.Context) {
+	var req bulkUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if req.Frozen == nil {
+		apierror.Respond(c, apierror.Invalid().WithField("frozen", "required", "is required", "请求参数错误，需要frozen"))
+		return
+	}
+	ids, err := req.userIDs()
+	if err != nil {
+		respondBulkIDsError(c, err)
+		return
+	}
+
+	frozen := *req.Frozen
+	self := currentUserID(c)
+	runBulk(c, "users.freeze", ids, func(tx *gorm.DB, id uint) error {
+		if isSelf(self, id) {
+			return bulkSkip("不能冻结或解冻自己的账号")
+		}
+		var user models.User
+		if err := tx.Select("id", "is_frozen").First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return bulkSkip("用户不存在")
+			}
+			return err
+		}
+		if user.IsFrozen == frozen {
+			return bulkSkip("用户状态无需修改")
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"is_frozen": frozen,
+			"version":   gorm.Expr("version + 1"),
+		}).Error
+	})
+}
+
+// BulkUpdateUserRoles 批量修改用户角色
+func BulkUpdateUserRoles(c *gin.Context) {
+	var req bulkUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if !validRoles[req.Role] {
+		apierror.Respond(c, apierror.Invalid().WithField("role", "oneof", "must be one of: user admin", "role只能是user或admin"))
+		return
+	}
+	ids, err := req.userIDs()
+	if err != nil {
+		respondBulkIDsError(c, err)
+		return
+	}
+
+	self := currentUserID(c)
+	runBulk(c, "users.role", ids, func(tx *gorm.DB, id uint) error {
+		if isSelf(self, id) {
+			return bulkSkip("不能修改自己的角色")
+		}
+		var user models.User
+		if err := tx.Select("id", "role").First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return bulkSkip("用户不存在")
+			}
+			return err
+		}
+		if user.Role == req.Role {
+			return bulkSkip("用户角色无需修改")
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"role":    req.Role,
+			"version": gorm.Expr("version + 1"),
+		}).Error
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/catalog"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportCatalog 导出电影、人物、类型或合集，数据边查询边写出
+func ExportCatalog(c *gin.Context) {
+	entity := c.Param("entity")
+	format := c.DefaultQuery("format", catalog.FormatJSON)
+	if err := catalog.Valid(entity, format); err != nil {
+		apierror.Respond(c, apierror.BadRequest("Unsupported entity or format", err.Error()).Wrap(err))
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", entity, time.Now().Format("20060102150405"), format)
+	c.Header("Content-Type", catalog.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// 已开始写出数据，出错时无法再修改状态码，只记录日志
+	if _, err := catalog.Export(requestDB(c), c.Writer, entity, format); err != nil {
+		slog.ErrorContext(c.Request.Context(), "导出失败", "entity", entity, "error", err)
+	}
+}
+
+// ImportCatalog 导入电影、人物、类型或合集并返回导入报告。
+// 数据可以是请求体，也可以是表单中的file文件，未指定format时根据文件名或Content-Type判断
+func ImportCatalog(c *gin.Context) {
+	entity := c.Param("entity")
+	format := c.Query("format")
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			respondBindError(c, err)
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			apierror.Respond(c, apierror.Internal(err, "Failed to read import file", "读取导入文件失败"))
+			return
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = catalog.FormatFromPath(fileHeader.Filename)
+		}
+	}
+	if format == "" {
+		format = formatFromContentType(c.ContentType())
+	}
+	if err := catalog.Valid(entity, format); err != nil {
+		apierror.Respond(c, apierror.BadRequest("Unsupported entity or format", err.Error()).Wrap(err))
+		return
+	}
+
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		apierror.Respond(c, apierror.Invalid().Wrap(err).WithField("dry_run", "boolean", "must be a boolean", "dry_run参数错误"))
+		return
+	}
+	report, err := catalog.Import(requestDB(c), body, entity, catalog.Options{
+		Format: format,
+		Mode:   c.DefaultQuery("mode", catalog.ModeID),
+		DryRun: dryRun,
+		Actor:  currentActor(c),
+	})
+	if err != nil {
+		apiErr := apierror.BadRequest("Invalid import data", err.Error()).Wrap(err)
+		if report != nil {
+			apiErr.With("report", report)
+		}
+		apierror.Respond(c, apiErr)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// formatFromContentType 根据Content-Type判断导入格式，默认为JSON
+func formatFromContentType(contentType string) string {
+	switch contentType {
+	case "text/csv":
+		return catalog.FormatCSV
+	case "application/x-ndjson", "application/jsonl":
+		return catalog.FormatNDJSON
+	}
+	return catalog.FormatJSON
+}
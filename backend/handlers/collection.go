@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetCollections 获取合集列表，支持分页和搜索
+func GetCollections(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+
+	searchQuery := strings.TrimSpace(c.Query("query"))
+
+	var collections []models.Collection
+	var total int64
+
+	offset := (page - 1) * pageSize
+
+	dbQuery := requestDB(c).Model(&models.Collection{})
+	if searchQuery != "" {
+		dbQuery = dialect.Search(dbQuery, searchQuery, "name")
+	}
+
+	// 获取总记录数
+	if err := dbQuery.Count(&total).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to count collections", "获取合集总数失败"))
+		return
+	}
+
+	// 获取分页数据
+	if err := dbQuery.Order("id ASC").Offset(offset).Limit(pageSize).Find(&collections).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch collections", "获取合集列表失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"page":        page,
+		"page_size":   pageSize,
+		"total":       total,
+		"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		"results":     collections,
+	})
+}
+
+// GetCollection 获取合集详情，合集中的电影按上映日期排序
+func GetCollection(c *gin.Context) {
+	id := c.Param("id")
+
+	var collection models.Collection
+	result := requestDB(c).
+		Preload("Movies", func(db *gorm.DB) *gorm.DB {
+			return db.Order("release_date ASC")
+		}).
+		Preload("Images").First(&collection, id)
+	if result.Error != nil {
+		respondFindError(c, result.Error, "collection")
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// collectionData 管理后台创建和更新合集的请求数据
+type collectionData struct {
+	Name         string  `json:"name" binding:"required"`
+	Overview     string  `json:"overview"`
+	PosterPath   string  `json:"poster_path"`
+	BackdropPath string  `json:"backdrop_path"`
+	MovieIDs     *[]uint `json:"movie_ids"` // 为空时不修改合集中的电影
+}
+
+// CreateCollection 创建自建合集
+func CreateCollection(c *gin.Context) {
+	var data collectionData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	collection := models.Collection{
+		Name:         data.Name,
+		Overview:     data.Overview,
+		PosterPath:   data.PosterPath,
+		BackdropPath: data.BackdropPath,
+	}
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&collection).Error; err != nil {
+			return err
+		}
+		if data.MovieIDs != nil {
+			if err := replaceCollectionMovies(tx, &collection, *data.MovieIDs); err != nil {
+				return err
+			}
+		}
+		return revision.Record(tx, currentActor(c), revision.Collection, collection.ID, nil, &collection)
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to create collection", "创建合集失败"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, collection)
+}
+
+// UpdateCollection 更新合集信息
+func UpdateCollection(c *gin.Context) {
+	id := c.Param("id")
+
+	var collection models.Collection
+	if err := requestDB(c).First(&collection, id).Error; err != nil {
+		respondFindError(c, err, "collection")
+		return
+	}
+
+	var data collectionData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	before := collection
+	collection.Name = data.Name
+	collection.Overview = data.Overview
+	collection.PosterPath = data.PosterPath
+	collection.BackdropPath = data.BackdropPath
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(&collection).Error; err != nil {
+			return err
+		}
+		if data.MovieIDs != nil {
+			if err := replaceCollectionMovies(tx, &collection, *data.MovieIDs); err != nil {
+				return err
+			}
+		}
+		return recordEdit(tx, c, revision.Collection, collection.ID, &before, &collection)
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to update collection", "更新合集失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// replaceCollectionMovies 用movieIDs替换合集中的电影
+func replaceCollectionMovies(tx *gorm.DB, collection *models.Collection, movieIDs []uint) error {
+	var movies []models.Movie
+	if len(movieIDs) > 0 {
+		if err := tx.Where("id IN ?", movieIDs).Find(&movies).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Omit("Movies.*").Model(collection).Association("Movies").Replace(movies); err != nil {
+		return err
+	}
+	collection.Movies = movies
+	return nil
+}
+
+// DeleteCollection 删除合集，合集移入回收站，电影和图片关联保留以便恢复
+func DeleteCollection(c *gin.Context) {
+	id := c.Param("id")
+
+	var collection models.Collection
+	if err := requestDB(c).First(&collection, id).Error; err != nil {
+		respondFindError(c, err, "collection")
+		return
+	}
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&collection).Error; err != nil {
+			return err
+		}
+		return revision.Record(tx, currentActor(c), revision.Collection, collection.ID, &collection, nil)
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to delete collection", "删除合集失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "合集删除成功"})
+}
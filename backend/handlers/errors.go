@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// respondFindError 查询单条记录失败时写入错误响应，记录不存在返回404，其他错误返回500
+func respondFindError(c *gin.Context, err error, resource string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Respond(c, apierror.NotFound(resource).Wrap(err))
+		return
+	}
+	apierror.Respond(c, err)
+}
+
+// respondBindError 请求体绑定或校验失败时返回400，校验失败时带有字段错误
+func respondBindError(c *gin.Context, err error) {
+	apierror.Respond(c, apierror.Bind(err))
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// versionETag 由版本号生成ETag
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag 在响应头中写入当前版本的ETag
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", versionETag(version))
+}
+
+// checkIfMatch 校验If-Match请求头与当前版本是否一致，不一致时写入错误响应并返回false
+func checkIfMatch(c *gin.Context, version int) bool {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" {
+		setETag(c, version)
+		apierror.Respond(c, apierror.New(http.StatusPreconditionRequired, apierror.CodePreconditionRequired,
+			"If-Match header is required, fetch the latest version first", "缺少If-Match请求头，请先获取最新数据").
+			With("version", version))
+		return false
+	}
+	if ifMatch == "*" {
+		return true
+	}
+
+	current := versionETag(version)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == current {
+			return true
+		}
+	}
+
+	respondVersionConflict(c, version)
+	return false
+}
+
+// respondVersionConflict 返回412，告知客户端数据已被修改及当前版本
+func respondVersionConflict(c *gin.Context, version int) {
+	setETag(c, version)
+	apierror.Respond(c, apierror.New(http.StatusPreconditionFailed, apierror.CodePreconditionFailed,
+		"Resource has been modified by someone else, refresh and try again", "数据已被其他人修改，请刷新后重试").
+		With("version", version))
+}
+
+// respondStaleWrite 条件更新未命中时重新读取当前版本并返回412
+func respondStaleWrite(c *gin.Context, model interface{}, id interface{}) {
+	var version int
+	requestDB(c).Model(model).Where("id = ?", id).Select("version").Scan(&version)
+	respondVersionConflict(c, version)
+}
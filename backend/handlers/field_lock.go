@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/fieldlock"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordEdit 记录编辑后台的修改，并锁定被修改的字段，避免之后被TMDB同步覆盖
+func recordEdit(tx *gorm.DB, c *gin.Context, entityType string, entityID interface{}, before, after interface{}) error {
+	if err := revision.Record(tx, currentActor(c), entityType, entityID, before, after); err != nil {
+		return err
+	}
+	return fieldlock.LockChanged(tx, currentActor(c), entityType, entityID, before, after)
+}
+
+// GetFieldLocks 返回获取实体字段锁的处理函数，同时返回可锁定的字段和同步时跳过的冲突
+func GetFieldLocks(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		lockable := fieldlock.Lockable(revisionEntities[entityType].newModel())
+		fields := make([]string, 0, len(lockable))
+		for field := range lockable {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		var locks []models.FieldLock
+		if err := requestDB(c).Where("entity_type = ? AND entity_id = ?", entityType, id).Order("field").Find(&locks).Error; err != nil {
+			apierror.Respond(c, apierror.Internal(err, "Failed to fetch field locks", "获取字段锁失败"))
+			return
+		}
+		var conflicts []models.SyncConflict
+		if err := requestDB(c).Where("entity_type = ? AND entity_id = ?", entityType, id).Order("field").Find(&conflicts).Error; err != nil {
+			apierror.Respond(c, apierror.Internal(err, "Failed to fetch sync conflicts", "获取同步冲突失败"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"lockable":  fields,
+			"locks":     locks,
+			"conflicts": conflicts,
+		})
+	}
+}
+
+// lockFieldsRequest 锁定字段的请求数据
+type lockFieldsRequest struct {
+	Fields []string `json:"fields" binding:"required,min=1"`
+}
+
+// LockFields 返回锁定实体字段的处理函数
+func LockFields(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		var data lockFieldsRequest
+		if err := c.ShouldBindJSON(&data); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		entity := revisionEntities[entityType].newModel()
+		if err := requestDB(c).First(entity, "id = ?", id).Error; err != nil {
+			respondFindError(c, err, entityType)
+			return
+		}
+
+		lockable := fieldlock.Lockable(entity)
+		for _, field := range data.Fields {
+			if !lockable[field] {
+				apierror.Respond(c, apierror.Invalid().WithField("fields", "lockable", field+" cannot be locked", "字段不能锁定: "+field))
+				return
+			}
+		}
+
+		if err := fieldlock.Lock(requestDB(c), currentActor(c), entityType, id, data.Fields); err != nil {
+			apierror.Respond(c, apierror.Internal(err, "Failed to lock fields", "锁定字段失败"))
+			return
+		}
+
+		var locks []models.FieldLock
+		requestDB(c).Where("entity_type = ? AND entity_id = ?", entityType, id).Order("field").Find(&locks)
+		c.JSON(http.StatusOK, gin.H{"locks": locks})
+	}
+}
+
+// UnlockField 返回解除字段锁的处理函数，解锁后下次同步将使用TMDB的值
+func UnlockField(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		field := c.Param("field")
+
+		deleted, err := fieldlock.Unlock(requestDB(c), entityType, id, []string{field})
+		if err != nil {
+			apierror.Respond(c, apierror.Internal(err, "Failed to unlock field", "解除字段锁失败"))
+			return
+		}
+		if deleted == 0 {
+			apierror.Respond(c, apierror.NotFound("field_lock"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "字段锁已解除"})
+	}
+}
+
+// GetSyncConflicts 获取同步时因字段锁定而跳过的修改，可按实体类型和ID筛选
+func GetSyncConflicts(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+
+	dbQuery := requestDB(c).Model(&models.SyncConflict{})
+	if entityType := c.Query("entity_type"); entityType != "" {
+		dbQuery = dbQuery.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		dbQuery = dbQuery.Where("entity_id = ?", entityID)
+	}
+
+	var total int64
+	if err := dbQuery.Count(&total).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to count sync conflicts", "获取同步冲突总数失败"))
+		return
+	}
+	var conflicts []models.SyncConflict
+	if err := dbQuery.Order("updated_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&conflicts).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch sync conflicts", "获取同步冲突失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"page":        page,
+		"page_size":   pageSize,
+		"total":       total,
+		"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		"results":     conflicts,
+	})
+}
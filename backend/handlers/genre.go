@@ -3,16 +3,18 @@ package handlers
 import (
 	"net/http"
 
-	"github.com/Estella0129/theater/backend/config"
 	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/revision"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetGenres 获取所有电影类型
 func GetGenres(c *gin.Context) {
 	var genres []models.Genre
-	if err := config.DB.Find(&genres).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取电影类型失败"})
+	if err := requestDB(c).Find(&genres).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch genres", "获取电影类型失败"))
 		return
 	}
 
@@ -23,12 +25,18 @@ func GetGenres(c *gin.Context) {
 func CreateGenre(c *gin.Context) {
 	var newGenre models.Genre
 	if err := c.ShouldBindJSON(&newGenre); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数解析失败"})
+		respondBindError(c, err)
 		return
 	}
 
-	if err := config.DB.Create(&newGenre).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建电影类型失败"})
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newGenre).Error; err != nil {
+			return err
+		}
+		return revision.Record(tx, currentActor(c), revision.Genre, newGenre.ID, nil, &newGenre)
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to create genre", "创建电影类型失败"))
 		return
 	}
 
@@ -39,36 +47,75 @@ func CreateGenre(c *gin.Context) {
 func UpdateGenre(c *gin.Context) {
 	id := c.Param("id")
 	var genre models.Genre
-	if err := config.DB.First(&genre, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "电影类型未找到"})
+	if err := requestDB(c).First(&genre, id).Error; err != nil {
+		respondFindError(c, err, "genre")
+		return
+	}
+	if !checkIfMatch(c, genre.Version) {
 		return
 	}
 
 	var updatedGenre models.Genre
 	if err := c.ShouldBindJSON(&updatedGenre); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数解析失败"})
+		respondBindError(c, err)
 		return
 	}
 
-	if err := config.DB.Model(&genre).Updates(updatedGenre).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新电影类型失败"})
+	before := genre
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		// 只有版本号未变时才更新，避免覆盖其他人的修改
+		result := tx.Model(&genre).Where("version = ?", before.Version).
+			Updates(models.Genre{Name: updatedGenre.Name, Version: before.Version + 1})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStaleVersion
+		}
+		return recordEdit(tx, c, revision.Genre, genre.ID, &before, &genre)
+	})
+	if err == errStaleVersion {
+		respondStaleWrite(c, &models.Genre{}, genre.ID)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to update genre", "更新电影类型失败"))
 		return
 	}
 
+	setETag(c, genre.Version)
 	c.JSON(http.StatusOK, genre)
 }
 
-// DeleteGenre 删除电影类型
+// DeleteGenre 删除电影类型，类型移入回收站，与电影的关联保留以便恢复
 func DeleteGenre(c *gin.Context) {
 	id := c.Param("id")
 	var genre models.Genre
-	if err := config.DB.First(&genre, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "电影类型未找到"})
+	if err := requestDB(c).First(&genre, id).Error; err != nil {
+		respondFindError(c, err, "genre")
 		return
 	}
 
-	if err := config.DB.Delete(&genre).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除电影类型失败"})
+	if !checkIfMatch(c, genre.Version) {
+		return
+	}
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("version = ?", genre.Version).Delete(&genre)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStaleVersion
+		}
+		return revision.Record(tx, currentActor(c), revision.Genre, genre.ID, &genre, nil)
+	})
+	if err == errStaleVersion {
+		respondStaleWrite(c, &models.Genre{}, genre.ID)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to delete genre", "删除电影类型失败"))
 		return
 	}
 
@@ -78,10 +125,23 @@ func DeleteGenre(c *gin.Context) {
 // GetAdminGenres 获取管理员可见的所有电影类型
 func GetAdminGenres(c *gin.Context) {
 	var genres []models.Genre
-	if err := config.DB.Find(&genres).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取管理员可见电影类型失败"})
+	if err := requestDB(c).Find(&genres).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch genres", "获取管理员可见电影类型失败"))
 		return
 	}
 
 	c.JSON(http.StatusOK, genres)
 }
+
+// GetAdminGenre 获取单个电影类型（管理后台），响应头中带有当前版本的ETag
+func GetAdminGenre(c *gin.Context) {
+	id := c.Param("id")
+	var genre models.Genre
+	if err := requestDB(c).First(&genre, id).Error; err != nil {
+		respondFindError(c, err, "genre")
+		return
+	}
+
+	setETag(c, genre.Version)
+	c.JSON(http.StatusOK, genre)
+}
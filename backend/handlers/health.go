@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout 就绪检查中每项检查的超时时间
+const readyTimeout = 2 * time.Second
+
+// Healthz 存活检查，进程能处理请求即返回200
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz 就绪检查，检查数据库连接和图片目录是否可写，任一项失败时返回503
+func Readyz(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+	for name, check := range map[string]func(ctx context.Context) error{
+		"database": checkDatabase,
+		"images":   checkImageDir,
+	} {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+		err := check(ctx)
+		cancel()
+		if err != nil {
+			ready = false
+			checks[name] = err.Error()
+			continue
+		}
+		checks[name] = "ok"
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+}
+
+func checkDatabase(ctx context.Context) error {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkImageDir 图片目录需要可写，代理TMDB图片和上传图片时都会写入
+func checkImageDir(ctx context.Context) error {
+	dir := config.GetImageDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建图片目录失败: %v", err)
+	}
+	f, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return fmt.Errorf("图片目录不可写: %v", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
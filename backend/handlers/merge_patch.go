@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+)
+
+// mergePatchContentType JSON Merge Patch (RFC 7396) 请求的Content-Type
+const mergePatchContentType = "application/merge-patch+json"
+
+// isMergePatch 判断请求体是否为JSON Merge Patch，普通JSON同样按Merge Patch处理
+func isMergePatch(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == mergePatchContentType || mediaType == "application/json"
+}
+
+// applyMergePatch 按RFC 7396将patch合并到target上，返回合并后的JSON
+func applyMergePatch(target, patch []byte) ([]byte, error) {
+	var targetValue, patchValue interface{}
+	if err := json.Unmarshal(target, &targetValue); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &patchValue); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatchValue(targetValue, patchValue))
+}
+
+func mergePatchValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatchValue(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// patchKeys 返回patch中顶层出现的字段名
+func patchKeys(patch []byte) (map[string]bool, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &object); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(object))
+	for key := range object {
+		keys[key] = true
+	}
+	return keys, nil
+}
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,7 +19,12 @@ import (
 
 	"github.com/Estella0129/theater/backend/config"
 	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"github.com/Estella0129/theater/backend/pkg/revision"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetMovies 获取电影列表，支持分页和搜索
@@ -38,9 +48,9 @@ func GetMovies(c *gin.Context) {
 
 	offset := (page - 1) * pageSize
 
-	dbQuery := config.DB.Model(&models.Movie{})
+	dbQuery := requestDB(c).Model(&models.Movie{})
 	if searchQuery != "" {
-		dbQuery = dbQuery.Where("title LIKE ? OR original_title LIKE ?", "%"+searchQuery+"%", "%"+searchQuery+"%")
+		dbQuery = dialect.Search(dbQuery, searchQuery, "title", "original_title")
 	}
 	if genre != "" {
 		dbQuery = dbQuery.Joins(
@@ -51,14 +61,14 @@ func GetMovies(c *gin.Context) {
 
 	// 获取总记录数
 	if err := dbQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取电影总数失败"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to count movies", "获取电影总数失败"))
 		return
 	}
 
 	dbQuery.Preload("Director", "job = ?", "Director").Preload("Director.People")
 	// 获取分页数据
 	if err := dbQuery.Offset(offset).Limit(pageSize).Find(&movies).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取电影列表失败"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch movies", "获取电影列表失败"))
 		return
 	}
 
@@ -82,34 +92,106 @@ func GetMovie(c *gin.Context) {
 	id := c.Param("id")
 
 	var movie models.Movie
-	result := config.DB.
-		Preload("Credits").
-		Preload("Credits.People").
+	result := requestDB(c).
+		Preload("Cast", func(db *gorm.DB) *gorm.DB {
+			return db.Scopes(activePeopleCredits).Where("credit_type = ?", "cast").Order(creditOrder)
+		}).
+		Preload("Cast.People").
 		Preload("Genres").
 		Preload("Images").First(&movie, id)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+		respondFindError(c, result.Error, "movie")
 		return
 	}
 
+	var crew []models.Credit
+	if err := requestDB(c).Preload("People").Scopes(activePeopleCredits).
+		Where("movie_id = ? AND credit_type = ?", movie.ID, "crew").
+		Order(creditOrder).Find(&crew).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch crew", "获取职员列表失败"))
+		return
+	}
+	movie.Crew = groupCrewByDepartment(crew)
+
 	c.JSON(http.StatusOK, movie)
 }
 
+// creditOrder 按演职人员的order列排序，order是保留字，由gorm按数据库加引号
+var creditOrder = clause.OrderByColumn{Column: clause.Column{Name: "order"}}
+
+// activePeopleCredits 只保留人物未被删除的演职人员记录，回收站中人物的记录保留以便恢复
+func activePeopleCredits(db *gorm.DB) *gorm.DB {
+	return db.Where("people_id IN (?)", config.DB.Model(&models.People{}).Select("id"))
+}
+
+// departmentOrder 演职人员部门的展示顺序，未列出的部门按名称排在后面
+var departmentOrder = map[string]int{
+	"Acting":            0,
+	"Directing":         1,
+	"Writing":           2,
+	"Production":        3,
+	"Camera":            4,
+	"Editing":           5,
+	"Sound":             6,
+	"Art":               7,
+	"Costume & Make-Up": 8,
+	"Visual Effects":    9,
+	"Lighting":          10,
+	"Crew":              11,
+}
+
+// lessDepartment 按departmentOrder比较两个部门的展示顺序
+func lessDepartment(a, b string) bool {
+	oa, aKnown := departmentOrder[a]
+	ob, bKnown := departmentOrder[b]
+	if aKnown != bKnown {
+		return aKnown
+	}
+	if aKnown {
+		return oa < ob
+	}
+	return a < b
+}
+
+// groupCrewByDepartment 将已按部门内顺序排好的职员按部门分组
+func groupCrewByDepartment(crew []models.Credit) []models.CrewDepartment {
+	groups := []models.CrewDepartment{}
+	index := map[string]int{}
+	for _, credit := range crew {
+		i, ok := index[credit.Department]
+		if !ok {
+			i = len(groups)
+			index[credit.Department] = i
+			groups = append(groups, models.CrewDepartment{Department: credit.Department})
+		}
+		groups[i].Crew = append(groups[i].Crew, credit)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return lessDepartment(groups[i].Department, groups[j].Department)
+	})
+	return groups
+}
+
 // CreateMovie 创建电影
 func CreateMovie(c *gin.Context) {
 	var movie models.Movie
 
 	if err := c.ShouldBindJSON(&movie); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+		respondBindError(c, err)
+		return
+	}
+	if err := checkCreditIDs(movie.Credits); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
-	tx := config.DB.Begin()
+	tx := requestDB(c).Begin()
 
 	// 创建电影主体
 	if err := tx.Create(&movie).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建电影失败"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to create movie", "创建电影失败"))
 		return
 	}
 
@@ -117,7 +199,7 @@ func CreateMovie(c *gin.Context) {
 	if len(movie.Genres) > 0 {
 		if err := tx.Model(&movie).Association("Genres").Append(movie.Genres); err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "关联电影类型失败"})
+			apierror.Respond(c, apierror.Internal(err, "Failed to save movie genres", "关联电影类型失败"))
 			return
 		}
 	}
@@ -128,13 +210,17 @@ func CreateMovie(c *gin.Context) {
 		movieID, err := strconv.ParseUint(strconv.FormatUint(uint64(movie.ID), 10), 10, 64)
 		if err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的Movie ID格式"})
+			apierror.Respond(c, apierror.Internal(err, "Invalid movie ID", "无效的Movie ID格式"))
 			return
 		}
 		credit.MovieID = int(movieID)
 		if err := tx.Create(&credit).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存演职人员失败"})
+			if dialect.IsDuplicateKey(err) {
+				apierror.Respond(c, errDuplicateCredit)
+			} else {
+				apierror.Respond(c, apierror.Internal(err, "Failed to save credits", "保存演职人员失败"))
+			}
 			return
 		}
 	}
@@ -144,7 +230,7 @@ func CreateMovie(c *gin.Context) {
 		// 创建图片记录
 		if err := tx.Create(&image).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存图片失败"})
+			apierror.Respond(c, apierror.Internal(err, "Failed to save images", "保存图片失败"))
 			return
 		}
 		// 创建电影-图片关联
@@ -154,120 +240,335 @@ func CreateMovie(c *gin.Context) {
 		}
 		if err := tx.Create(&movieImage).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "关联图片失败"})
+			apierror.Respond(c, apierror.Internal(err, "Failed to save movie images", "关联图片失败"))
 			return
 		}
 	}
 
-	tx.Commit()
+	if err := revision.Record(tx, currentActor(c), revision.Movie, movie.ID, nil, &movie); err != nil {
+		tx.Rollback()
+		apierror.Respond(c, apierror.Internal(err, "Failed to record revision", "记录修改历史失败"))
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to create movie", "创建电影失败"))
+		return
+	}
 	c.JSON(http.StatusCreated, movie)
 }
 
-// UpdateMovie 更新电影信息
+// UpdateMovie 更新电影信息，请求体为完整的电影数据，类型、演职人员和图片全部替换
 func UpdateMovie(c *gin.Context) {
 	id := c.Param("id")
 
-	var movie models.Movie
+	// 加载类型和演职人员，修改记录中比较替换前后的关联数据
+	var existing models.Movie
+	if err := requestDB(c).Preload("Genres").Preload("Credits").First(&existing, id).Error; err != nil {
+		respondFindError(c, err, "movie")
+		return
+	}
+	if !checkIfMatch(c, existing.Version) {
+		return
+	}
 
+	var movie models.Movie
 	if err := c.ShouldBindJSON(&movie); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的更新数据: %s", err.Error())})
+		respondBindError(c, err)
 		return
 	}
 
-	tx := config.DB.Begin()
-	//if err := tx.Preload("Genres").First(&movie, id).Error; err != nil {
-	//	c.JSON(http.StatusNotFound, gin.H{"error": "电影未找到"})
-	//	return
-	//}
+	saveMovieUpdate(c, &existing, &movie, movieAssociations{Genres: true, Credits: true, Images: true})
+}
 
-	// 删除原有关联数据
-	if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.Credit{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "清除演职人员失败"})
+// PatchMovie 按JSON Merge Patch部分更新电影信息，只替换请求中出现的关联数据
+func PatchMovie(c *gin.Context) {
+	id := c.Param("id")
+
+	if !isMergePatch(c.ContentType()) {
+		apierror.Respond(c, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMedia,
+			"Request body must be "+mergePatchContentType, "请求体必须为 "+mergePatchContentType))
 		return
 	}
 
-	// 删除原有关联数据
-	if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.MovieImage{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "清除图片失败"})
+	var existing models.Movie
+	if err := requestDB(c).Preload("Genres").Preload("Credits").Preload("Images").First(&existing, id).Error; err != nil {
+		respondFindError(c, err, "movie")
+		return
+	}
+	if !checkIfMatch(c, existing.Version) {
 		return
 	}
 
-	imagePathList := []string{}
-	for _, image := range movie.Images {
-		imagePathList = append(imagePathList, image.FilePath)
+	patch, err := c.GetRawData()
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Failed to read request body", "读取请求数据失败").Wrap(err))
+		return
+	}
+	keys, err := patchKeys(patch)
+	if err != nil {
+		respondBindError(c, err)
+		return
 	}
 
-	// 删除原有关联数据
-	if err := tx.Where("file_path in ?", imagePathList).Delete(&models.Image{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "清除图片失败"})
+	current, err := json.Marshal(existing)
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to update movie", "更新电影失败"))
+		return
+	}
+	merged, err := applyMergePatch(current, patch)
+	if err != nil {
+		respondBindError(c, err)
 		return
 	}
 
-	if err := tx.Model(&movie).Where(id).Updates(movie).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新电影失败"})
-		return
-	}
-	//
-	//// 更新类型关联
-	//var genres []models.Genre
-	//if err := tx.Where("id IN ?", movie.Genres).Find(&genres).Error; err != nil {
-	//	tx.Rollback()
-	//	c.JSON(http.StatusBadRequest, gin.H{"error": "无效的电影类型"})
-	//	return
-	//}
-	//tx.Model(&movie).Association("Genres").Replace(genres)
-	//
-	//
-	//// 重新创建演职人员
-	//for _, credit := range movie.Credits {
-	//	credit.MovieID = int(movie.ID)
-	//	if err := tx.Create(&credit).Error; err != nil {
-	//		tx.Rollback()
-	//		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存演职人员失败"})
-	//		return
-	//	}
-	//}
-
-	tx.Commit()
-	c.JSON(http.StatusOK, movie)
+	var movie models.Movie
+	if err := json.Unmarshal(merged, &movie); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := apierror.Validate(&movie); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	saveMovieUpdate(c, &existing, &movie, movieAssociations{
+		Genres:  keys["Genres"],
+		Credits: keys["Credits"],
+		Images:  keys["Images"],
+	})
+}
+
+// movieAssociations 更新电影时需要替换的关联数据
+type movieAssociations struct {
+	Genres  bool
+	Credits bool
+	Images  bool
+}
+
+// saveMovieUpdate 在一个事务中保存电影字段并替换指定的关联数据，成功后返回重新加载的电影
+func saveMovieUpdate(c *gin.Context, existing, movie *models.Movie, replace movieAssociations) {
+	movie.ID = existing.ID
+	movie.CreatedAt = existing.CreatedAt
+	movie.DeletedAt = existing.DeletedAt
+	movie.Version = existing.Version + 1
+	// 替换的关联数据没有提供时清空，修改记录中同样记为空
+	if replace.Genres && movie.Genres == nil {
+		movie.Genres = []models.Genre{}
+	}
+	if replace.Credits && movie.Credits == nil {
+		movie.Credits = []models.Credit{}
+	}
+	if replace.Credits {
+		if err := checkCreditIDs(movie.Credits); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+	}
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		// 只有版本号未变时才更新，避免覆盖其他人的修改
+		result := tx.Model(movie).Where("version = ?", existing.Version).
+			Select("*").Omit(clause.Associations, "created_at").Updates(movie)
+		if result.Error != nil {
+			return errUpdateMovie
+		}
+		if result.RowsAffected == 0 {
+			return errStaleVersion
+		}
+		if replace.Genres {
+			if err := replaceMovieGenres(tx, movie); err != nil {
+				return err
+			}
+		}
+		if replace.Credits {
+			if err := replaceMovieCredits(tx, movie); err != nil {
+				return err
+			}
+		}
+		if replace.Images {
+			if err := replaceMovieImages(tx, movie); err != nil {
+				return err
+			}
+		}
+		if err := recordEdit(tx, c, revision.Movie, movie.ID, existing, movie); err != nil {
+			return errUpdateMovie
+		}
+		return nil
+	})
+	if err != nil {
+		if err == errStaleVersion {
+			respondStaleWrite(c, &models.Movie{}, existing.ID)
+		} else {
+			apierror.Respond(c, err)
+		}
+		return
+	}
+
+	var updated models.Movie
+	if err := requestDB(c).
+		Preload("Genres").
+		Preload("Credits", func(db *gorm.DB) *gorm.DB {
+			return db.Order("credit_type ASC").Order(creditOrder)
+		}).
+		Preload("Credits.People").
+		Preload("Images").First(&updated, movie.ID).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to reload movie", "获取更新后的电影失败"))
+		return
+	}
+
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, updated)
+}
+
+var (
+	errStaleVersion    = errors.New("数据已被其他人修改")
+	errUpdateMovie     = apierror.Internal(nil, "Failed to update movie", "更新电影失败")
+	errInvalidGenres   = apierror.Invalid().WithField("Genres", "exists", "contains unknown genres", "无效的电影类型")
+	errInvalidCredit   = apierror.Invalid().WithField("Credits", "exists", "contains unknown people", "无效的演职人员")
+	errDuplicateCredit = apierror.Invalid().WithField("Credits", "unique", "contains duplicate credit_id", "演职人员的credit_id重复")
+	errSaveCredits     = apierror.Internal(nil, "Failed to save credits", "保存演职人员失败")
+	errSaveImages      = apierror.Internal(nil, "Failed to save images", "保存图片失败")
+)
+
+// replaceMovieGenres 用movie.Genres替换电影的类型，类型必须已存在
+func replaceMovieGenres(tx *gorm.DB, movie *models.Movie) error {
+	ids := make([]int, 0, len(movie.Genres))
+	for _, genre := range movie.Genres {
+		ids = append(ids, genre.ID)
+	}
+
+	var genres []models.Genre
+	if len(ids) > 0 {
+		if err := tx.Where("id IN ?", ids).Find(&genres).Error; err != nil {
+			return errInvalidGenres
+		}
+		if len(genres) != len(ids) {
+			return errInvalidGenres
+		}
+	}
+
+	if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.MovieGenre{}).Error; err != nil {
+		return errUpdateMovie
+	}
+	for _, genre := range genres {
+		relation := models.MovieGenre{MovieID: movie.ID, GenreID: uint(genre.ID)}
+		if err := tx.Create(&relation).Error; err != nil {
+			return errUpdateMovie
+		}
+	}
+	return nil
+}
+
+// replaceMovieCredits 用movie.Credits替换电影的演职人员
+func replaceMovieCredits(tx *gorm.DB, movie *models.Movie) error {
+	if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.Credit{}).Error; err != nil {
+		return errSaveCredits
+	}
+
+	// 生成的ID等写回movie.Credits，修改记录中与保存的数据一致
+	for i := range movie.Credits {
+		credit := &movie.Credits[i]
+		if credit.PeopleID == 0 && credit.People != nil {
+			credit.PeopleID = credit.People.ID
+		}
+		if credit.PeopleID == 0 {
+			return errInvalidCredit
+		}
+		if credit.ID == "" {
+			credit.ID = newCreditID()
+		}
+		credit.MovieID = int(movie.ID)
+		credit.Movie = nil
+		credit.People = nil
+		if err := tx.Create(credit).Error; err != nil {
+			if dialect.IsDuplicateKey(err) {
+				return errDuplicateCredit
+			}
+			return errSaveCredits
+		}
+	}
+	return nil
+}
+
+// checkCreditIDs 检查请求中的演职人员没有重复的credit_id，未提供的credit_id保存时生成
+func checkCreditIDs(credits []models.Credit) error {
+	seen := make(map[string]bool, len(credits))
+	for _, credit := range credits {
+		if credit.ID == "" {
+			continue
+		}
+		if seen[credit.ID] {
+			return errDuplicateCredit
+		}
+		seen[credit.ID] = true
+	}
+	return nil
+}
+
+// newCreditID 为手动添加的演职人员生成与TMDB格式一致的24位ID
+func newCreditID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// replaceMovieImages 用movie.Images替换电影的图片关联，图片记录本身保留
+func replaceMovieImages(tx *gorm.DB, movie *models.Movie) error {
+	if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.MovieImage{}).Error; err != nil {
+		return errSaveImages
+	}
+
+	for _, image := range movie.Images {
+		if image.FilePath == "" {
+			continue
+		}
+		if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).Create(&image).Error; err != nil {
+			return errSaveImages
+		}
+		movieImage := models.MovieImage{
+			MovieID:       int(movie.ID),
+			ImageFilePath: image.FilePath,
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&movieImage).Error; err != nil {
+			return errSaveImages
+		}
+	}
+	return nil
 }
 
 // AddFavorite 添加电影收藏
 func AddFavorite(c *gin.Context) {
 	userId := c.GetUint("userId")
 	if userId == 0 {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "请先登录"})
+		apierror.Respond(c, apierror.Unauthorized("Login required", "请先登录"))
 		return
 	}
 
 	movieId := c.Param("id")
 	var movie models.Movie
-	if err := config.DB.First(&movie, movieId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "电影不存在"})
+	if err := requestDB(c).First(&movie, movieId).Error; err != nil {
+		respondFindError(c, err, "movie")
 		return
 	}
 
 	var user models.User
-	if err := config.DB.Preload("FavoriteMovies").First(&user, userId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+	if err := requestDB(c).Preload("FavoriteMovies").First(&user, userId).Error; err != nil {
+		respondFindError(c, err, "user")
 		return
 	}
 
 	// 检查是否已收藏
 	for _, m := range user.FavoriteMovies {
 		if m.ID == movie.ID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "已收藏该电影"})
+			apierror.Respond(c, apierror.Conflict("Movie is already a favorite", "已收藏该电影"))
 			return
 		}
 	}
 
 	// 添加收藏
-	if err := config.DB.Model(&user).Association("FavoriteMovies").Append(&movie); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "收藏失败"})
+	if err := requestDB(c).Model(&user).Association("FavoriteMovies").Append(&movie); err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to add favorite", "收藏失败"))
 		return
 	}
 
@@ -278,20 +579,20 @@ func AddFavorite(c *gin.Context) {
 func RemoveFavorite(c *gin.Context) {
 	userId := c.GetUint("userId")
 	if userId == 0 {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "请先登录"})
+		apierror.Respond(c, apierror.Unauthorized("Login required", "请先登录"))
 		return
 	}
 
 	movieId := c.Param("id")
 	var movie models.Movie
-	if err := config.DB.First(&movie, movieId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "电影不存在"})
+	if err := requestDB(c).First(&movie, movieId).Error; err != nil {
+		respondFindError(c, err, "movie")
 		return
 	}
 
 	var user models.User
-	if err := config.DB.Preload("FavoriteMovies").First(&user, userId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+	if err := requestDB(c).Preload("FavoriteMovies").First(&user, userId).Error; err != nil {
+		respondFindError(c, err, "user")
 		return
 	}
 
@@ -305,26 +606,48 @@ func RemoveFavorite(c *gin.Context) {
 	}
 
 	if !found {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "未收藏该电影"})
+		apierror.Respond(c, apierror.NotFound("favorite"))
 		return
 	}
 
 	// 取消收藏
-	if err := config.DB.Model(&user).Association("FavoriteMovies").Delete(&movie); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "取消收藏失败"})
+	if err := requestDB(c).Model(&user).Association("FavoriteMovies").Delete(&movie); err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to remove favorite", "取消收藏失败"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "取消收藏成功"})
 }
 
-// DeleteMovie 删除电影
+// DeleteMovie 删除电影，电影移入回收站，关联数据保留以便恢复
 func DeleteMovie(c *gin.Context) {
 	id := c.Param("id")
 
-	result := config.DB.Delete(&models.Movie{}, id)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete movie"})
+	var movie models.Movie
+	if err := requestDB(c).First(&movie, id).Error; err != nil {
+		respondFindError(c, err, "movie")
+		return
+	}
+	if !checkIfMatch(c, movie.Version) {
+		return
+	}
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("version = ?", movie.Version).Delete(&movie)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStaleVersion
+		}
+		return revision.Record(tx, currentActor(c), revision.Movie, movie.ID, &movie, nil)
+	})
+	if err == errStaleVersion {
+		respondStaleWrite(c, &models.Movie{}, movie.ID)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to delete movie", "删除电影失败"))
 		return
 	}
 
@@ -335,10 +658,7 @@ func DeleteMovie(c *gin.Context) {
 func UploadImage(c *gin.Context) {
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "文件上传失败",
-			"message": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
@@ -349,36 +669,26 @@ func UploadImage(c *gin.Context) {
 		"image/webp": true,
 	}
 	if !allowedTypes[file.Header.Get("Content-Type")] {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":  "不支持的图片格式",
-			"detail": file.Header.Get("Content-Type"),
-		})
+		apierror.Respond(c, apierror.Invalid().WithField("file", "oneof",
+			"must be a JPEG, PNG or WebP image", "不支持的图片格式").With("detail", file.Header.Get("Content-Type")))
 		return
 	}
 
 	// 生成唯一文件名
 	fileExt := filepath.Ext(file.Filename)
 	fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), fileExt)
-	dstPath := filepath.Join("images", fileName)
+	dstPath := filepath.Join(config.GetImageDir(), fileName)
 
-	// 设置CORS头
-	c.Header("Access-Control-Allow-Origin", "*")
 	// 保存文件
 	if error := c.SaveUploadedFile(file, dstPath); error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "文件保存失败",
-			"message": error.Error(),
-		})
+		apierror.Respond(c, apierror.Internal(error, "Failed to save file", "文件保存失败"))
 		return
 	}
 
 	// 获取图片元数据
 	dstFile, err := os.Open(dstPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "打开保存文件失败",
-			"message": err.Error(),
-		})
+		apierror.Respond(c, apierror.Internal(err, "Failed to open saved file", "打开保存文件失败"))
 		return
 	}
 	defer dstFile.Close()
@@ -387,18 +697,13 @@ func UploadImage(c *gin.Context) {
 	dstFile.Seek(0, 0)
 	img, err := imaging.Open(dstFile.Name(), imaging.AutoOrientation(true))
 	if err != nil {
-		log.Printf("解码失败 文件路径:%s 错误详情:%v", dstPath, err)
+		slog.WarnContext(c.Request.Context(), "图片解码失败", "path", dstPath, "error", err)
 		_ = os.Remove(dstPath)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "解析图片元数据失败",
-			"message": fmt.Sprintf("文件格式可能损坏，详情：%s", err.Error()),
-		})
+		apierror.Respond(c, apierror.Invalid().Wrap(err).WithField("file", "image",
+			"could not be decoded, the file may be corrupted", "解析图片元数据失败，文件格式可能损坏"))
 		return
 	}
 
-	// 移除未使用的str变量
-	fmt.Println("图片元数据获取错误:", err)
-
 	c.JSON(http.StatusOK, gin.H{
 		"file_path":    "/" + fileName,
 		"width":        img.Bounds().Dx(),
@@ -419,23 +724,23 @@ func GetAdminMovies(c *gin.Context) {
 	offset := (page - 1) * pageSize
 
 	// 构建查询
-	dbQuery := config.DB.Model(&models.Movie{})
+	dbQuery := requestDB(c).Model(&models.Movie{})
 
 	// 添加搜索条件
 	if searchQuery != "" {
-		dbQuery = dbQuery.Where("title LIKE ? OR original_title LIKE ?", "%"+searchQuery+"%", "%"+searchQuery+"%")
+		dbQuery = dialect.Search(dbQuery, searchQuery, "title", "original_title")
 	}
 
 	// 获取总记录数
 	if err := dbQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取电影总数失败"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to count movies", "获取电影总数失败"))
 		return
 	}
 	dbQuery.Preload("Director", "job = ?", "Director").Preload("Director.People")
 	// 获取分页数据，按ID逆序排列
 
 	if err := dbQuery.Order("id DESC").Offset(offset).Limit(pageSize).Find(&movies).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取电影列表失败"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch movies", "获取电影列表失败"))
 		return
 	}
 
@@ -447,3 +752,24 @@ func GetAdminMovies(c *gin.Context) {
 		"results":     movies,
 	})
 }
+
+// GetAdminMovie 获取单个电影详情（管理后台），响应头中带有当前版本的ETag
+func GetAdminMovie(c *gin.Context) {
+	id := c.Param("id")
+
+	var movie models.Movie
+	result := requestDB(c).
+		Preload("Genres").
+		Preload("Credits", func(db *gorm.DB) *gorm.DB {
+			return db.Order("credit_type ASC").Order(creditOrder)
+		}).
+		Preload("Credits.People").
+		Preload("Images").First(&movie, id)
+	if result.Error != nil {
+		respondFindError(c, result.Error, "movie")
+		return
+	}
+
+	setETag(c, movie.Version)
+	c.JSON(http.StatusOK, movie)
+}
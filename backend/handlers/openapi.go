@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/backup"
+	"github.com/Estella0129/theater/backend/pkg/catalog"
+	"github.com/Estella0129/theater/backend/pkg/job"
+	"github.com/Estella0129/theater/backend/pkg/openapi"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"github.com/gin-gonic/gin"
+)
+
+// APIBasePath 前端和管理后台接口的路径前缀，OpenAPI文档中的路径相对于该前缀
+const APIBasePath = "/api/v1"
+
+// 以下类型只用于描述gin.H响应的结构
+
+// messageResponse 只有提示信息的响应
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// pageResponse 分页列表的响应，results的类型由pageOf指定
+type pageResponse struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"total_pages"`
+}
+
+type loginResponse struct {
+	User  models.User `json:"user"`
+	Token string      `json:"token"`
+}
+
+type freezeResponse struct {
+	Message  string `json:"message"`
+	IsFrozen bool   `json:"is_frozen"`
+	Version  int    `json:"version"`
+}
+
+type passwordResponse struct {
+	Message string `json:"message"`
+	Version int    `json:"version"`
+}
+
+type uploadedImage struct {
+	FilePath    string  `json:"file_path"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	AspectRatio float64 `json:"aspect_ratio"`
+}
+
+type fieldLocksResponse struct {
+	Lockable  []string              `json:"lockable"`
+	Locks     []models.FieldLock    `json:"locks"`
+	Conflicts []models.SyncConflict `json:"conflicts"`
+}
+
+type lockedFieldsResponse struct {
+	Locks []models.FieldLock `json:"locks"`
+}
+
+type revisionDetail struct {
+	Revision    revisionResponse                `json:"revision"`
+	CurrentDiff map[string]revision.FieldChange `json:"current_diff"`
+}
+
+type rollbackResponse struct {
+	Message  string            `json:"message"`
+	Revision *revisionResponse `json:"revision,omitempty"` // 数据与该记录一致时没有
+}
+
+type bulkResponse struct {
+	Total     int          `json:"total"`
+	Succeeded int          `json:"succeeded"`
+	Skipped   int          `json:"skipped"`
+	Failed    int          `json:"failed"`
+	Results   []job.Result `json:"results"`
+}
+
+type backupList struct {
+	Results []backup.Info `json:"results"`
+}
+
+// versionError 412和428响应，带有数据的当前版本号
+type versionError struct {
+	apierror.Body
+	Version int `json:"version"`
+}
+
+// retryError 429响应，同时带有Retry-After响应头
+type retryError struct {
+	apierror.Body
+	RetryAfter int `json:"retry_after"`
+}
+
+// importError 导入失败的响应，读取到一半出错时带有已处理部分的报告
+type importError struct {
+	apierror.Body
+	Report *catalog.Report `json:"report,omitempty"`
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  *openapi.Document
+)
+
+// apiDocsPage 文档页面，使用CDN上的Swagger UI展示OpenAPI文档，初始化脚本单独加载以符合CSP
+const apiDocsPage = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Theater API</title>
+<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script src="/api/docs/swagger-init.js"></script>
+</body>
+</html>
+`
+
+// apiDocsScript 文档页面的初始化脚本
+const apiDocsScript = `window.ui = SwaggerUIBundle({ url: "/api/docs/openapi.json", dom_id: "#swagger-ui", deepLinking: true });
+`
+
+// OpenAPISpec 返回OpenAPI文档
+func OpenAPISpec(c *gin.Context) {
+	c.JSON(http.StatusOK, OpenAPI())
+}
+
+// APIDocs 接口文档页面
+func APIDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(apiDocsPage))
+}
+
+// APIDocsScript 文档页面的初始化脚本
+func APIDocsScript(c *gin.Context) {
+	c.Data(http.StatusOK, "text/javascript; charset=utf-8", []byte(apiDocsScript))
+}
+
+// OpenAPI 返回前端和管理后台全部接口的OpenAPI文档，新增路由时需要在这里添加说明，
+// cmd包的TestOpenAPICoversRoutes和openapi check命令检查路由与文档是否一致
+func OpenAPI() *openapi.Document {
+	openAPIOnce.Do(func() {
+		openAPIDoc = buildOpenAPI()
+	})
+	return openAPIDoc
+}
+
+func buildOpenAPI() *openapi.Document {
+	d := openapi.New("Theater API", "1.0.0", APIBasePath)
+	d.Info.Description = "电影资料库的前端和管理后台接口。错误响应统一包含code、message、localized_message和request_id，" +
+		"localized_message的语言由Accept-Language决定，默认为中文。" +
+		"每个IP和登录用户的请求分别限流，超过限制时返回429，Retry-After为需要等待的秒数。"
+
+	d.ErrorResponse(http.StatusBadRequest, apierror.Body{}, "请求参数错误，校验失败时fields中为各字段的错误")
+	d.ErrorResponse(http.StatusUnauthorized, apierror.Body{}, "未登录或登录信息无效")
+	d.ErrorResponse(http.StatusForbidden, apierror.Body{}, "没有权限")
+	d.ErrorResponse(http.StatusNotFound, apierror.Body{}, "数据不存在")
+	d.ErrorResponse(http.StatusConflict, apierror.Body{}, "与现有数据冲突")
+	d.ErrorResponse(http.StatusPreconditionFailed, versionError{}, "数据已被其他人修改，version为当前版本")
+	d.ErrorResponse(http.StatusUnsupportedMediaType, apierror.Body{}, "不支持的Content-Type")
+	d.ErrorResponse(http.StatusPreconditionRequired, versionError{}, "缺少If-Match请求头，version为当前版本")
+	d.ErrorResponse(http.StatusTooManyRequests, retryError{}, "请求过于频繁或登录失败次数过多，retry_after为需要等待的秒数")
+	d.ErrorResponse(http.StatusInternalServerError, apierror.Body{}, "服务器内部错误")
+	d.BearerAuth(adminAuth, "JWT", "登录接口返回的token，用户需要是未冻结的管理员")
+
+	d.Tag("users", "用户")
+	d.Tag("movies", "电影")
+	d.Tag("people", "人物")
+	d.Tag("genres", "电影类型")
+	d.Tag("collections", "合集")
+	d.Tag("revisions", "修改记录")
+	d.Tag("field-locks", "字段锁和同步冲突")
+	d.Tag("trash", "回收站")
+	d.Tag("bulk", "批量操作和后台任务")
+	d.Tag("catalog", "导入导出")
+	d.Tag("backups", "备份")
+
+	addFrontendRoutes(d)
+	addAdminRoutes(d)
+	return d
+}
+
+func addFrontendRoutes(d *openapi.Document) {
+	// 用户
+	d.Route(http.MethodPost, "/frontend/users/register").Doc("用户注册", "注册的用户总是普通用户").Tag("users").
+		Body(registerRequest{}).Returns(http.StatusCreated, models.User{}).
+		Errors(http.StatusBadRequest, http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError)
+	d.Route(http.MethodPost, "/frontend/users/login").Doc("用户登录", "同一IP对同一用户名连续失败多次后暂时锁定，锁定期间返回429").Tag("users").
+		Body(loginRequest{}).Returns(http.StatusOK, loginResponse{}).
+		Errors(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError)
+	d.Route(http.MethodGet, "/frontend/users/:id").Doc("获取用户详情").Tag("users").
+		Returns(http.StatusOK, models.User{}).Errors(http.StatusNotFound)
+
+	// 电影
+	paged(d.Route(http.MethodGet, "/frontend/movies").Doc("获取电影列表").Tag("movies")).
+		Query("query", openapi.String(), "按标题或原标题搜索").
+		Query("genre", openapi.Integer(), "类型ID").
+		Returns(http.StatusOK, pageOf(d, models.Movie{})).Errors(http.StatusInternalServerError)
+	d.Route(http.MethodGet, "/frontend/movies/:id").Doc("获取单个电影详情", "包含类型、图片、演员和按部门分组的职员").Tag("movies").
+		Returns(http.StatusOK, models.Movie{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+	d.Route(http.MethodGet, "/frontend/genres").Doc("获取所有电影类型").Tag("genres").
+		Returns(http.StatusOK, []models.Genre{}).Errors(http.StatusInternalServerError)
+
+	// 人物
+	paged(d.Route(http.MethodGet, "/frontend/peoples").Doc("获取人物列表").Tag("people")).
+		Query("query", openapi.String(), "按姓名或原名搜索").
+		Returns(http.StatusOK, pageOf(d, models.People{})).Errors(http.StatusInternalServerError)
+	d.Route(http.MethodGet, "/frontend/peoples/:id").Doc("获取单个人物详情").Tag("people").
+		Returns(http.StatusOK, models.People{}).Errors(http.StatusNotFound)
+	d.Route(http.MethodGet, "/frontend/peoples/:id/filmography").Doc("获取人物作品年表", "按部门分组，每组按上映日期倒序排列").Tag("people").
+		Returns(http.StatusOK, []filmographyDepartment{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+	d.Route(http.MethodGet, "/frontend/peoples/:id/known-for").Doc("获取人物代表作").Tag("people").
+		Query("limit", openapi.Integer().WithDefault(8), "返回数量").
+		Returns(http.StatusOK, []knownForCredit{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+	d.Route(http.MethodGet, "/frontend/peoples/:id/collaborators").Doc("获取人物常合作者").Tag("people").
+		Query("limit", openapi.Integer().WithDefault(10), "返回数量").
+		Returns(http.StatusOK, []collaborator{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+
+	// 合集
+	paged(d.Route(http.MethodGet, "/frontend/collections").Doc("获取合集列表").Tag("collections")).
+		Query("query", openapi.String(), "按名称搜索").
+		Returns(http.StatusOK, pageOf(d, models.Collection{})).Errors(http.StatusInternalServerError)
+	d.Route(http.MethodGet, "/frontend/collections/:id").Doc("获取合集详情及电影").Tag("collections").
+		Returns(http.StatusOK, models.Collection{}).Errors(http.StatusNotFound)
+}
+
+func addAdminRoutes(d *openapi.Document) {
+	d.Route(http.MethodPost, "/admin/upload-image").Doc("上传图片", "支持JPEG、PNG和WebP，file_path为/images下的路径").Tag("movies").
+		BodyContent("multipart/form-data", openapi.Object(map[string]*openapi.Schema{"file": openapi.Binary()}, "file")).
+		Returns(http.StatusOK, uploadedImage{}).Errors(http.StatusBadRequest, http.StatusInternalServerError)
+
+	// 用户管理
+	d.Route(http.MethodPost, "/admin/users").Doc("管理员创建用户").Tag("users").
+		Body(models.User{}).Returns(http.StatusCreated, models.User{}).
+		Errors(http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError)
+	paged(d.Route(http.MethodGet, "/admin/users").Doc("获取用户列表").Tag("users")).
+		Returns(http.StatusOK, pageOf(d, models.User{})).Errors(http.StatusInternalServerError)
+	withETag(d.Route(http.MethodGet, "/admin/users/:id").Doc("获取用户详情").Tag("users").
+		Returns(http.StatusOK, models.User{}).Errors(http.StatusNotFound))
+	withETag(ifMatch(d.Route(http.MethodPut, "/admin/users/:id").Doc("更新用户信息").Tag("users")).
+		Body(userUpdate{}).Returns(http.StatusOK, models.User{}).
+		Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError))
+	ifMatch(d.Route(http.MethodDelete, "/admin/users/:id").Doc("删除用户", "用户移入回收站").Tag("users")).
+		Returns(http.StatusOK, messageResponse{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+	withETag(ifMatch(d.Route(http.MethodPatch, "/admin/users/:id/toggle-freeze").Doc("切换用户冻结状态").Tag("users")).
+		Returns(http.StatusOK, freezeResponse{}).Errors(http.StatusNotFound, http.StatusInternalServerError))
+	withETag(ifMatch(d.Route(http.MethodPatch, "/admin/users/:id/update_password").Doc("修改用户密码").Tag("users")).
+		Body(passwordUpdate{}).Returns(http.StatusOK, passwordResponse{}).
+		Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError))
+
+	// 电影管理
+	d.Route(http.MethodPost, "/admin/movies").Doc("创建电影").Tag("movies").
+		Body(models.Movie{}).Returns(http.StatusCreated, models.Movie{}).
+		Errors(http.StatusBadRequest, http.StatusInternalServerError)
+	paged(d.Route(http.MethodGet, "/admin/movies").Doc("获取电影列表").Tag("movies")).
+		Query("query", openapi.String(), "按标题或原标题搜索").
+		Returns(http.StatusOK, pageOf(d, models.Movie{})).Errors(http.StatusInternalServerError)
+	withETag(d.Route(http.MethodGet, "/admin/movies/:id").Doc("获取电影详情").Tag("movies").
+		Returns(http.StatusOK, models.Movie{}).Errors(http.StatusNotFound))
+	withETag(ifMatch(d.Route(http.MethodPut, "/admin/movies/:id").Doc("更新电影信息", "替换电影的全部字段以及类型、演职人员和图片").Tag("movies")).
+		Body(models.Movie{}).Returns(http.StatusOK, models.Movie{}).
+		Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError))
+	withETag(ifMatch(d.Route(http.MethodPatch, "/admin/movies/:id").Doc("部分更新电影信息", "请求体为JSON Merge Patch (RFC 7396)，只修改出现的字段").Tag("movies")).
+		BodyContent(mergePatchContentType, d.Schema(models.Movie{})).
+		Returns(http.StatusOK, models.Movie{}).
+		Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusUnsupportedMediaType, http.StatusInternalServerError))
+	ifMatch(d.Route(http.MethodDelete, "/admin/movies/:id").Doc("删除电影", "电影移入回收站").Tag("movies")).
+		Returns(http.StatusOK, messageResponse{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+
+	// 人物管理
+	d.Route(http.MethodPost, "/admin/people").Doc("创建人物").Tag("people").
+		Body(models.People{}).Returns(http.StatusCreated, models.People{}).
+		Errors(http.StatusBadRequest, http.StatusInternalServerError)
+	paged(d.Route(http.MethodGet, "/admin/people").Doc("获取人物列表").Tag("people")).
+		Query("search", openapi.String(), "按姓名搜索").
+		Returns(http.StatusOK, pageOf(d, models.People{})).Errors(http.StatusInternalServerError)
+	withETag(d.Route(http.MethodGet, "/admin/people/:id").Doc("获取人物详情").Tag("people").
+		Returns(http.StatusOK, models.People{}).Errors(http.StatusNotFound))
+	withETag(ifMatch(d.Route(http.MethodPut, "/admin/people/:id").Doc("更新人物信息").Tag("people")).
+		Body(models.People{}).Returns(http.StatusOK, models.People{}).
+		Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError))
+	ifMatch(d.Route(http.MethodDelete, "/admin/people/:id").Doc("删除人物", "人物移入回收站").Tag("people")).
+		Returns(http.StatusOK, messageResponse{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+
+	// 类型管理
+	d.Route(http.MethodPost, "/admin/genres").Doc("创建类型").Tag("genres").
+		Body(models.Genre{}).Returns(http.StatusCreated, models.Genre{}).
+		Errors(http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError)
+	d.Route(http.MethodGet, "/admin/genres").Doc("获取类型列表").Tag("genres").
+		Returns(http.StatusOK, []models.Genre{}).Errors(http.StatusInternalServerError)
+	withETag(d.Route(http.MethodGet, "/admin/genres/:id").Doc("获取类型详情").Tag("genres").
+		Returns(http.StatusOK, models.Genre{}).Errors(http.StatusNotFound))
+	withETag(ifMatch(d.Route(http.MethodPut, "/admin/genres/:id").Doc("更新类型信息").Tag("genres")).
+		Body(models.Genre{}).Returns(http.StatusOK, models.Genre{}).
+		Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError))
+	ifMatch(d.Route(http.MethodDelete, "/admin/genres/:id").Doc("删除类型", "类型移入回收站").Tag("genres")).
+		Returns(http.StatusOK, messageResponse{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+
+	// 合集管理
+	d.Route(http.MethodPost, "/admin/collections").Doc("创建合集").Tag("collections").
+		Body(collectionData{}).Returns(http.StatusCreated, models.Collection{}).
+		Errors(http.StatusBadRequest, http.StatusInternalServerError)
+	paged(d.Route(http.MethodGet, "/admin/collections").Doc("获取合集列表").Tag("collections")).
+		Query("query", openapi.String(), "按名称搜索").
+		Returns(http.StatusOK, pageOf(d, models.Collection{})).Errors(http.StatusInternalServerError)
+	d.Route(http.MethodGet, "/admin/collections/:id").Doc("获取合集详情").Tag("collections").
+		Returns(http.StatusOK, models.Collection{}).Errors(http.StatusNotFound)
+	d.Route(http.MethodPut, "/admin/collections/:id").Doc("更新合集信息", "movie_ids为空时不修改合集中的电影").Tag("collections").
+		Body(collectionData{}).Returns(http.StatusOK, models.Collection{}).
+		Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError)
+	d.Route(http.MethodDelete, "/admin/collections/:id").Doc("删除合集").Tag("collections").
+		Returns(http.StatusOK, messageResponse{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+
+	// 修改记录和字段锁
+	for _, entity := range []struct{ path, name string }{
+		{"movies", "电影"}, {"people", "人物"}, {"genres", "类型"}, {"collections", "合集"},
+	} {
+		paged(d.Route(http.MethodGet, "/admin/"+entity.path+"/:id/revisions").Doc(entity.name+"修改记录").Tag("revisions")).
+			Returns(http.StatusOK, pageOf(d, revisionResponse{})).Errors(http.StatusInternalServerError)
+
+		d.Route(http.MethodGet, "/admin/"+entity.path+"/:id/locks").Doc(entity.name+"字段锁及同步冲突").Tag("field-locks").
+			Param("id", openapi.String(), entity.name+"ID").
+			Returns(http.StatusOK, fieldLocksResponse{}).Errors(http.StatusInternalServerError)
+		d.Route(http.MethodPost, "/admin/"+entity.path+"/:id/locks").Doc("锁定"+entity.name+"字段").Tag("field-locks").
+			Param("id", openapi.String(), entity.name+"ID").
+			Body(lockFieldsRequest{}).Returns(http.StatusOK, lockedFieldsResponse{}).
+			Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError)
+		d.Route(http.MethodDelete, "/admin/"+entity.path+"/:id/locks/:field").Doc("解除"+entity.name+"字段锁").Tag("field-locks").
+			Param("id", openapi.String(), entity.name+"ID").
+			Param("field", openapi.String(), "字段的JSON名称").
+			Returns(http.StatusOK, messageResponse{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+	}
+	withETag(d.Route(http.MethodGet, "/admin/revisions/:id").Doc("修改记录详情及差异",
+		"current_diff为当前数据与该记录之间的差异；电影、人物和类型存在时ETag为其当前版本").Tag("revisions").
+		Returns(http.StatusOK, revisionDetail{}).Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError))
+	withETag(ifMatch(d.Route(http.MethodPost, "/admin/revisions/:id/rollback").Doc("回滚到指定修改记录",
+		"已删除的数据会被重新创建；电影、人物和类型存在时需要以修改记录详情中的ETag作为If-Match，合集和已彻底删除的数据不检查").Tag("revisions")).
+		Returns(http.StatusOK, rollbackResponse{}).Errors(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError))
+	paged(d.Route(http.MethodGet, "/admin/sync-conflicts").Doc("同步时跳过的冲突").Tag("field-locks")).
+		Query("entity_type", openapi.Enum(revision.Movie, revision.People, revision.Genre, revision.Collection), "实体类型").
+		Query("entity_id", openapi.String(), "实体ID").
+		Returns(http.StatusOK, pageOf(d, models.SyncConflict{})).Errors(http.StatusInternalServerError)
+
+	// 回收站
+	trashType := openapi.Enum("movies", "people", "genres", "collections", "users")
+	paged(d.Route(http.MethodGet, "/admin/trash/:type").Doc("回收站列表").Tag("trash").
+		Param("type", trashType, "数据类型")).
+		Returns(http.StatusOK, pageOf(d, openapi.OneOf(
+			d.Schema(models.Movie{}), d.Schema(models.People{}), d.Schema(models.Genre{}), d.Schema(models.User{}),
+		))).Errors(http.StatusNotFound, http.StatusInternalServerError)
+	d.Route(http.MethodPost, "/admin/trash/:type/:id/restore").Doc("从回收站恢复").Tag("trash").
+		Param("type", trashType, "数据类型").
+		Returns(http.StatusOK, messageResponse{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+	d.Route(http.MethodDelete, "/admin/trash/:type/:id").Doc("永久删除").Tag("trash").
+		Param("type", trashType, "数据类型").
+		Returns(http.StatusOK, messageResponse{}).Errors(http.StatusNotFound, http.StatusInternalServerError)
+
+	// 批量操作
+	bulk(d, "/admin/bulk/movies/delete", "批量删除电影", bulkMovieRequest{})
+	bulk(d, "/admin/bulk/movies/restore", "批量恢复电影", bulkMovieRequest{})
+	bulk(d, "/admin/bulk/movies/genres", "批量添加或移除类型", bulkMovieRequest{}, "需要genre_id和action，action为add或remove")
+	d.Route(http.MethodPost, "/admin/bulk/movies/resync").Doc("批量从TMDB重新同步", "总是在后台任务中执行").Tag("bulk").
+		Body(bulkMovieRequest{}).Returns(http.StatusAccepted, job.Info{}).
+		ResponseHeader(http.StatusAccepted, "Location", "任务查询地址").
+		Errors(http.StatusBadRequest, http.StatusInternalServerError)
+	bulk(d, "/admin/bulk/people/delete", "批量删除人物", bulkPeopleRequest{})
+	bulk(d, "/admin/bulk/people/restore", "批量恢复人物", bulkPeopleRequest{})
+	d.Route(http.MethodPost, "/admin/bulk/people/resync").Doc("批量从TMDB重新同步人物详情", "总是在后台任务中执行").Tag("bulk").
+		Body(bulkPeopleRequest{}).Returns(http.StatusAccepted, job.Info{}).
+		ResponseHeader(http.StatusAccepted, "Location", "任务查询地址").
+		Errors(http.StatusBadRequest, http.StatusInternalServerError)
+	bulk(d, "/admin/bulk/users/freeze", "批量冻结或解冻用户", bulkUserRequest{}, "需要frozen")
+	bulk(d, "/admin/bulk/users/role", "批量修改用户角色", bulkUserRequest{}, "需要role，为user或admin")
+	d.Route(http.MethodGet, "/admin/jobs/:id").Doc("后台任务进度和结果", "任务结束一段时间后过期").Tag("bulk").
+		Param("id", openapi.String(), "任务ID").
+		Returns(http.StatusOK, job.Info{}).Errors(http.StatusNotFound)
+
+	// 导入导出
+	catalogEntity := openapi.Enum("movies", "people", "genres", "collections")
+	catalogFormat := openapi.Enum(catalog.FormatJSON, catalog.FormatNDJSON, catalog.FormatCSV)
+	adminOnly(d.Route(http.MethodGet, "/admin/export/:entity").Doc("导出电影、人物、类型或合集").Tag("catalog")).
+		Param("entity", catalogEntity, "数据类型").
+		Query("format", catalogFormat.WithDefault(catalog.FormatJSON), "导出格式").
+		ReturnsContent(http.StatusOK, "application/json", openapi.ArrayOf(openapi.Any())).
+		ReturnsContent(http.StatusOK, "application/x-ndjson", openapi.String()).
+		ReturnsContent(http.StatusOK, "text/csv", openapi.String()).
+		Errors(http.StatusBadRequest)
+	adminOnly(d.Route(http.MethodPost, "/admin/import/:entity").
+		Doc("导入电影、人物、类型或合集", "数据可以是请求体，也可以是表单中的file文件，未指定format时根据文件名或Content-Type判断").Tag("catalog")).
+		Param("entity", catalogEntity, "数据类型").
+		Query("format", catalogFormat, "导入格式").
+		Query("mode", openapi.Enum(catalog.ModeID, catalog.ModeTMDBID).WithDefault(catalog.ModeID), "匹配已有数据的方式").
+		Query("dry_run", openapi.Boolean().WithDefault(false), "只校验不写入").
+		BodyContent("application/json", openapi.ArrayOf(openapi.Any())).
+		BodyContent("application/x-ndjson", openapi.String()).
+		BodyContent("text/csv", openapi.String()).
+		BodyContent("multipart/form-data", openapi.Object(map[string]*openapi.Schema{"file": openapi.Binary()}, "file")).
+		Returns(http.StatusOK, catalog.Report{}).
+		Returns(http.StatusBadRequest, importError{}, "请求参数错误或读取数据失败").
+		Errors(http.StatusInternalServerError)
+
+	// 备份
+	adminOnly(d.Route(http.MethodPost, "/admin/backups").Doc("在后台任务中备份数据库").Tag("backups")).
+		Query("images", openapi.Boolean(), "是否包含图片，默认使用backup.include_images配置").
+		Returns(http.StatusAccepted, job.Info{}).
+		ResponseHeader(http.StatusAccepted, "Location", "任务查询地址").
+		Errors(http.StatusBadRequest)
+	adminOnly(d.Route(http.MethodGet, "/admin/backups").Doc("备份列表").Tag("backups")).
+		Returns(http.StatusOK, backupList{}).Errors(http.StatusInternalServerError)
+	adminOnly(d.Route(http.MethodGet, "/admin/backups/:name").Doc("下载备份文件").Tag("backups")).
+		Param("name", openapi.String(), "备份文件名").
+		ReturnsContent(http.StatusOK, "application/gzip", openapi.Binary()).
+		Errors(http.StatusBadRequest, http.StatusNotFound)
+}
+
+// adminAuth 需要管理员登录的接口使用的认证方式，见AdminMiddleware
+const adminAuth = "adminBearer"
+
+// adminOnly 需要管理员登录的接口
+func adminOnly(op *openapi.Operation) *openapi.Operation {
+	return op.Security(adminAuth).Errors(http.StatusUnauthorized, http.StatusForbidden)
+}
+
+// pageOf 分页列表的响应，item为列表项的Go值或Schema
+func pageOf(d *openapi.Document, item interface{}) *openapi.Schema {
+	var results *openapi.Schema
+	if schema, ok := item.(*openapi.Schema); ok {
+		results = schema
+	} else {
+		results = d.Schema(item)
+	}
+	schema := d.Schema(pageResponse{})
+	return &openapi.Schema{AllOf: []*openapi.Schema{schema, openapi.Object(
+		map[string]*openapi.Schema{"results": openapi.ArrayOf(results)}, "results",
+	)}}
+}
+
+// paged 添加分页参数
+func paged(op *openapi.Operation) *openapi.Operation {
+	return op.
+		Query("page", openapi.Integer().WithDefault(1), "页码，从1开始").
+		Query("page_size", openapi.Integer().WithDefault(20), "每页数量")
+}
+
+// ifMatch 需要If-Match请求头的接口，见checkIfMatch
+func ifMatch(op *openapi.Operation) *openapi.Operation {
+	return op.Header("If-Match", true, "数据的ETag，*表示不检查版本").
+		Errors(http.StatusPreconditionFailed, http.StatusPreconditionRequired)
+}
+
+// withETag 成功响应带有当前版本的ETag
+func withETag(op *openapi.Operation) *openapi.Operation {
+	return op.ResponseHeader(http.StatusOK, "ETag", "当前版本，修改时作为If-Match请求头")
+}
+
+// bulk 批量操作接口，数量较少时直接返回结果，否则返回202和后台任务
+func bulk(d *openapi.Document, path, summary string, request interface{}, description ...string) {
+	d.Route(http.MethodPost, path).Doc(summary, description...).Tag("bulk").
+		Body(request).
+		Returns(http.StatusOK, bulkResponse{}, "直接执行的结果").
+		Returns(http.StatusAccepted, job.Info{}, "数量较多时在后台任务中执行").
+		ResponseHeader(http.StatusAccepted, "Location", "任务查询地址").
+		Errors(http.StatusBadRequest, http.StatusInternalServerError)
+}
@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/Estella0129/theater/backend/config"
 	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"github.com/Estella0129/theater/backend/pkg/revision"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetPeople 获取人物列表，支持分页和搜索
@@ -30,20 +35,20 @@ func GetPeoples(c *gin.Context) {
 
 	offset := (page - 1) * pageSize
 
-	dbQuery := config.DB.Model(&models.People{})
+	dbQuery := requestDB(c).Model(&models.People{})
 	if searchQuery != "" {
-		dbQuery = dbQuery.Where("name LIKE ? OR original_name LIKE ?", "%"+searchQuery+"%", "%"+searchQuery+"%")
+		dbQuery = dialect.Search(dbQuery, searchQuery, "name", "original_name")
 	}
 
 	// 获取总记录数
 	if err := dbQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取人物总数失败"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to count people", "获取人物总数失败"))
 		return
 	}
 
 	// 获取分页数据
 	if err := dbQuery.Offset(offset).Limit(pageSize).Find(&people).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取人物列表失败"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch people", "获取人物列表失败"))
 		return
 	}
 
@@ -67,26 +72,205 @@ func GetPeople(c *gin.Context) {
 	id := c.Param("id")
 
 	var People models.People
-	result := config.DB.Preload("Credits").Preload("Credits.Movie").First(&People, id)
+	result := requestDB(c).Preload("Credits").Preload("Credits.Movie").Preload("Images").First(&People, id)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "People not found"})
+		respondFindError(c, result.Error, "people")
 		return
 	}
 
 	c.JSON(http.StatusOK, People)
 }
 
+// filmographyDepartment 人物在某一部门下的作品列表
+type filmographyDepartment struct {
+	Department string          `json:"department"`
+	Credits    []models.Credit `json:"credits"`
+}
+
+// GetPeopleFilmography 获取人物作品年表，按部门分组并按上映日期倒序排列
+func GetPeopleFilmography(c *gin.Context) {
+	id := c.Param("id")
+
+	var people models.People
+	if err := requestDB(c).First(&people, id).Error; err != nil {
+		respondFindError(c, err, "people")
+		return
+	}
+
+	var credits []models.Credit
+	if err := requestDB(c).InnerJoins("Movie").
+		Where("credits.people_id = ?", people.ID).
+		Order(clause.OrderByColumn{Column: clause.Column{Table: "Movie", Name: "release_date"}, Desc: true}).Find(&credits).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch people credits", "获取人物作品失败"))
+		return
+	}
+
+	groups := []filmographyDepartment{}
+	index := map[string]int{}
+	for _, credit := range credits {
+		i, ok := index[credit.Department]
+		if !ok {
+			i = len(groups)
+			index[credit.Department] = i
+			groups = append(groups, filmographyDepartment{Department: credit.Department})
+		}
+		groups[i].Credits = append(groups[i].Credits, credit)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return lessDepartment(groups[i].Department, groups[j].Department)
+	})
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// knownForCredit 代表作，score综合电影热度与人物在片中的重要程度
+type knownForCredit struct {
+	models.Credit
+	Score float64 `json:"score"`
+}
+
+// creditProminence 人物在电影中的重要程度，主演和导演、编剧最高
+func creditProminence(credit models.Credit) float64 {
+	if credit.CreditType == "cast" {
+		return 1 / (1 + float64(credit.Order)/3)
+	}
+	switch credit.Department {
+	case "Directing", "Writing":
+		return 1
+	case "Production", "Camera", "Editing", "Sound":
+		return 0.5
+	}
+	return 0.3
+}
+
+// GetPeopleKnownFor 获取人物代表作，按电影热度和角色重要程度排序
+func GetPeopleKnownFor(c *gin.Context) {
+	id := c.Param("id")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "8"))
+	if err != nil || limit < 1 {
+		limit = 8
+	}
+
+	var people models.People
+	if err := requestDB(c).First(&people, id).Error; err != nil {
+		respondFindError(c, err, "people")
+		return
+	}
+
+	var credits []models.Credit
+	if err := requestDB(c).InnerJoins("Movie").Where("credits.people_id = ?", people.ID).Find(&credits).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch people credits", "获取人物作品失败"))
+		return
+	}
+
+	// 同一部电影只保留得分最高的一条
+	best := map[int]knownForCredit{}
+	for _, credit := range credits {
+		if credit.Movie == nil {
+			continue
+		}
+		score := credit.Movie.Popularity * creditProminence(credit)
+		if current, ok := best[credit.MovieID]; !ok || score > current.Score {
+			best[credit.MovieID] = knownForCredit{Credit: credit, Score: score}
+		}
+	}
+
+	results := make([]knownForCredit, 0, len(best))
+	for _, credit := range best {
+		results = append(results, credit)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].MovieID < results[j].MovieID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// collaborator 合作者及共同参与的电影数量
+type collaborator struct {
+	People       models.People `json:"people"`
+	SharedMovies int           `json:"shared_movies"`
+}
+
+// GetPeopleCollaborators 获取与人物共同参与电影最多的合作者
+func GetPeopleCollaborators(c *gin.Context) {
+	id := c.Param("id")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	var people models.People
+	if err := requestDB(c).First(&people, id).Error; err != nil {
+		respondFindError(c, err, "people")
+		return
+	}
+
+	var counts []struct {
+		PeopleID     int
+		SharedMovies int
+	}
+	if err := requestDB(c).Table("credits AS mine").
+		Select("others.people_id AS people_id, COUNT(DISTINCT others.movie_id) AS shared_movies").
+		Joins("JOIN credits AS others ON others.movie_id = mine.movie_id AND others.people_id <> mine.people_id").
+		Joins("JOIN movies ON movies.id = mine.movie_id AND movies.deleted_at IS NULL").
+		Joins("JOIN peoples ON peoples.id = others.people_id AND peoples.deleted_at IS NULL").
+		Where("mine.people_id = ?", people.ID).
+		Group("others.people_id").
+		Order("shared_movies DESC, others.people_id ASC").
+		Limit(limit).Scan(&counts).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch collaborators", "获取合作者失败"))
+		return
+	}
+
+	ids := make([]int, 0, len(counts))
+	for _, count := range counts {
+		ids = append(ids, count.PeopleID)
+	}
+	var peoples []models.People
+	if err := requestDB(c).Where("id IN ?", ids).Find(&peoples).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch collaborators", "获取合作者失败"))
+		return
+	}
+	byID := map[int]models.People{}
+	for _, p := range peoples {
+		byID[p.ID] = p
+	}
+
+	results := make([]collaborator, 0, len(counts))
+	for _, count := range counts {
+		if p, ok := byID[count.PeopleID]; ok {
+			results = append(results, collaborator{People: p, SharedMovies: count.SharedMovies})
+		}
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
 // CreatePeople 创建人物
 func CreatePeople(c *gin.Context) {
 	var People models.People
 	if err := c.ShouldBindJSON(&People); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		respondBindError(c, err)
 		return
 	}
 
-	result := config.DB.Create(&People)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create People"})
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&People).Error; err != nil {
+			return err
+		}
+		return revision.Record(tx, currentActor(c), revision.People, People.ID, nil, &People)
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to create people", "创建人物失败"))
 		return
 	}
 
@@ -98,32 +282,80 @@ func UpdatePeople(c *gin.Context) {
 	id := c.Param("id")
 
 	var People models.People
-	if err := config.DB.First(&People, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "People not found"})
+	if err := requestDB(c).First(&People, id).Error; err != nil {
+		respondFindError(c, err, "people")
+		return
+	}
+	if !checkIfMatch(c, People.Version) {
 		return
 	}
+	peopleID, version := People.ID, People.Version
+	before := People
 
 	if err := c.ShouldBindJSON(&People); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid update data"})
+		respondBindError(c, err)
 		return
 	}
-
-	result := config.DB.Save(&People)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update People"})
+	// 删除时间和同步时间不能通过接口修改，恢复请使用回收站接口
+	People.ID = peopleID
+	People.SyncedAt = before.SyncedAt
+	People.DeletedAt = before.DeletedAt
+	People.Version = version + 1
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		// 只有版本号未变时才更新，避免覆盖其他人的修改
+		result := tx.Model(&People).Where("version = ?", version).
+			Select("*").Omit(clause.Associations, "synced_at", "deleted_at").Updates(&People)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStaleVersion
+		}
+		return recordEdit(tx, c, revision.People, peopleID, &before, &People)
+	})
+	if err == errStaleVersion {
+		respondStaleWrite(c, &models.People{}, peopleID)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to update people", "更新人物失败"))
 		return
 	}
 
+	setETag(c, People.Version)
 	c.JSON(http.StatusOK, People)
 }
 
-// DeletePeople 删除人物
+// DeletePeople 删除人物，人物移入回收站，演职人员记录保留以便恢复
 func DeletePeople(c *gin.Context) {
 	id := c.Param("id")
 
-	result := config.DB.Delete(&models.People{}, id)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete People"})
+	var People models.People
+	if err := requestDB(c).First(&People, id).Error; err != nil {
+		respondFindError(c, err, "people")
+		return
+	}
+	if !checkIfMatch(c, People.Version) {
+		return
+	}
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("version = ?", People.Version).Delete(&People)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errStaleVersion
+		}
+		return revision.Record(tx, currentActor(c), revision.People, People.ID, &People, nil)
+	})
+	if err == errStaleVersion {
+		respondStaleWrite(c, &models.People{}, People.ID)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to delete people", "删除人物失败"))
 		return
 	}
 
@@ -141,9 +373,9 @@ func GetAdminPeople(c *gin.Context) {
 
 	offset := (page - 1) * pageSize
 
-	db := config.DB
+	db := requestDB(c)
 	if searchQuery != "" {
-		db = db.Where("name LIKE ?", "%"+searchQuery+"%")
+		db = dialect.Search(db, searchQuery, "name")
 	}
 
 	// 获取总记录数
@@ -152,7 +384,7 @@ func GetAdminPeople(c *gin.Context) {
 	// 获取分页数据
 	result := db.Offset(offset).Limit(pageSize).Find(&people)
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch people"})
+		apierror.Respond(c, apierror.Internal(result.Error, "Failed to fetch people", "获取人物列表失败"))
 		return
 	}
 
@@ -164,3 +396,17 @@ func GetAdminPeople(c *gin.Context) {
 		"results":     people,
 	})
 }
+
+// GetAdminPeopleDetail 获取单个人物详情（管理后台），响应头中带有当前版本的ETag
+func GetAdminPeopleDetail(c *gin.Context) {
+	id := c.Param("id")
+
+	var People models.People
+	if err := requestDB(c).Preload("Images").First(&People, id).Error; err != nil {
+		respondFindError(c, err, "people")
+		return
+	}
+
+	setETag(c, People.Version)
+	c.JSON(http.StatusOK, People)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/metrics"
+	"github.com/Estella0129/theater/backend/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// 限流规则，由InitRateLimits按rate_limit配置创建
+var (
+	ipLimit       *ratelimit.Bucket
+	userLimit     *ratelimit.Bucket
+	loginLimit    *ratelimit.Bucket
+	registerLimit *ratelimit.Bucket
+	imagesLimit   *ratelimit.Bucket
+	loginLockout  *ratelimit.Lockout
+	userLockout   *ratelimit.Lockout
+)
+
+// InitRateLimits 按rate_limit配置创建限流规则，store为database时状态保存在config.DB中
+func InitRateLimits() {
+	cfg := config.AppConfig.RateLimit
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.Store == config.RateLimitDatabase {
+		store = ratelimit.NewDBStore(config.DB)
+	}
+	bucket := func(name string, b config.TokenBucket) *ratelimit.Bucket {
+		return ratelimit.NewBucket(store, name, b.Rate, b.Period, b.Burst)
+	}
+
+	ipLimit = bucket("ip", cfg.IP)
+	userLimit = bucket("user", cfg.User)
+	loginLimit = bucket("login", cfg.Login)
+	registerLimit = bucket("register", cfg.Register)
+	imagesLimit = bucket("images", cfg.Images)
+	loginLockout = ratelimit.NewLockout(store, cfg.Lockout.MaxFailures, cfg.Lockout.Duration, cfg.Lockout.MaxDuration)
+	userLockout = ratelimit.NewLockout(store, cfg.Lockout.UserMaxFailures, cfg.Lockout.Duration, cfg.Lockout.MaxDuration)
+}
+
+// RateLimit API的限流中间件，每个IP和每个登录用户分别限流。
+// 带有有效token的请求同时计入IP和用户，伪造token不能绕过IP限制
+func RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ratelimit.Allow(c, ipLimit, ratelimit.ClientIP(c)) || !ratelimit.Allow(c, userLimit, tokenUser(c)) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// LoginRateLimit 每个IP的登录请求限流
+func LoginRateLimit() gin.HandlerFunc {
+	return ratelimit.Middleware(loginLimit, ratelimit.ClientIP)
+}
+
+// RegisterRateLimit 每个IP的注册请求限流
+func RegisterRateLimit() gin.HandlerFunc {
+	return ratelimit.Middleware(registerLimit, ratelimit.ClientIP)
+}
+
+// AllowImageDownload 每个IP从TMDB下载本地没有的图片的限流，超过限制时返回429和false
+func AllowImageDownload(c *gin.Context) bool {
+	return ratelimit.Allow(c, imagesLimit, ratelimit.ClientIP(c))
+}
+
+// tokenUser 请求中有效token的用户ID，没有登录时为空。
+// 只信任用配置的密钥验证通过的token，未配置密钥时不解析，避免伪造的用户ID绕过IP限流或冒充操作者
+func tokenUser(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if header == "" || config.JWTSecret == "" {
+		return ""
+	}
+	claims, err := parseToken(header)
+	if err != nil || claims["user_id"] == nil {
+		return ""
+	}
+	return formatUserID(claims["user_id"])
+}
+
+// lockoutKey 登录锁定按用户名和客户端IP计数，其他IP的失败不会锁定该用户，避免任何人都能锁定别人的账号。
+// 用户名不区分大小写，避免在大小写不敏感的数据库上绕过
+func lockoutKey(c *gin.Context, username string) string {
+	return strings.ToLower(username) + "@" + ratelimit.ClientIP(c)
+}
+
+// userLockoutKey 不区分IP按用户名计数，阈值较高，防止从大量IP分散猜测同一用户的密码
+func userLockoutKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+// checkLoginLockout 用户名在当前IP或在所有IP上被锁定时返回429和false
+func checkLoginLockout(c *gin.Context, username string) bool {
+	ctx := c.Request.Context()
+	wait, err := loginLockout.Check(ctx, lockoutKey(c, username))
+	if err != nil {
+		slog.WarnContext(ctx, "读取登录锁定状态失败", "error", err)
+	}
+	userWait, err := userLockout.Check(ctx, userLockoutKey(username))
+	if err != nil {
+		slog.WarnContext(ctx, "读取登录锁定状态失败", "error", err)
+	}
+	if wait = max(wait, userWait); wait > 0 {
+		respondLockedOut(c, wait)
+		return false
+	}
+	return true
+}
+
+// respondLoginFailed 用户名或密码错误，记录一次失败，达到次数后返回锁定信息。
+// 用户不存在时同样计数，不能据此判断用户名是否存在
+func respondLoginFailed(c *gin.Context, username string) {
+	ctx := c.Request.Context()
+	locked, err := loginLockout.Fail(ctx, lockoutKey(c, username))
+	if err != nil {
+		slog.WarnContext(ctx, "记录登录失败次数失败", "error", err)
+	}
+	userLocked, err := userLockout.Fail(ctx, userLockoutKey(username))
+	if err != nil {
+		slog.WarnContext(ctx, "记录登录失败次数失败", "error", err)
+	}
+	if locked = max(locked, userLocked); locked > 0 {
+		slog.WarnContext(c.Request.Context(), "登录失败次数过多，暂时锁定", "username", username,
+			"client_ip", ratelimit.ClientIP(c), "duration", locked)
+		respondLockedOut(c, locked)
+		return
+	}
+	apierror.Respond(c, apierror.Unauthorized("Invalid credentials", "用户名或密码错误"))
+}
+
+// resetLoginLockout 登录成功后清除失败次数
+func resetLoginLockout(c *gin.Context, username string) {
+	ctx := c.Request.Context()
+	if err := loginLockout.Reset(ctx, lockoutKey(c, username)); err != nil {
+		slog.WarnContext(ctx, "清除登录失败次数失败", "error", err)
+	}
+	if err := userLockout.Reset(ctx, userLockoutKey(username)); err != nil {
+		slog.WarnContext(ctx, "清除登录失败次数失败", "error", err)
+	}
+}
+
+func respondLockedOut(c *gin.Context, wait time.Duration) {
+	metrics.RateLimited.WithLabelValues("lockout").Inc()
+	ratelimit.Reject(c, wait, apierror.New(http.StatusTooManyRequests, apierror.CodeTooManyRequests,
+		"Too many failed login attempts, please try again later",
+		"登录失败次数过多，请"+ratelimit.RetryMessage(wait)+"后再试"))
+}
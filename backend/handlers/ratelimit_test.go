@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLoginLockout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldLogin, oldUser := loginLockout, userLockout
+	store := ratelimit.NewMemoryStore()
+	loginLockout = ratelimit.NewLockout(store, 2, time.Minute, time.Hour)
+	userLockout = ratelimit.NewLockout(store, 4, time.Minute, time.Hour)
+	t.Cleanup(func() { loginLockout, userLockout = oldLogin, oldUser })
+
+	// 模拟登录失败，已锁定时返回429
+	r := gin.New()
+	r.POST("/login/:username", func(c *gin.Context) {
+		if checkLoginLockout(c, c.Param("username")) {
+			respondLoginFailed(c, c.Param("username"))
+		}
+	})
+	login := func(username, ip string) int {
+		req := httptest.NewRequest(http.MethodPost, "/login/"+username, nil)
+		req.RemoteAddr = ip + ":12345"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	steps := []struct {
+		name     string
+		username string
+		ip       string
+		want     int
+	}{
+		{"第一次失败", "alice", "10.0.0.1", http.StatusUnauthorized},
+		{"达到次数后锁定", "alice", "10.0.0.1", http.StatusTooManyRequests},
+		{"锁定期间", "Alice", "10.0.0.1", http.StatusTooManyRequests},
+		{"其他IP不受影响", "alice", "10.0.0.2", http.StatusUnauthorized},
+		{"同一IP的其他用户不受影响", "bob", "10.0.0.1", http.StatusUnauthorized},
+		{"所有IP合计达到次数后锁定用户名", "alice", "10.0.0.3", http.StatusTooManyRequests},
+		{"用户名锁定后新的IP同样锁定", "alice", "10.0.0.4", http.StatusTooManyRequests},
+		{"其他用户不受用户名锁定影响", "bob", "10.0.0.4", http.StatusUnauthorized},
+	}
+	for _, step := range steps {
+		if got := login(step.username, step.ip); got != step.want {
+			t.Errorf("%s: 状态码为%d，应为%d", step.name, got, step.want)
+		}
+	}
+}
+
+func TestTokenUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token := "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret))
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		want   string
+	}{
+		{"有效token", testJWTSecret, token, "1"},
+		{"其他密钥签名", "another-secret-0123456789abcdefghij", token, ""},
+		{"服务端未配置密钥", "", "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("")), ""},
+		{"没有token", testJWTSecret, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := config.JWTSecret
+			config.JWTSecret = tt.secret
+			t.Cleanup(func() { config.JWTSecret = old })
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("Authorization", tt.header)
+			}
+			if got := tokenUser(c); got != tt.want {
+				t.Errorf("用户为%q，应为%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/fieldlock"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// currentActor 当前请求的操作者，用于修改记录。
+// 管理接口没有挂载AuthMiddleware，未经中间件验证时从请求的token中取用户，没有有效token时为admin
+func currentActor(c *gin.Context) string {
+	if userID := currentUserID(c); userID != "" {
+		return "user:" + userID
+	}
+	return revision.ActorAdmin
+}
+
+// currentUserID 当前登录用户的ID，没有登录时为空。
+// 经过AuthMiddleware时使用其解析的结果，否则解析请求中的token
+func currentUserID(c *gin.Context) string {
+	if userID, ok := c.Get("user_id"); ok {
+		return formatUserID(userID)
+	}
+	return tokenUser(c)
+}
+
+// requestDB 带有当前请求context的数据库连接，SQL日志中会带有请求ID。
+// 不随请求取消，请求中启动的后台任务也可以使用
+func requestDB(c *gin.Context) *gorm.DB {
+	return config.DB.WithContext(context.WithoutCancel(c.Request.Context()))
+}
+
+// revisionEntity 支持修改记录和回滚的实体
+type revisionEntity struct {
+	newModel func() interface{}
+	version  func(model interface{}) int // 乐观锁版本号，为空表示没有版本号
+}
+
+var revisionEntities = map[string]revisionEntity{
+	revision.Movie: {
+		func() interface{} { return &models.Movie{} },
+		func(model interface{}) int { return model.(*models.Movie).Version },
+	},
+	revision.People: {
+		func() interface{} { return &models.People{} },
+		func(model interface{}) int { return model.(*models.People).Version },
+	},
+	revision.Genre: {
+		func() interface{} { return &models.Genre{} },
+		func(model interface{}) int { return model.(*models.Genre).Version },
+	},
+	revision.Collection: {func() interface{} { return &models.Collection{} }, nil},
+}
+
+// revisionResponse 修改记录的响应，diff和snapshot以JSON对象返回
+type revisionResponse struct {
+	models.Revision
+	Diff     json.RawMessage `json:"diff"`
+	Snapshot json.RawMessage `json:"snapshot,omitempty"`
+}
+
+func newRevisionResponse(r models.Revision, withSnapshot bool) revisionResponse {
+	response := revisionResponse{Revision: r, Diff: json.RawMessage(r.Diff)}
+	if withSnapshot {
+		response.Snapshot = json.RawMessage(r.Snapshot)
+	}
+	return response
+}
+
+// GetRevisions 返回获取实体修改记录列表的处理函数，按时间倒序分页
+func GetRevisions(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+		if err != nil || pageSize < 1 {
+			pageSize = 20
+		}
+
+		var revisions []models.Revision
+		var total int64
+
+		dbQuery := requestDB(c).Model(&models.Revision{}).Where("entity_type = ? AND entity_id = ?", entityType, id)
+		if err := dbQuery.Count(&total).Error; err != nil {
+			apierror.Respond(c, apierror.Internal(err, "Failed to count revisions", "获取修改记录总数失败"))
+			return
+		}
+		if err := dbQuery.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&revisions).Error; err != nil {
+			apierror.Respond(c, apierror.Internal(err, "Failed to fetch revisions", "获取修改记录失败"))
+			return
+		}
+
+		results := make([]revisionResponse, 0, len(revisions))
+		for _, r := range revisions {
+			results = append(results, newRevisionResponse(r, false))
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+			"results":     results,
+		})
+	}
+}
+
+// GetRevision 获取单条修改记录，current_diff为回滚到该记录时当前数据将发生的变化。
+// 实体有版本号时响应头中带有实体当前版本的ETag，回滚时作为If-Match
+func GetRevision(c *gin.Context) {
+	id := c.Param("id")
+
+	var r models.Revision
+	if err := requestDB(c).First(&r, id).Error; err != nil {
+		respondFindError(c, err, "revision")
+		return
+	}
+
+	entity, ok := revisionEntities[r.EntityType]
+	if !ok {
+		apierror.Respond(c, apierror.BadRequest("Unsupported entity type", "不支持的实体类型"))
+		return
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal([]byte(r.Snapshot), &snapshot); err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to parse revision", "解析修改记录失败"))
+		return
+	}
+
+	current := entity.newModel()
+	currentFields := map[string]interface{}{}
+	if err := requestDB(c).Unscoped().First(current, "id = ?", r.EntityID).Error; err == nil {
+		if currentFields, err = revision.Fields(current); err != nil {
+			apierror.Respond(c, apierror.Internal(err, "Failed to parse current data", "解析当前数据失败"))
+			return
+		}
+		if entity.version != nil {
+			setETag(c, entity.version(current))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revision":     newRevisionResponse(r, true),
+		"current_diff": revision.Diff(currentFields, snapshot),
+	})
+}
+
+// RollbackRevision 将实体恢复为修改记录中的状态，已删除的实体会被重新创建。
+// 实体存在且有版本号时需要If-Match，与编辑一样避免覆盖其他人的修改
+func RollbackRevision(c *gin.Context) {
+	id := c.Param("id")
+
+	var r models.Revision
+	if err := requestDB(c).First(&r, id).Error; err != nil {
+		respondFindError(c, err, "revision")
+		return
+	}
+
+	entity, ok := revisionEntities[r.EntityType]
+	if !ok {
+		apierror.Respond(c, apierror.BadRequest("Unsupported entity type", "不支持的实体类型"))
+		return
+	}
+
+	// 实体已被彻底删除时没有版本号，直接重新创建
+	version := 0
+	if entity.version != nil {
+		current := entity.newModel()
+		err := requestDB(c).Unscoped().First(current, "id = ?", r.EntityID).Error
+		switch {
+		case err == nil:
+			version = entity.version(current)
+			if !checkIfMatch(c, version) {
+				return
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			apierror.Respond(c, apierror.Internal(err, "Failed to revert", "回滚失败"))
+			return
+		}
+	}
+
+	var created *models.Revision
+	var after interface{}
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		var before interface{}
+		current := entity.newModel()
+		err := tx.Unscoped().First(current, "id = ?", r.EntityID).Error
+		switch {
+		case err == nil:
+			before = current
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		// 回滚同样是一次修改，只有版本号未变时版本号加1，使持有旧版本的编辑失效
+		if before != nil && entity.version != nil {
+			result := tx.Unscoped().Model(current).Where("version = ?", version).
+				UpdateColumn("version", gorm.Expr("version + 1"))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errStaleVersion
+			}
+		}
+
+		target := entity.newModel()
+		if err := json.Unmarshal([]byte(r.Snapshot), target); err != nil {
+			return err
+		}
+
+		if before != nil {
+			if err := tx.Unscoped().Model(target).Select("*").
+				Omit(clause.Associations, "created_at", "version").Updates(target).Error; err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Omit(clause.Associations).Create(target).Error; err != nil {
+				return err
+			}
+			// 重新创建的实体版本号同样加1，删除前持有的版本失效
+			if entity.version != nil {
+				if err := tx.Unscoped().Model(target).UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		after = entity.newModel()
+		if err := tx.Unscoped().First(after, "id = ?", r.EntityID).Error; err != nil {
+			return err
+		}
+
+		created, err = revision.New(currentActor(c), r.EntityType, r.EntityID, before, after)
+		if err != nil || created == nil {
+			return err
+		}
+		created.Action = revision.ActionRollback
+		if err := tx.Create(created).Error; err != nil {
+			return err
+		}
+		// 回滚同样视为手动修改，锁定恢复的字段
+		if before != nil {
+			return fieldlock.LockChanged(tx, created.Actor, r.EntityType, r.EntityID, before, after)
+		}
+		return nil
+	})
+	if err == errStaleVersion {
+		respondStaleWrite(c, entity.newModel(), r.EntityID)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to revert", "回滚失败"))
+		return
+	}
+
+	if entity.version != nil {
+		setETag(c, entity.version(after))
+	}
+	if created == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "数据与该记录一致，无需回滚"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "回滚成功",
+		"revision": newRevisionResponse(*created, true),
+	})
+}
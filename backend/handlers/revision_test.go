@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestCurrentActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := config.JWTSecret
+	config.JWTSecret = testJWTSecret
+	t.Cleanup(func() { config.JWTSecret = old })
+
+	bigID, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 1000000,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		userID interface{} // AuthMiddleware设置的user_id，为空表示没有经过中间件
+		want   string
+	}{
+		{"中间件设置的用户", "", float64(7), "user:7"},
+		{"请求中的token", "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret)), nil, "user:1"},
+		{"较大的用户ID", "Bearer " + bigID, nil, "user:1000000"},
+		{"其他密钥签名的token", "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("another-secret-0123456789abcdefghij")), nil, revision.ActorAdmin},
+		{"没有token", "", nil, revision.ActorAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("Authorization", tt.header)
+			}
+			if tt.userID != nil {
+				c.Set("user_id", tt.userID)
+			}
+			if got := currentActor(c); got != tt.want {
+				t.Errorf("操作者为%q，应为%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/fieldlock"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashEntity 支持回收站的实体，删除时只做软删除，关联数据保留以便恢复
+type trashEntity struct {
+	revisionType string // 修改记录中的实体类型，为空表示不记录修改
+	newModel     func() interface{}
+	newList      func() interface{}
+	omit         []string                           // 列表中不返回的字段
+	unversioned  bool                               // 没有乐观锁版本号，恢复时不修改
+	purge        func(tx *gorm.DB, id string) error // 永久删除前清理关联数据
+}
+
+var trashEntities = map[string]trashEntity{
+	"movies": {
+		revisionType: revision.Movie,
+		newModel:     func() interface{} { return &models.Movie{} },
+		newList:      func() interface{} { return &[]models.Movie{} },
+		purge:        purgeMovie,
+	},
+	"people": {
+		revisionType: revision.People,
+		newModel:     func() interface{} { return &models.People{} },
+		newList:      func() interface{} { return &[]models.People{} },
+		purge:        purgePeople,
+	},
+	"genres": {
+		revisionType: revision.Genre,
+		newModel:     func() interface{} { return &models.Genre{} },
+		newList:      func() interface{} { return &[]models.Genre{} },
+		purge:        purgeGenre,
+	},
+	"collections": {
+		revisionType: revision.Collection,
+		newModel:     func() interface{} { return &models.Collection{} },
+		newList:      func() interface{} { return &[]models.Collection{} },
+		unversioned:  true,
+		purge:        purgeCollection,
+	},
+	"users": {
+		newModel: func() interface{} { return &models.User{} },
+		newList:  func() interface{} { return &[]models.User{} },
+		omit:     []string{"password"},
+		purge:    purgeUser,
+	},
+}
+
+// purgeMovie 删除电影的演职人员、类型、图片、合集、收藏关联和字段锁，修改记录保留
+func purgeMovie(tx *gorm.DB, id string) error {
+	if err := tx.Where("movie_id = ?", id).Delete(&models.Credit{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("movie_id = ?", id).Delete(&models.MovieGenre{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("movie_id = ?", id).Delete(&models.MovieImage{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("movie_id = ?", id).Delete(&models.CollectionMovie{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM user_favorite_movies WHERE movie_id = ?", id).Error; err != nil {
+		return err
+	}
+	return fieldlock.Clear(tx, revision.Movie, id)
+}
+
+// purgePeople 删除人物的演职人员记录、图片关联和字段锁，修改记录保留
+func purgePeople(tx *gorm.DB, id string) error {
+	if err := tx.Where("people_id = ?", id).Delete(&models.Credit{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("people_id = ?", id).Delete(&models.PeopleImage{}).Error; err != nil {
+		return err
+	}
+	return fieldlock.Clear(tx, revision.People, id)
+}
+
+// purgeGenre 删除类型与电影的关联和字段锁，修改记录保留
+func purgeGenre(tx *gorm.DB, id string) error {
+	if err := tx.Where("genre_id = ?", id).Delete(&models.MovieGenre{}).Error; err != nil {
+		return err
+	}
+	return fieldlock.Clear(tx, revision.Genre, id)
+}
+
+// purgeCollection 删除合集的电影和图片关联、电影所属的TMDB合集和字段锁，修改记录保留
+func purgeCollection(tx *gorm.DB, id string) error {
+	if err := tx.Where("collection_id = ?", id).Delete(&models.CollectionMovie{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("collection_id = ?", id).Delete(&models.CollectionImage{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&models.Movie{}).Where("collection_id = ?", id).Update("collection_id", nil).Error; err != nil {
+		return err
+	}
+	return fieldlock.Clear(tx, revision.Collection, id)
+}
+
+// purgeUser 删除用户的收藏
+func purgeUser(tx *gorm.DB, id string) error {
+	return tx.Exec("DELETE FROM user_favorite_movies WHERE user_id = ?", id).Error
+}
+
+// errNotInTrash 数据不存在或未被删除
+var errNotInTrash = errors.New("回收站中没有该数据")
+
+// findInTrash 在回收站中查找实体
+func findInTrash(tx *gorm.DB, entity trashEntity, id string) (interface{}, error) {
+	model := entity.newModel()
+	err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(model, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNotInTrash
+	}
+	return model, err
+}
+
+// GetTrash 获取回收站中的数据，按删除时间倒序分页
+func GetTrash(c *gin.Context) {
+	entity, ok := trashEntities[c.Param("type")]
+	if !ok {
+		apierror.Respond(c, apierror.NotFound("trash_type"))
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+
+	var total int64
+	dbQuery := requestDB(c).Unscoped().Model(entity.newModel()).Where("deleted_at IS NOT NULL")
+	if err := dbQuery.Count(&total).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to count trash", "获取回收站总数失败"))
+		return
+	}
+
+	results := entity.newList()
+	if len(entity.omit) > 0 {
+		dbQuery = dbQuery.Omit(entity.omit...)
+	}
+	if err := dbQuery.Order("deleted_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(results).Error; err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to fetch trash", "获取回收站失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"page":        page,
+		"page_size":   pageSize,
+		"total":       total,
+		"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		"results":     results,
+	})
+}
+
+// RestoreTrash 从回收站恢复数据，软删除时保留的关联数据随之恢复
+func RestoreTrash(c *gin.Context) {
+	entity, ok := trashEntities[c.Param("type")]
+	if !ok {
+		apierror.Respond(c, apierror.NotFound("trash_type"))
+		return
+	}
+	id := c.Param("id")
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		return restoreFromTrash(tx, entity, id, currentActor(c))
+	})
+	if err == errNotInTrash {
+		apierror.Respond(c, apierror.NotFound("trash").Wrap(err))
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to restore", "恢复失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "恢复成功"})
+}
+
+// restoreFromTrash 恢复回收站中的实体，版本号加1并记录修改
+func restoreFromTrash(tx *gorm.DB, entity trashEntity, id string, actor string) error {
+	model, err := findInTrash(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	// 恢复同样是一次修改，版本号加1
+	updates := map[string]interface{}{"deleted_at": nil}
+	if !entity.unversioned {
+		updates["version"] = gorm.Expr("version + 1")
+	}
+	if err := tx.Unscoped().Model(model).Updates(updates).Error; err != nil {
+		return err
+	}
+	if entity.revisionType == "" {
+		return nil
+	}
+
+	restored := entity.newModel()
+	if err := tx.First(restored, "id = ?", id).Error; err != nil {
+		return err
+	}
+	r, err := revision.New(actor, entity.revisionType, id, nil, restored)
+	if err != nil {
+		return err
+	}
+	r.Action = revision.ActionRestore
+	return tx.Create(r).Error
+}
+
+// PurgeTrash 永久删除回收站中的数据及其关联数据，只能删除已在回收站中的数据
+func PurgeTrash(c *gin.Context) {
+	entity, ok := trashEntities[c.Param("type")]
+	if !ok {
+		apierror.Respond(c, apierror.NotFound("trash_type"))
+		return
+	}
+	id := c.Param("id")
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		model, err := findInTrash(tx, entity, id)
+		if err != nil {
+			return err
+		}
+		if err := entity.purge(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(model).Error; err != nil {
+			return err
+		}
+		if entity.revisionType == "" {
+			return nil
+		}
+
+		r, err := revision.New(currentActor(c), entity.revisionType, id, model, nil)
+		if err != nil {
+			return err
+		}
+		r.Action = revision.ActionPurge
+		return tx.Create(r).Error
+	})
+	if err == errNotInTrash {
+		apierror.Respond(c, apierror.NotFound("trash").Wrap(err))
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to purge", "永久删除失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "永久删除成功"})
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -8,9 +10,12 @@ import (
 
 	"github.com/Estella0129/theater/backend/config"
 	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // AuthMiddleware JWT验证中间件
@@ -18,63 +23,120 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			apierror.Respond(c, apierror.Unauthorized("Authorization header is required", "请先登录"))
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(config.JWTSecret), nil
-		})
-
+		claims, err := parseToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			apierror.Respond(c, apierror.Unauthorized("Invalid token", "登录信息无效，请重新登录").Wrap(err))
 			return
 		}
+		c.Set("user_id", claims["user_id"])
+		c.Set("role", claims["role"])
+		c.Next()
+	}
+}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			c.Set("user_id", claims["user_id"])
-			c.Set("role", claims["role"])
-			c.Next()
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+// AdminMiddleware 只允许管理员访问，需要在AuthMiddleware之后使用。
+// 每次请求从数据库读取用户，被降级、冻结或删除的管理员的token立即失效
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := c.Get("user_id")
+		if !ok {
+			apierror.Respond(c, apierror.Unauthorized("Authorization header is required", "请先登录"))
+			return
+		}
+		var user models.User
+		if err := requestDB(c).Select("id", "role", "is_frozen").First(&user, "id = ?", formatUserID(userID)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Respond(c, apierror.Unauthorized("User no longer exists", "用户不存在，请重新登录"))
+				return
+			}
+			apierror.Respond(c, apierror.Internal(err, "Failed to fetch user", "获取用户信息失败"))
+			return
+		}
+		if user.Role != "admin" || user.IsFrozen {
+			apierror.Respond(c, apierror.Forbidden("Administrator permission required", "需要管理员权限"))
 			return
 		}
+		c.Next()
+	}
+}
+
+// jwtKey JWT的HMAC密钥，没有加载配置或密钥过短时不能用于签名或校验
+func jwtKey() ([]byte, error) {
+	if err := config.CheckJWTSecret(); err != nil {
+		return nil, err
+	}
+	return []byte(config.JWTSecret), nil
+}
+
+// parseToken 校验Authorization请求头中的JWT，可以带有Bearer前缀
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	tokenString = strings.TrimSpace(strings.TrimPrefix(tokenString, "Bearer "))
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return jwtKey()
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
 	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// formatUserID token中的user_id，JSON数字解析为float64，按整数格式化避免大ID变成1e+06
+func formatUserID(userID interface{}) string {
+	if id, ok := userID.(float64); ok {
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	}
+	return fmt.Sprint(userID)
+}
+
+// registerRequest 用户注册的请求数据，角色、冻结状态等只能由管理员修改
+type registerRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=20"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	Name     string `json:"name" binding:"omitempty,min=2,max=20"`
 }
 
-// RegisterUser 用户注册
+// RegisterUser 用户注册，注册的用户总是普通用户
 func RegisterUser(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+	var req registerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
 		return
 	}
+	user := models.User{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+		Name:     req.Name,
+		Role:     "user",
+	}
 
 	// 密码加密
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to process password", "密码处理失败"))
 		return
 	}
 	user.Password = string(hashedPassword)
 
 	// 创建用户
-	result := config.DB.Create(&user)
+	result := requestDB(c).Create(&user)
 	if result.Error != nil {
-		if strings.Contains(result.Error.Error(), "UNIQUE constraint failed: users.username") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
-			return
-		} else if strings.Contains(result.Error.Error(), "UNIQUE constraint failed: users.email") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		if respondDuplicateUser(c, result.Error, 0, user.Username, user.Email) {
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user: " + result.Error.Error()})
+		apierror.Respond(c, apierror.Internal(result.Error, "Failed to create user", "创建用户失败"))
 		return
 	}
 
@@ -83,50 +145,62 @@ func RegisterUser(c *gin.Context) {
 	c.JSON(http.StatusCreated, user)
 }
 
+// loginRequest 用户登录的请求数据
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
 // LoginUser 用户登录
 func LoginUser(c *gin.Context) {
-	var loginData struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
+	var loginData loginRequest
 
 	if err := c.ShouldBindJSON(&loginData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login data"})
+		respondBindError(c, err)
+		return
+	}
+	// 连续失败次数过多的用户名暂时不能登录
+	if !checkLoginLockout(c, loginData.Username) {
 		return
 	}
+
 	//查询用户
 	var user models.User
-	result := config.DB.Where("username = ?", loginData.Username).First(&user)
+	result := requestDB(c).Where("username = ?", loginData.Username).First(&user)
 	if result.Error != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		respondLoginFailed(c, loginData.Username)
 		return
 	}
 
-	// 检查用户是否被冻结
-	if user.IsFrozen {
-		c.JSON(http.StatusForbidden, gin.H{"error": "用户已被冻结，请联系管理员"})
+	// 先验证密码，密码错误时同样计入失败次数，冻结状态只告诉知道密码的人
+	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginData.Password))
+	if err != nil {
+		respondLoginFailed(c, loginData.Username)
 		return
 	}
+	resetLoginLockout(c, loginData.Username)
 
-	// 验证密码
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginData.Password))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	// 检查用户是否被冻结
+	if user.IsFrozen {
+		apierror.Respond(c, apierror.Forbidden("User is frozen, contact the administrator", "用户已被冻结，请联系管理员"))
 		return
 	}
 
-	// 生成JWT token
-	Role := 0
-	userID := 0
+	// 生成JWT token，user_id用于按用户限流
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"role":    Role,
+		"user_id": user.ID,
+		"role":    user.Role,
 		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 24小时后过期
 	})
 
-	tokenString, err := token.SignedString([]byte(config.JWTSecret))
+	key, err := jwtKey()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to generate token", "生成登录凭证失败"))
+		return
+	}
+	tokenString, err := token.SignedString(key)
+	if err != nil {
+		apierror.Respond(c, apierror.Internal(err, "Failed to generate token", "生成登录凭证失败"))
 		return
 	}
 
@@ -149,12 +223,12 @@ func GetUsers(c *gin.Context) {
 	offset := (page - 1) * pageSize
 
 	// 获取总记录数
-	config.DB.Model(&models.User{}).Count(&total)
+	requestDB(c).Model(&models.User{}).Count(&total)
 
 	// 获取分页数据
-	result := config.DB.Select("id, username, name, email, role, gender, created_at, updated_at").Offset(offset).Limit(pageSize).Find(&users)
+	result := requestDB(c).Select("id, username, name, email, role, gender, is_frozen, version, created_at, updated_at").Offset(offset).Limit(pageSize).Find(&users)
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		apierror.Respond(c, apierror.Internal(result.Error, "Failed to fetch users", "获取用户列表失败"))
 		return
 	}
 
@@ -172,9 +246,9 @@ func GetUser(c *gin.Context) {
 	id := c.Param("id")
 
 	var user models.User
-	result := config.DB.Select("id, username, name, email, role, gender, created_at, updated_at").First(&user, id)
+	result := requestDB(c).Select("id, username, name, email, role, gender, is_frozen, version, created_at, updated_at").First(&user, id)
 	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondFindError(c, result.Error, "user")
 		return
 	}
 
@@ -186,133 +260,220 @@ func ToggleFreezeUser(c *gin.Context) {
 	id := c.Param("id")
 
 	var user models.User
-	if err := config.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := requestDB(c).First(&user, id).Error; err != nil {
+		respondFindError(c, err, "user")
+		return
+	}
+	if !checkIfMatch(c, user.Version) {
 		return
 	}
 
 	// 切换冻结状态
-	user.IsFrozen = !user.IsFrozen
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
+	if !updateUserVersioned(c, &user, map[string]interface{}{"is_frozen": !user.IsFrozen}) {
 		return
 	}
 
+	setETag(c, user.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message":   "User status updated successfully",
 		"is_frozen": user.IsFrozen,
+		"version":   user.Version,
 	})
 }
 
+// respondDuplicateUser 用户名或邮箱与其他用户重复时返回409，已删除的用户也占用用户名和邮箱。
+// 不同数据库的唯一约束错误信息不同，通过查询判断是哪个字段重复
+func respondDuplicateUser(c *gin.Context, err error, id uint, username, email string) bool {
+	if !dialect.IsDuplicateKey(err) {
+		return false
+	}
+
+	var count int64
+	if username != "" {
+		requestDB(c).Unscoped().Model(&models.User{}).Where("username = ? AND id <> ?", username, id).Count(&count)
+		if count > 0 {
+			apierror.Respond(c, apierror.Conflict("Username already exists", "用户名已存在").Wrap(err).
+				WithField("username", "unique", "is already taken", "用户名已存在"))
+			return true
+		}
+	}
+	if email != "" {
+		requestDB(c).Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", email, id).Count(&count)
+		if count > 0 {
+			apierror.Respond(c, apierror.Conflict("Email already exists", "邮箱已存在").Wrap(err).
+				WithField("email", "unique", "is already taken", "邮箱已存在"))
+			return true
+		}
+	}
+	apierror.Respond(c, apierror.Conflict("User already exists", "用户已存在").Wrap(err))
+	return true
+}
+
+// userUpdate 管理员更新用户信息的请求数据，为空的字段不修改
+type userUpdate struct {
+	Username string `json:"username" binding:"omitempty,min=3,max=20"`
+	Name     string `json:"name" binding:"omitempty,min=2,max=20"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	Role     string `json:"role" binding:"omitempty,oneof=user admin"`
+	Gender   string `json:"gender" binding:"omitempty,oneof=male female"`
+}
+
 // UpdateUser 更新用户信息
 func UpdateUser(c *gin.Context) {
 	id := c.Param("id")
 
 	var user models.User
-	if err := config.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := requestDB(c).First(&user, id).Error; err != nil {
+		respondFindError(c, err, "user")
 		return
 	}
-
-	var updateData struct {
-		Username string `json:"username"`
-		Name     string `json:"name"`
-		Email    string `json:"email"`
-		Role     string `json:"role"`
-		Gender   string `json:"gender"`
-		IsFrozen bool   `json:"is_frozen"`
+	if !checkIfMatch(c, user.Version) {
+		return
 	}
 
+	var updateData userUpdate
+
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid update data"})
+		respondBindError(c, err)
 		return
 	}
 
-	// 更新用户信息（不改变冻结状态）
-	user.Name = updateData.Name
-	user.Email = updateData.Email
-	user.Gender = updateData.Gender
-
-	result := config.DB.Model(&user).Updates(models.User{
+	// 只更新非空的字段，冻结状态由ToggleFreezeUser修改；
+	// 只有版本号未变时才更新，避免覆盖其他人的修改
+	version := user.Version
+	result := requestDB(c).Model(&user).Where("version = ?", version).Updates(models.User{
 		Username: updateData.Username,
 		Name:     updateData.Name,
 		Email:    updateData.Email,
 		Role:     updateData.Role,
 		Gender:   updateData.Gender,
+		Version:  version + 1,
 	})
 
+	if result.Error == nil && result.RowsAffected == 0 {
+		respondStaleWrite(c, &models.User{}, user.ID)
+		return
+	}
 	if result.Error != nil {
-		if strings.Contains(result.Error.Error(), "UNIQUE constraint failed: users.username") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
-			return
-		} else if strings.Contains(result.Error.Error(), "UNIQUE constraint failed: users.email") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		if respondDuplicateUser(c, result.Error, user.ID, updateData.Username, updateData.Email) {
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user: " + result.Error.Error()})
+		apierror.Respond(c, apierror.Internal(result.Error, "Failed to update user", "更新用户失败"))
 		return
 	}
 
+	user.Password = ""
+	setETag(c, user.Version)
 	c.JSON(http.StatusOK, user)
 }
 
+// passwordUpdate 修改密码的请求数据
+type passwordUpdate struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+	ConfirmPassword string `json:"confirm_password" binding:"required"`
+}
+
 func UpdatePassword(c *gin.Context) {
 	id := c.Param("id")
 
 	var user models.User
-	if err := config.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := requestDB(c).First(&user, id).Error; err != nil {
+		respondFindError(c, err, "user")
 		return
 	}
-
-	var passwordData struct {
-		CurrentPassword string `json:"current_password" binding:"required"`
-		NewPassword     string `json:"new_password" binding:"required"`
-		ConfirmPassword string `json:"confirm_password" binding:"required"`
+	if !checkIfMatch(c, user.Version) {
+		return
 	}
 
+	var passwordData passwordUpdate
+
 	if err := c.ShouldBindJSON(&passwordData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid password data"})
+		respondBindError(c, err)
 		return
 	}
 
 	// 验证两次新密码是否一致
 	if passwordData.NewPassword != passwordData.ConfirmPassword {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "New passwords do not match"})
+		apierror.Respond(c, apierror.Invalid().WithField("confirm_password", "eqfield", "does not match new_password", "两次输入的新密码不一致"))
 		return
 	}
 
 	// 验证当前密码
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwordData.CurrentPassword))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		apierror.Respond(c, apierror.Invalid().Wrap(err).WithField("current_password", "incorrect", "is incorrect", "当前密码不正确"))
 		return
 	}
 
 	// 加密新密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(passwordData.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to process password", "密码处理失败"))
 		return
 	}
 
 	// 更新密码
-	user.Password = string(hashedPassword)
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+	if !updateUserVersioned(c, &user, map[string]interface{}{"password": string(hashedPassword)}) {
+		return
+	}
+
+	setETag(c, user.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully", "version": user.Version})
+}
+
+// updateUserVersioned 在版本号未变时更新用户字段并将版本号加1，失败时写入错误响应并返回false
+func updateUserVersioned(c *gin.Context, user *models.User, values map[string]interface{}) bool {
+	version := user.Version
+	values["version"] = version + 1
+
+	result := requestDB(c).Model(user).Where("version = ?", version).Updates(values)
+	if result.Error != nil {
+		apierror.Respond(c, apierror.Internal(result.Error, "Failed to update user", "更新用户失败"))
+		return false
+	}
+	if result.RowsAffected == 0 {
+		respondStaleWrite(c, &models.User{}, user.ID)
+		return false
+	}
+	return true
+}
+
+// GetAdminUser 获取单个用户信息（管理后台），响应头中带有当前版本的ETag
+func GetAdminUser(c *gin.Context) {
+	id := c.Param("id")
+
+	var user models.User
+	result := requestDB(c).Select("id, username, name, email, role, gender, is_frozen, version, created_at, updated_at").First(&user, id)
+	if result.Error != nil {
+		respondFindError(c, result.Error, "user")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+	setETag(c, user.Version)
+	c.JSON(http.StatusOK, user)
 }
 
-// DeleteUser 删除用户
+// DeleteUser 删除用户，用户移入回收站，收藏保留以便恢复
 func DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 
-	result := config.DB.Delete(&models.User{}, id)
+	var user models.User
+	if err := requestDB(c).First(&user, id).Error; err != nil {
+		respondFindError(c, err, "user")
+		return
+	}
+	if !checkIfMatch(c, user.Version) {
+		return
+	}
+
+	result := requestDB(c).Where("version = ?", user.Version).Delete(&user)
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		apierror.Respond(c, apierror.Internal(result.Error, "Failed to delete user", "删除用户失败"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondStaleWrite(c, &models.User{}, user.ID)
 		return
 	}
 
@@ -331,29 +492,25 @@ func CreateUser(c *gin.Context) {
 
 	var user models.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		respondBindError(c, err)
 		return
 	}
 
 	// 密码加密
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		apierror.Respond(c, apierror.Internal(err, "Failed to process password", "密码处理失败"))
 		return
 	}
 	user.Password = string(hashedPassword)
 
 	// 创建用户
-	result := config.DB.Create(&user)
+	result := requestDB(c).Create(&user)
 	if result.Error != nil {
-		if strings.Contains(result.Error.Error(), "UNIQUE constraint failed: users.username") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
-			return
-		} else if strings.Contains(result.Error.Error(), "UNIQUE constraint failed: users.email") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		if respondDuplicateUser(c, result.Error, 0, user.Username, user.Email) {
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user: " + result.Error.Error()})
+		apierror.Respond(c, apierror.Internal(result.Error, "Failed to create user", "创建用户失败"))
 		return
 	}
 
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testJWTSecret = "test-secret-0123456789abcdefghijklmnop"
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
+		"user_id": 1,
+		"role":    "admin",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	return s
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		secret string // 服务端配置的密钥
+		header string
+		want   int
+	}{
+		{"有效token", testJWTSecret, "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret)), http.StatusOK},
+		{"没有Bearer前缀", testJWTSecret, signToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret)), http.StatusOK},
+		{"其他密钥签名", testJWTSecret, "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("another-secret-0123456789abcdefghij")), http.StatusUnauthorized},
+		{"空密钥签名", testJWTSecret, "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("")), http.StatusUnauthorized},
+		{"不签名", testJWTSecret, "Bearer " + signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType), http.StatusUnauthorized},
+		{"没有token", testJWTSecret, "", http.StatusUnauthorized},
+		{"服务端未配置密钥", "", "Bearer " + signToken(t, jwt.SigningMethodHS256, []byte("")), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := config.JWTSecret
+			config.JWTSecret = tt.secret
+			t.Cleanup(func() { config.JWTSecret = old })
+
+			r := gin.New()
+			r.GET("/", AuthMiddleware(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("状态码为%d，应为%d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
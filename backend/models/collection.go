@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Collection 电影合集，包括从TMDB同步的系列电影和自建的精选合集
+type Collection struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	TMDBID       *uint     `json:"tmdb_id" gorm:"uniqueIndex"` // 自建合集为空
+	Name         string    `json:"name"`
+	Overview     string    `json:"overview"`
+	PosterPath   string    `json:"poster_path"`
+	BackdropPath string    `json:"backdrop_path"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"deleted_at,omitempty"` // 软删除时间，回收站中的合集不再同步
+
+	Movies []Movie `gorm:"many2many:collection_movies;foreignKey:ID;joinForeignKey:CollectionID;References:ID;joinReferences:MovieID" json:"movies,omitempty"`
+	Images []Image `gorm:"many2many:collection_images;foreignKey:ID;joinForeignKey:CollectionID;References:FilePath;joinReferences:ImageFilePath;association_autocreate:false" json:"images,omitempty"`
+}
+
+// CollectionMovie 合集和电影的关联表
+type CollectionMovie struct {
+	CollectionID uint `gorm:"primaryKey;type:int;column:collection_id"`
+	MovieID      uint `gorm:"primaryKey;type:int;column:movie_id"`
+}
+
+// CollectionImage 合集和图片的关联表
+type CollectionImage struct {
+	CollectionID  uint   `gorm:"primaryKey;type:int;column:collection_id"`
+	ImageFilePath string `gorm:"primaryKey;type:varchar(255);column:image_file_path"`
+}
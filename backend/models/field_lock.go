@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// FieldLock 字段锁，被锁定的字段在TMDB同步时保留本地的值
+type FieldLock struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	EntityType string    `gorm:"type:varchar(32);uniqueIndex:idx_field_lock;not null" json:"entity_type"` // movie、people、genre、collection
+	EntityID   string    `gorm:"type:varchar(64);uniqueIndex:idx_field_lock;not null" json:"entity_id"`
+	Field      string    `gorm:"type:varchar(64);uniqueIndex:idx_field_lock;not null" json:"field"` // 字段的JSON名称
+	Actor      string    `gorm:"type:varchar(64);not null" json:"actor"`                            // 设置锁的操作者
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SyncConflict 同步时因字段被锁定而跳过的修改，每个字段只保留最近一次
+type SyncConflict struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	EntityType  string    `gorm:"type:varchar(32);uniqueIndex:idx_sync_conflict;not null" json:"entity_type"`
+	EntityID    string    `gorm:"type:varchar(64);uniqueIndex:idx_sync_conflict;not null" json:"entity_id"`
+	Field       string    `gorm:"type:varchar(64);uniqueIndex:idx_sync_conflict;not null" json:"field"`
+	LocalValue  string    `gorm:"type:text" json:"local_value"`  // 保留的本地值，JSON格式
+	RemoteValue string    `gorm:"type:text" json:"remote_value"` // 被跳过的TMDB值，JSON格式
+	Job         string    `gorm:"type:varchar(64)" json:"job"`   // 发现冲突的同步任务
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
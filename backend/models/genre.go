@@ -1,6 +1,12 @@
 package models
 
+import "gorm.io/gorm"
+
 type Genre struct {
 	ID   int    `gorm:"column:id;primaryKey;autoIncrement;not null" json:"id"`
-	Name string `gorm:"column:name;not null" json:"name"`
+	Name string `gorm:"column:name;not null" json:"name" binding:"required,max=50"`
+
+	Version int `gorm:"column:version;not null;default:1" json:"version"` // 乐观锁版本号，每次修改加1
+
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"deleted_at,omitempty"` // 软删除时间
 }
@@ -19,3 +19,9 @@ type MovieImage struct {
 	MovieID       int    `gorm:"primaryKey;type:int;column:movie_id"`
 	ImageFilePath string `gorm:"primaryKey;type:varchar(255);column:image_file_path"`
 }
+
+// PeopleImage 人物和图片的关联表
+type PeopleImage struct {
+	PeopleID      int    `gorm:"primaryKey;type:int;column:people_id"`
+	ImageFilePath string `gorm:"primaryKey;type:varchar(255);column:image_file_path"`
+}
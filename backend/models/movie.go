@@ -9,46 +9,42 @@ import (
 type Movie struct {
 	gorm.Model
 	ID                  uint           `json:"id" gorm:"primaryKey"`
-	Title               string         `json:"title"`
+	Title               string         `json:"title" binding:"required,max=255"`
 	OriginalTitle       string         `json:"original_title"`
-	OriginalLanguage    string         `json:"original_language"`
+	OriginalLanguage    string         `json:"original_language" binding:"max=16"`
 	Overview            string         `json:"overview"`
 	PosterPath          string         `json:"poster_path"` // 海报路径（需要拼接完整URL）
 	BackdropPath        string         `json:"backdrop_path"`
 	ReleaseDate         time.Time      `json:"release_date"`
 	Adult               bool           `json:"adult"`
-	Popularity          float64        `json:"popularity"`
-	VoteAverage         float64        `json:"vote_average"`
-	VoteCount           int            `json:"vote_count"`
+	Popularity          float64        `json:"popularity" binding:"gte=0"`
+	VoteAverage         float64        `json:"vote_average" binding:"gte=0,lte=10"`
+	VoteCount           int            `json:"vote_count" binding:"gte=0"`
 	Video               bool           `json:"video"`
 	CreatedAt           time.Time      `json:"created_at"`
 	UpdatedAt           time.Time      `json:"updated_at"`
 	DeletedAt           gorm.DeletedAt `json:"deleted_at" gorm:"index"`
-	BelongsToCollection *Collection    `json:"belongs_to_collection" gorm:"foreignKey:CollectionID"`
+	BelongsToCollection *Collection    `json:"belongs_to_collection" gorm:"foreignKey:CollectionID"` // TMDB系列
 	CollectionID        *uint          `json:"collection_id"`
-	Budget              int            `json:"budget"`
-	Homepage            string         `json:"homepage"`
+	Budget              int            `json:"budget" binding:"gte=0"`
+	Homepage            string         `json:"homepage" binding:"omitempty,url"`
 	IMDBID              string         `json:"imdb_id"`
-	Runtime             int            `json:"runtime"`
+	Runtime             int            `json:"runtime" binding:"gte=0"`
 	Tagline             string         `json:"tagline"`
 	Status              string         `json:"status"`
-	Cast                string         `json:"cast"`
-	Duration            int            `json:"duration"`
+	Duration            int            `json:"duration" binding:"gte=0"`
+	Version             int            `json:"version" gorm:"not null;default:1"` // 乐观锁版本号，每次修改加1
 
 	Director *Credit  `gorm:"foreignKey:MovieID;references:ID;association_autocreate:false"`
-	Credits  []Credit `gorm:"foreignKey:MovieID;references:ID;association_autocreate:false"`
+	Credits  []Credit `gorm:"foreignKey:MovieID;references:ID;association_autocreate:false" json:"Credits,omitempty"`
+
+	Cast []Credit         `gorm:"foreignKey:MovieID;references:ID;association_autocreate:false" json:"cast,omitempty"` // 演员，按TMDB排序
+	Crew []CrewDepartment `gorm:"-" json:"crew,omitempty"`                                                             // 职员，按部门分组
 
 	Images []Image `gorm:"many2many:movie_images;foreignKey:ID;joinForeignKey:MovieID;References:FilePath;joinReferences:ImageFilePath;association_autocreate:false"`
 	Genres []Genre `gorm:"many2many:movie_genres;foreignKey:ID;joinForeignKey:MovieID;References:ID;joinReferences:GenreID;association_autocreate:true"`
 }
 
-type Collection struct {
-	ID           uint   `json:"id" gorm:"primaryKey"`
-	Name         string `json:"name"`
-	PosterPath   string `json:"poster_path"`
-	BackdropPath string `json:"backdrop_path"`
-}
-
 type MovieGenre struct {
 	MovieID uint `gorm:"primaryKey;type:int;column:movie_id"`
 	GenreID uint `gorm:"primaryKey;type:int;column:genre_id"`
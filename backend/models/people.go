@@ -1,10 +1,16 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 type People struct {
 	ID                 int     `gorm:"primaryKey;column:id" json:"id"`
-	Name               string  `gorm:"type:varchar(255);column:name" json:"name"`
+	Name               string  `gorm:"type:varchar(255);column:name" json:"name" binding:"required,max=255"`
 	OriginalName       string  `gorm:"type:varchar(255);column:original_name" json:"original_name"`
-	Gender             int     `gorm:"type:int;column:gender" json:"gender"`
+	Gender             int     `gorm:"type:int;column:gender" json:"gender" binding:"oneof=0 1 2 3"`
 	Adult              bool    `gorm:"type:boolean;column:adult" json:"adult"`
 	KnownForDepartment string  `gorm:"type:varchar(255);column:known_for_department" json:"known_for_department"`
 	Popularity         float64 `gorm:"type:double;column:popularity" json:"popularity"`
@@ -16,7 +22,19 @@ type People struct {
 	Homepage           string  `gorm:"type:varchar(255);column:homepage" json:"homepage"`
 	PlaceOfBirth       string  `gorm:"type:varchar(255);column:place_of_birth" json:"place_of_birth"`
 
+	// 外部平台ID
+	IMDBID      string `gorm:"type:varchar(32);column:imdb_id" json:"imdb_id"`
+	WikidataID  string `gorm:"type:varchar(32);column:wikidata_id" json:"wikidata_id"`
+	InstagramID string `gorm:"type:varchar(255);column:instagram_id" json:"instagram_id"`
+	TwitterID   string `gorm:"type:varchar(255);column:twitter_id" json:"twitter_id"`
+
+	SyncedAt *time.Time `gorm:"column:synced_at" json:"synced_at"`                // 最近一次从TMDB同步详情的时间
+	Version  int        `gorm:"column:version;not null;default:1" json:"version"` // 乐观锁版本号，每次修改加1
+
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"deleted_at,omitempty"` // 软删除时间，回收站中的人物不再同步
+
 	Credits []Credit `gorm:"foreignKey:PeopleID;references:ID"`
+	Images  []Image  `gorm:"many2many:people_images;foreignKey:ID;joinForeignKey:PeopleID;References:FilePath;joinReferences:ImageFilePath;association_autocreate:false"`
 }
 
 type Credit struct {
@@ -24,7 +42,9 @@ type Credit struct {
 	CreditType string `gorm:"type:varchar(255);column:credit_type" json:"credit_type"`
 	Department string `gorm:"type:varchar(255);column:department" json:"department"`
 	Job        string `gorm:"type:varchar(255);column:job" json:"job"`
-	Order      int    `gorm:"type:int;column:order" json:"order"`
+	Character  string `gorm:"type:varchar(255);column:character" json:"character"` // 饰演角色，仅演员有值
+	CastID     int    `gorm:"type:int;column:cast_id" json:"cast_id"`
+	Order      int    `gorm:"type:int;column:order" json:"order"` // 演员为TMDB排序，职员为部门内排序
 
 	MovieID int    `gorm:"type:int;column:movie_id"`
 	Movie   *Movie `gorm:"foreignKey:MovieID;references:ID"`
@@ -32,3 +52,9 @@ type Credit struct {
 	PeopleID int     `gorm:"type:int;column:people_id"`
 	People   *People `gorm:"foreignKey:PeopleID;references:ID"`
 }
+
+// CrewDepartment 按部门分组的职员列表
+type CrewDepartment struct {
+	Department string   `json:"department"`
+	Crew       []Credit `json:"crew"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RateLimit 令牌桶和登录失败的状态，rate_limit.store为database时多个实例共享
+type RateLimit struct {
+	Key         string    `gorm:"type:varchar(191);primaryKey"` // 规则名称和IP、用户ID或用户名，如login:127.0.0.1
+	Tokens      float64   // 剩余令牌数
+	RefilledAt  time.Time // 上次补充令牌的时间
+	Failures    int       // 连续登录失败次数
+	LockedUntil time.Time // 登录锁定的到期时间
+	ExpiresAt   time.Time `gorm:"index"` // 过期后状态与初始状态相同，可以删除
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Revision 实体的修改记录，用于查看历史和回滚
+type Revision struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	EntityType string    `gorm:"type:varchar(32);index:idx_revision_entity;not null" json:"entity_type"` // movie、people、genre、collection
+	EntityID   string    `gorm:"type:varchar(64);index:idx_revision_entity;not null" json:"entity_id"`
+	Action     string    `gorm:"type:varchar(16);not null" json:"action"` // create、update、delete、rollback
+	Actor      string    `gorm:"type:varchar(64);not null" json:"actor"`  // user:<id>、admin 或 sync:<任务ID>
+	Diff       string    `gorm:"type:text" json:"diff"`                   // 字段变化，JSON格式 {"字段": {"old": 旧值, "new": 新值}}
+	Snapshot   string    `gorm:"type:text" json:"snapshot"`               // 修改后的实体字段，删除时为删除前的字段
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// SyncRun 每种同步任务最近一次的执行结果，同步可能在单独的sync进程中运行，所以记录在数据库中
+type SyncRun struct {
+	Type          string     `gorm:"type:varchar(32);primaryKey" json:"type"` // movies、genres
+	LastSuccessAt *time.Time `json:"last_success_at"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
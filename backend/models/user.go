@@ -8,13 +8,14 @@ import (
 
 type User struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
-	Username  string         `json:"username" gorm:"unique;not null"`
-	Name      string         `json:"name" gorm:"default:''"`
-	Password  string         `json:"password,omitempty" gorm:"not null"`
-	Email     string         `json:"email" gorm:"unique;not null"`
-	Role      string         `json:"role" gorm:"default:'user'"`
-	Gender    string         `json:"gender" gorm:"default:''"`
+	Username  string         `json:"username" gorm:"unique;not null" binding:"required,min=3,max=20"`
+	Name      string         `json:"name" gorm:"default:''" binding:"omitempty,min=2,max=20"`
+	Password  string         `json:"password,omitempty" gorm:"not null" binding:"required,min=6"`
+	Email     string         `json:"email" gorm:"unique;not null" binding:"required,email"`
+	Role      string         `json:"role" gorm:"default:'user'" binding:"omitempty,oneof=user admin"`
+	Gender    string         `json:"gender" gorm:"default:''" binding:"omitempty,oneof=male female"`
 	IsFrozen  bool           `json:"is_frozen" gorm:"default:false"`
+	Version   int            `json:"version" gorm:"not null;default:1"` // 乐观锁版本号，每次修改加1
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
@@ -0,0 +1,218 @@
+// Package apierror 统一API的错误响应：错误码、英文和本地化的错误信息、字段错误以及请求ID
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"github.com/Estella0129/theater/backend/pkg/logging"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 错误码，客户端根据错误码而不是错误信息判断错误类型
+const (
+	CodeBadRequest           = "bad_request"
+	CodeValidation           = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeUnsupportedMedia     = "unsupported_media_type"
+	CodeTooManyRequests      = "too_many_requests"
+	CodeInternal             = "internal_error"
+)
+
+// Error API错误，Message为英文信息，Localized为中文信息
+type Error struct {
+	Status    int
+	Code      string
+	Message   string
+	Localized string
+	Fields    []FieldError
+	// Details 附加在响应中的其他字段，如412响应中的当前版本号
+	Details map[string]interface{}
+	// Err 原始错误，只记录在日志中，不返回给客户端
+	Err error
+}
+
+// FieldError 请求中某个字段的错误
+type FieldError struct {
+	Field            string `json:"field"`
+	Code             string `json:"code"`
+	Message          string `json:"message"`
+	LocalizedMessage string `json:"localized_message"`
+}
+
+// Body 错误响应体，用于生成API文档，与Respond写入的响应一致，Details中的字段同样出现在顶层
+type Body struct {
+	Error            string       `json:"error"` // 与localized_message相同，兼容旧客户端
+	Code             string       `json:"code"`
+	Message          string       `json:"message"`
+	LocalizedMessage string       `json:"localized_message"`
+	Fields           []FieldError `json:"fields,omitempty"`
+	RequestID        string       `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With 在响应中附加字段
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// WithField 添加字段错误
+func (e *Error) WithField(field, code, message, localized string) *Error {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: message, LocalizedMessage: localized})
+	return e
+}
+
+// Wrap 记录原始错误
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// New 创建API错误
+func New(status int, code, message, localized string) *Error {
+	return &Error{Status: status, Code: code, Message: message, Localized: localized}
+}
+
+// BadRequest 请求参数错误
+func BadRequest(message, localized string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message, localized)
+}
+
+// Unauthorized 未登录或登录信息无效
+func Unauthorized(message, localized string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message, localized)
+}
+
+// Forbidden 没有权限
+func Forbidden(message, localized string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message, localized)
+}
+
+// Conflict 与现有数据冲突
+func Conflict(message, localized string) *Error {
+	return New(http.StatusConflict, CodeConflict, message, localized)
+}
+
+// Internal 服务器内部错误，err只记录在日志中
+func Internal(err error, message, localized string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message, localized).Wrap(err)
+}
+
+// resources 资源的英文和中文名称
+var resources = map[string][2]string{
+	"movie":      {"Movie", "电影"},
+	"people":     {"People", "人物"},
+	"genre":      {"Genre", "电影类型"},
+	"collection": {"Collection", "合集"},
+	"user":       {"User", "用户"},
+	"revision":   {"Revision", "修改记录"},
+	"backup":     {"Backup", "备份"},
+	"job":        {"Job", "任务"},
+	"favorite":   {"Favorite", "收藏"},
+	"field_lock": {"Field lock", "字段锁"},
+	"trash":      {"Item in trash", "回收站中的数据"},
+	"trash_type": {"Trash type", "回收站类型"},
+	"route":      {"Route", "接口"},
+}
+
+// NotFound 资源不存在，resource为movie、people等资源类型
+func NotFound(resource string) *Error {
+	names, ok := resources[resource]
+	if !ok {
+		names = [2]string{"Resource", "资源"}
+	}
+	return New(http.StatusNotFound, CodeNotFound, names[0]+" not found", names[1]+"不存在").
+		With("resource", resource)
+}
+
+// From 将错误映射为API错误：记录不存在为404，唯一约束冲突为409，
+// 请求体解析和校验失败为400，其他错误为500且不向客户端暴露原始错误。
+// 500错误包装的原始错误属于前几类时，按原始错误返回
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Status == http.StatusInternalServerError && apiErr.Err != nil {
+			if known := classify(apiErr.Err); known != nil {
+				return known
+			}
+		}
+		return apiErr
+	}
+	if known := classify(err); known != nil {
+		return known
+	}
+	return Internal(err, "Internal server error", "服务器内部错误")
+}
+
+// classify 映射已知类型的错误，无法识别时返回nil
+func classify(err error) *Error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return New(http.StatusNotFound, CodeNotFound, "Resource not found", "资源不存在").Wrap(err)
+	case dialect.IsDuplicateKey(err):
+		return Conflict("Resource already exists", "数据已存在").Wrap(err)
+	}
+	return Validation(err)
+}
+
+// Respond 写入错误响应并中止请求，错误同时记录在访问日志中。
+// 为兼容旧客户端，error字段为本地化的错误信息
+func Respond(c *gin.Context, err error) {
+	apiErr := From(err)
+	_ = c.Error(err)
+
+	localize := localizer(c.GetHeader("Accept-Language"))
+	body := gin.H{}
+	for key, value := range apiErr.Details {
+		body[key] = value
+	}
+	body["error"] = localize(apiErr.Message, apiErr.Localized)
+	body["code"] = apiErr.Code
+	body["message"] = apiErr.Message
+	body["localized_message"] = localize(apiErr.Message, apiErr.Localized)
+	if len(apiErr.Fields) > 0 {
+		fields := make([]FieldError, len(apiErr.Fields))
+		for i, field := range apiErr.Fields {
+			field.LocalizedMessage = localize(field.Message, field.LocalizedMessage)
+			fields[i] = field
+		}
+		body["fields"] = fields
+	}
+	if id := c.GetString(logging.RequestIDKey); id != "" {
+		body["request_id"] = id
+	}
+	c.AbortWithStatusJSON(apiErr.Status, body)
+}
+
+// localizer 根据Accept-Language选择错误信息的语言，默认为中文
+func localizer(acceptLanguage string) func(message, localized string) string {
+	english := strings.HasPrefix(strings.ToLower(strings.TrimSpace(acceptLanguage)), "en")
+	return func(message, localized string) string {
+		if english || localized == "" {
+			return message
+		}
+		return localized
+	}
+}
@@ -0,0 +1,125 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// 字段错误中使用JSON字段名而不是Go字段名
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// Invalid 请求校验失败，调用WithField添加字段错误
+func Invalid() *Error {
+	return New(http.StatusBadRequest, CodeValidation, "Request validation failed", "请求参数校验失败")
+}
+
+// Validate 按binding标签校验结构体，失败时返回带字段错误的API错误
+func Validate(obj interface{}) error {
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return From(err)
+	}
+	return nil
+}
+
+// Validation 将请求体解析和校验的错误转换为400错误，err不是这类错误时返回nil
+func Validation(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		apiErr := Invalid().Wrap(err)
+		for _, fieldErr := range validationErrs {
+			message, localized := describe(fieldErr)
+			apiErr.WithField(fieldPath(fieldErr), fieldErr.Tag(), message, localized)
+		}
+		return apiErr
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return Invalid().Wrap(err).WithField(field, "type",
+			"must be of type "+typeErr.Type.String(), "类型错误，应为"+typeErr.Type.String())
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return BadRequest("Request body is not valid JSON", "请求体不是有效的JSON").Wrap(err)
+	}
+	return nil
+}
+
+// fieldPath 去掉最外层结构体名称后的字段路径，如belongs_to_collection.name
+func fieldPath(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fieldErr.Field()
+}
+
+// describe 生成字段错误的英文和中文信息
+func describe(fieldErr validator.FieldError) (string, string) {
+	param := fieldErr.Param()
+	isString := fieldErr.Kind() == reflect.String
+	isList := fieldErr.Kind() == reflect.Slice || fieldErr.Kind() == reflect.Map
+
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required", "不能为空"
+	case "email":
+		return "must be a valid email address", "邮箱格式不正确"
+	case "url":
+		return "must be a valid URL", "链接格式不正确"
+	case "oneof":
+		return "must be one of: " + param, "只能是以下值之一：" + param
+	case "min", "gte":
+		switch {
+		case isString:
+			return fmt.Sprintf("must be at least %s characters", param), fmt.Sprintf("长度不能少于%s个字符", param)
+		case isList:
+			return fmt.Sprintf("must contain at least %s items", param), fmt.Sprintf("至少需要%s项", param)
+		}
+		return "must be at least " + param, "不能小于" + param
+	case "max", "lte":
+		switch {
+		case isString:
+			return fmt.Sprintf("must be at most %s characters", param), fmt.Sprintf("长度不能超过%s个字符", param)
+		case isList:
+			return fmt.Sprintf("must contain at most %s items", param), fmt.Sprintf("最多%s项", param)
+		}
+		return "must be at most " + param, "不能大于" + param
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters", param), fmt.Sprintf("长度必须为%s个字符", param)
+	}
+	return "is invalid", "格式不正确"
+}
+
+// Bind 请求绑定失败的错误，无法识别的错误同样作为400返回
+func Bind(err error) *Error {
+	if apiErr := Validation(err); apiErr != nil {
+		return apiErr
+	}
+	return BadRequest("Invalid request body", "请求体格式不正确").Wrap(err)
+}
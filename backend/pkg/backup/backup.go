@@ -0,0 +1,461 @@
+// Package backup 数据库和图片的备份与恢复。
+// 备份为tar.gz文件，包含manifest.json、VACUUM INTO生成的数据库快照以及可选的图片目录
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 备份文件中的固定条目
+const (
+	manifestName = "manifest.json"
+	databaseName = "theater.db"
+	imagesPrefix = "images/"
+)
+
+// filePrefix和fileSuffix 备份文件名的前缀和后缀，保留策略只处理符合该格式的文件
+const (
+	filePrefix = "theater-"
+	fileSuffix = ".tar.gz"
+	timeLayout = "20060102-150405"
+)
+
+// requiredTables 恢复前检查快照中必须存在的表
+var requiredTables = []string{"movies", "peoples", "genres", "users", "credits"}
+
+// ErrInvalidName 备份文件名不符合格式
+var ErrInvalidName = errors.New("备份文件名无效")
+
+// ErrExists 同名的备份文件已存在，备份文件名精确到秒
+var ErrExists = errors.New("同一秒内已有备份，请稍后重试")
+
+// mu 同一进程中同时只运行一个备份
+var mu sync.Mutex
+
+// Options 备份选项
+type Options struct {
+	Dir           string // 备份目录
+	IncludeImages bool   // 是否包含图片目录
+	ImageDir      string // 图片目录
+	Keep          int    // 保留最近的备份数量，0表示不清理
+}
+
+// Manifest 备份的说明信息
+type Manifest struct {
+	CreatedAt      time.Time `json:"created_at"`
+	DatabaseSHA256 string    `json:"database_sha256"`
+	DatabaseSize   int64     `json:"database_size"`
+	IncludeImages  bool      `json:"include_images"`
+	Images         int       `json:"images"` // 图片文件数量
+}
+
+// Info 备份文件信息
+type Info struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Create 对SQLite数据库做一致的在线快照并打包，按保留策略删除旧备份，返回备份文件路径
+func Create(db *gorm.DB, opts Options) (string, error) {
+	if name := db.Dialector.Name(); name != "sqlite" {
+		return "", fmt.Errorf("当前数据库为%s，只有SQLite支持备份，其他数据库请使用pg_dump或mysqldump", name)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return "", fmt.Errorf("创建备份目录失败: %v", err)
+	}
+
+	now := time.Now()
+	snapshot := filepath.Join(opts.Dir, fmt.Sprintf(".snapshot-%d.db", now.UnixNano()))
+	defer os.Remove(snapshot)
+	// VACUUM INTO在一个读事务中复制数据库，不阻塞其他读写
+	if err := db.Exec("VACUUM INTO ?", snapshot).Error; err != nil {
+		return "", fmt.Errorf("生成数据库快照失败: %v", err)
+	}
+
+	name := filePrefix + now.Format(timeLayout) + fileSuffix
+	path := filepath.Join(opts.Dir, name)
+	tmp := path + ".tmp"
+	if err := writeArchive(tmp, snapshot, now, opts); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	// 用硬链接代替Rename，已存在同名备份时失败而不是覆盖，例如另一个进程同时执行了备份
+	err := os.Link(tmp, path)
+	os.Remove(tmp)
+	if errors.Is(err, fs.ErrExist) {
+		return "", ErrExists
+	}
+	if err != nil {
+		return "", fmt.Errorf("保存备份文件失败: %v", err)
+	}
+
+	if opts.Keep > 0 {
+		if err := prune(opts.Dir, opts.Keep); err != nil {
+			return path, fmt.Errorf("清理旧备份失败: %v", err)
+		}
+	}
+	return path, nil
+}
+
+func writeArchive(path, snapshot string, now time.Time, opts Options) error {
+	sum, size, err := fileSHA256(snapshot)
+	if err != nil {
+		return fmt.Errorf("读取数据库快照失败: %v", err)
+	}
+	var images []string
+	if opts.IncludeImages {
+		if images, err = listFiles(opts.ImageDir); err != nil {
+			return fmt.Errorf("读取图片目录失败: %v", err)
+		}
+	}
+	manifest, err := json.MarshalIndent(Manifest{
+		CreatedAt:      now,
+		DatabaseSHA256: sum,
+		DatabaseSize:   size,
+		IncludeImages:  opts.IncludeImages,
+		Images:         len(images),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建备份文件失败: %v", err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	if err := tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0644, Size: int64(len(manifest)), ModTime: now}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return err
+	}
+	if err := addFile(tw, snapshot, databaseName); err != nil {
+		return fmt.Errorf("写入数据库快照失败: %v", err)
+	}
+	for _, image := range images {
+		if err := addFile(tw, filepath.Join(opts.ImageDir, image), imagesPrefix+filepath.ToSlash(image)); err != nil {
+			return fmt.Errorf("写入图片%s失败: %v", image, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func addFile(tw *tar.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: stat.Size(), ModTime: stat.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// listFiles 返回目录下所有普通文件的相对路径，目录不存在时返回空
+func listFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// List 列出备份目录中的备份，最新的在前
+func List(dir string) ([]Info, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []Info{}
+	for _, entry := range entries {
+		createdAt, ok := parseName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Info{Name: entry.Name(), Size: stat.Size(), CreatedAt: createdAt})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Path 返回备份目录中指定备份的路径，名称不符合格式时返回ErrInvalidName
+func Path(dir, name string) (string, error) {
+	if _, ok := parseName(name); !ok || filepath.Base(name) != name {
+		return "", ErrInvalidName
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func parseName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix), time.Local)
+	return t, err == nil
+}
+
+// prune 只保留最近的keep个备份
+func prune(dir string, keep int) error {
+	backups, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(dir, backups[i].Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify 检查备份文件：manifest存在，数据库快照的校验和一致、完整性检查通过且包含必需的表
+func Verify(path string) (*Manifest, error) {
+	dir, err := os.MkdirTemp("", "theater-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	database := filepath.Join(dir, databaseName)
+	manifest, err := extract(path, database, "")
+	if err != nil {
+		return nil, err
+	}
+	return manifest, checkDatabase(database)
+}
+
+// RestoreOptions 恢复选项
+type RestoreOptions struct {
+	DBPath        string // 要替换的数据库文件
+	ImageDir      string // 图片目录
+	RestoreImages bool   // 备份包含图片时是否恢复图片
+}
+
+// Restore 校验备份后替换数据库文件，原数据库保留为<DBPath>.before-restore-<时间>。
+// 恢复时不能有其他进程在使用数据库
+func Restore(path string, opts RestoreOptions) (*Manifest, error) {
+	dir := filepath.Dir(opts.DBPath)
+	tmp, err := os.CreateTemp(dir, ".restore-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	tmp.Close()
+	database := tmp.Name()
+	defer os.Remove(database)
+
+	imageDir := ""
+	if opts.RestoreImages {
+		imageDir = opts.ImageDir
+	}
+	// 先解压并校验数据库，通过后才写入图片和替换数据库
+	manifest, err := extract(path, database, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDatabase(database); err != nil {
+		return nil, err
+	}
+	if imageDir != "" && manifest.IncludeImages {
+		if _, err := extract(path, "", imageDir); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := os.Stat(opts.DBPath); err == nil {
+		previous := opts.DBPath + ".before-restore-" + time.Now().Format(timeLayout)
+		if err := os.Rename(opts.DBPath, previous); err != nil {
+			return nil, fmt.Errorf("保留原数据库失败: %v", err)
+		}
+	}
+	// 原数据库的WAL文件不能应用到恢复的数据库上
+	os.Remove(opts.DBPath + "-wal")
+	os.Remove(opts.DBPath + "-shm")
+	if err := os.Rename(database, opts.DBPath); err != nil {
+		return nil, fmt.Errorf("替换数据库失败: %v", err)
+	}
+	return manifest, nil
+}
+
+// extract 读取备份文件，将数据库快照写到database，database为空时跳过；
+// imageDir不为空时将图片写到该目录。返回manifest，并检查数据库快照的校验和
+func extract(path, database, imageDir string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开备份文件失败: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("备份文件格式错误: %v", err)
+	}
+	defer gz.Close()
+
+	var manifest *Manifest
+	sum := ""
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("备份文件格式错误: %v", err)
+		}
+
+		switch {
+		case header.Name == manifestName:
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("读取manifest失败: %v", err)
+			}
+		case header.Name == databaseName && database != "":
+			if sum, err = writeFile(database, tr); err != nil {
+				return nil, fmt.Errorf("解压数据库快照失败: %v", err)
+			}
+		case strings.HasPrefix(header.Name, imagesPrefix) && imageDir != "":
+			name := filepath.FromSlash(strings.TrimPrefix(header.Name, imagesPrefix))
+			// 防止条目路径跳出图片目录
+			if !filepath.IsLocal(name) {
+				return nil, fmt.Errorf("备份中的图片路径无效: %s", header.Name)
+			}
+			target := filepath.Join(imageDir, name)
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return nil, err
+			}
+			if _, err := writeFile(target, tr); err != nil {
+				return nil, fmt.Errorf("恢复图片%s失败: %v", name, err)
+			}
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("备份中缺少%s", manifestName)
+	}
+	if database != "" {
+		if sum == "" {
+			return nil, fmt.Errorf("备份中缺少数据库快照")
+		}
+		if sum != manifest.DatabaseSHA256 {
+			return nil, fmt.Errorf("数据库快照校验和不一致，备份文件可能已损坏")
+		}
+	}
+	return manifest, nil
+}
+
+// writeFile 将r写入path并返回内容的SHA-256
+func writeFile(path string, r io.Reader) (string, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, h), r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), file.Sync()
+}
+
+func fileSHA256(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// checkDatabase 检查数据库文件的完整性和必需的表
+func checkDatabase(path string) error {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return fmt.Errorf("打开数据库快照失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Row().Scan(&result); err != nil {
+		return fmt.Errorf("数据库快照完整性检查失败: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("数据库快照完整性检查失败: %s", result)
+	}
+	for _, table := range requiredTables {
+		if !db.Migrator().HasTable(table) {
+			return fmt.Errorf("数据库快照中缺少%s表", table)
+		}
+	}
+	return nil
+}
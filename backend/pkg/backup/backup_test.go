@@ -0,0 +1,179 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/backup"
+	"github.com/Estella0129/theater/backend/pkg/dbtest"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"github.com/Estella0129/theater/backend/pkg/migrate"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newDB 创建执行了所有迁移并有一个用户的SQLite数据库
+func newDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := dbtest.Open(t, dialect.SQLite)
+	if _, err := migrate.Up(db); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	user := models.User{Username: "backup", Email: "backup@example.com", Password: "x", Role: "user"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return db
+}
+
+func TestRoundTrip(t *testing.T) {
+	db := newDB(t)
+	dir := t.TempDir()
+
+	path, err := backup.Create(db, backup.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("备份失败: %v", err)
+	}
+	manifest, err := backup.Verify(path)
+	if err != nil {
+		t.Fatalf("校验备份失败: %v", err)
+	}
+	if manifest.DatabaseSHA256 == "" || manifest.DatabaseSize == 0 {
+		t.Errorf("manifest缺少数据库快照信息: %+v", manifest)
+	}
+
+	// 同一秒内的第二次备份不能覆盖已有的备份
+	if _, err := backup.Create(db, backup.Options{Dir: dir}); err != nil && !errors.Is(err, backup.ErrExists) {
+		t.Errorf("第二次备份失败: %v", err)
+	}
+	if _, err := backup.Verify(path); err != nil {
+		t.Errorf("第二次备份后原备份校验失败: %v", err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "theater.db")
+	if _, err := backup.Restore(path, backup.RestoreOptions{DBPath: dbPath}); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	restored, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开恢复的数据库失败: %v", err)
+	}
+	if sqlDB, err := restored.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	var count int64
+	if err := restored.Model(&models.User{}).Where("username = ?", "backup").Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("恢复的数据库中应有备份前的用户，数量为%d: %v", count, err)
+	}
+}
+
+func TestVerifyRejectsCorruptedBackup(t *testing.T) {
+	db := newDB(t)
+	path, err := backup.Create(db, backup.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("备份失败: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(entry string, data []byte) []byte
+		want   string
+	}{
+		{"manifest中的校验和错误", func(entry string, data []byte) []byte {
+			if entry != "manifest.json" {
+				return data
+			}
+			var manifest backup.Manifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatalf("读取manifest失败: %v", err)
+			}
+			manifest.DatabaseSHA256 = strings.Repeat("0", 64)
+			data, _ = json.Marshal(manifest)
+			return data
+		}, "校验和不一致"},
+		{"数据库快照被修改", func(entry string, data []byte) []byte {
+			if entry != "theater.db" {
+				return data
+			}
+			data = bytes.Clone(data)
+			data[len(data)-1] ^= 0xff
+			return data
+		}, "校验和不一致"},
+		{"缺少manifest", func(entry string, data []byte) []byte {
+			if entry == "manifest.json" {
+				return nil
+			}
+			return data
+		}, "缺少manifest.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := filepath.Join(t.TempDir(), filepath.Base(path))
+			rewrite(t, path, corrupted, tt.modify)
+			if _, err := backup.Verify(corrupted); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("校验应失败并包含%q，实际为%v", tt.want, err)
+			}
+		})
+	}
+}
+
+// rewrite 复制备份文件，modify返回每个条目的新内容，返回nil时删除该条目
+func rewrite(t *testing.T, src, dst string, modify func(entry string, data []byte) []byte) {
+	t.Helper()
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	gr, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	gw := gzip.NewWriter(out)
+	tr, tw := tar.NewReader(gr), tar.NewWriter(gw)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data = modify(header.Name, data); data == nil {
+			continue
+		}
+		header.Size = int64(len(data))
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,301 @@
+// Package catalog 电影、人物、类型和合集的导入导出，支持JSON、NDJSON和CSV格式
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/Estella0129/theater/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 可导入导出的数据
+const (
+	Movies      = "movies"
+	People      = "people"
+	Genres      = "genres"
+	Collections = "collections"
+)
+
+// 导入时匹配已有数据的方式
+const (
+	ModeID     = "id"      // 按ID匹配，ID为空时创建
+	ModeTMDBID = "tmdb_id" // 按TMDB ID匹配，电影、人物和类型的ID即TMDB ID，合集按tmdb_id匹配
+)
+
+// batchSize 导出时每次查询和导入时每个事务的记录数
+const batchSize = 100
+
+// maxReportErrors 导入报告中最多保留的错误数量
+const maxReportErrors = 1000
+
+// importer 可导入的记录
+type importer interface {
+	// key 记录的标识，用于导入报告
+	key(mode string) string
+	// validate 检查记录，返回所有错误
+	validate(db *gorm.DB, mode string) []string
+	// exists 是否已有匹配的数据
+	exists(db *gorm.DB, mode string) (bool, error)
+	// save 创建或更新数据及其关联，返回是否为新建
+	save(tx *gorm.DB, mode, actor string) (bool, error)
+}
+
+type entity struct {
+	recordType reflect.Type
+	export     func(db *gorm.DB, emit func(record interface{}) error) error
+}
+
+var entities = map[string]entity{
+	Movies:      {reflect.TypeOf(MovieRecord{}), exportMovies},
+	People:      {reflect.TypeOf(PeopleRecord{}), exportPeople},
+	Genres:      {reflect.TypeOf(GenreRecord{}), exportGenres},
+	Collections: {reflect.TypeOf(CollectionRecord{}), exportCollections},
+}
+
+// Valid 检查数据类型和格式是否支持
+func Valid(name, format string) error {
+	if _, ok := entities[name]; !ok {
+		return fmt.Errorf("不支持的数据类型: %s，可选 movies、people、genres、collections", name)
+	}
+	switch format {
+	case FormatJSON, FormatNDJSON, FormatCSV:
+		return nil
+	}
+	return fmt.Errorf("不支持的格式: %s，可选 json、ndjson、csv", format)
+}
+
+// Export 将数据以format格式逐批查询并写入w，回收站中的数据不导出，返回导出的记录数
+func Export(db *gorm.DB, w io.Writer, name, format string) (int, error) {
+	if err := Valid(name, format); err != nil {
+		return 0, err
+	}
+	e := entities[name]
+
+	enc, err := newEncoder(w, format, e.recordType)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	if err := e.export(db, func(record interface{}) error {
+		count++
+		return enc.Encode(record)
+	}); err != nil {
+		return count, err
+	}
+	return count, enc.Close()
+}
+
+func exportMovies(db *gorm.DB, emit func(record interface{}) error) error {
+	var movies []models.Movie
+	return db.Preload("Genres").
+		Preload("Credits", func(db *gorm.DB) *gorm.DB {
+			return db.Order("credit_type ASC").Order(clause.OrderByColumn{Column: clause.Column{Name: "order"}})
+		}).
+		Preload("Images").
+		FindInBatches(&movies, batchSize, func(tx *gorm.DB, batch int) error {
+			for _, movie := range movies {
+				if err := emit(newMovieRecord(movie)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+func exportPeople(db *gorm.DB, emit func(record interface{}) error) error {
+	var peoples []models.People
+	return db.Preload("Images").
+		FindInBatches(&peoples, batchSize, func(tx *gorm.DB, batch int) error {
+			for _, people := range peoples {
+				if err := emit(newPeopleRecord(people)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+func exportGenres(db *gorm.DB, emit func(record interface{}) error) error {
+	var genres []models.Genre
+	return db.FindInBatches(&genres, batchSize, func(tx *gorm.DB, batch int) error {
+		for _, genre := range genres {
+			if err := emit(GenreRecord{ID: genre.ID, Name: genre.Name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func exportCollections(db *gorm.DB, emit func(record interface{}) error) error {
+	var collections []models.Collection
+	return db.Preload("Movies", func(db *gorm.DB) *gorm.DB {
+		return db.Select("movies.id").Order("movies.id")
+	}).
+		Preload("Images").
+		FindInBatches(&collections, batchSize, func(tx *gorm.DB, batch int) error {
+			for _, collection := range collections {
+				if err := emit(newCollectionRecord(collection)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// Options 导入选项
+type Options struct {
+	Format string
+	Mode   string
+	DryRun bool   // 只检查数据，不写入数据库
+	Actor  string // 修改记录中的操作者
+}
+
+// Report 导入报告
+type Report struct {
+	Entity    string        `json:"entity"`
+	Format    string        `json:"format"`
+	Mode      string        `json:"mode"`
+	DryRun    bool          `json:"dry_run"`
+	Total     int           `json:"total"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Invalid   int           `json:"invalid"` // 格式或校验错误，未导入
+	Failed    int           `json:"failed"`  // 写入数据库失败
+	Errors    []RecordError `json:"errors"`
+	Truncated bool          `json:"truncated,omitempty"` // 错误过多，只保留了前面的部分
+}
+
+// RecordError 单条记录的错误
+type RecordError struct {
+	Index  int      `json:"index"` // 记录的序号，从1开始
+	Key    string   `json:"key,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+func (r *Report) addError(index int, key string, errs ...string) {
+	if len(r.Errors) >= maxReportErrors {
+		r.Truncated = true
+		return
+	}
+	r.Errors = append(r.Errors, RecordError{Index: index, Key: key, Errors: errs})
+}
+
+// pending 已通过校验等待写入的记录
+type pending struct {
+	index  int
+	record importer
+}
+
+// Import 从r中逐条读取记录并导入，每batchSize条记录一个事务，单条记录失败只回滚该条。
+// 读取数据出错时停止导入并返回已处理部分的报告
+func Import(db *gorm.DB, r io.Reader, name string, opts Options) (*Report, error) {
+	if err := Valid(name, opts.Format); err != nil {
+		return nil, err
+	}
+	if opts.Mode == "" {
+		opts.Mode = ModeID
+	}
+	if opts.Mode != ModeID && opts.Mode != ModeTMDBID {
+		return nil, fmt.Errorf("不支持的导入方式: %s，可选 id、tmdb_id", opts.Mode)
+	}
+	e := entities[name]
+
+	report := &Report{Entity: name, Format: opts.Format, Mode: opts.Mode, DryRun: opts.DryRun, Errors: []RecordError{}}
+	dec, err := newDecoder(r, opts.Format, e.recordType)
+	if err != nil {
+		return report, err
+	}
+
+	var batch []pending
+	for index := 1; ; index++ {
+		record := reflect.New(e.recordType).Interface().(importer)
+		err := dec.Decode(record)
+		if err == io.EOF {
+			break
+		}
+		var formatErr recordError
+		if errors.As(err, &formatErr) {
+			report.Total++
+			report.Invalid++
+			report.addError(index, "", formatErr.Error())
+			continue
+		}
+		if err != nil {
+			flush(db, batch, opts, report)
+			return report, fmt.Errorf("读取第%d条记录失败: %v", index, err)
+		}
+
+		report.Total++
+		if errs := record.validate(db, opts.Mode); len(errs) > 0 {
+			report.Invalid++
+			report.addError(index, record.key(opts.Mode), errs...)
+			continue
+		}
+
+		batch = append(batch, pending{index, record})
+		if len(batch) >= batchSize {
+			flush(db, batch, opts, report)
+			batch = batch[:0]
+		}
+	}
+	flush(db, batch, opts, report)
+	return report, nil
+}
+
+// flush 写入一批记录并更新报告
+func flush(db *gorm.DB, batch []pending, opts Options, report *Report) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if opts.DryRun {
+		for _, p := range batch {
+			exists, err := p.record.exists(db, opts.Mode)
+			switch {
+			case err != nil:
+				report.Failed++
+				report.addError(p.index, p.record.key(opts.Mode), err.Error())
+			case exists:
+				report.Updated++
+			default:
+				report.Created++
+			}
+		}
+		return
+	}
+
+	created, updated := 0, 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range batch {
+			var isNew bool
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				var err error
+				isNew, err = p.record.save(tx, opts.Mode, opts.Actor)
+				return err
+			})
+			switch {
+			case err != nil:
+				report.Failed++
+				report.addError(p.index, p.record.key(opts.Mode), err.Error())
+			case isNew:
+				created++
+			default:
+				updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// 提交失败时整批都未写入
+		report.Failed += created + updated
+		report.addError(batch[0].index, "", fmt.Sprintf("提交事务失败: %v", err))
+		return
+	}
+	report.Created += created
+	report.Updated += updated
+}
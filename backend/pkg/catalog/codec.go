@@ -0,0 +1,293 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// 导入导出格式
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// ContentType 返回格式对应的HTTP Content-Type
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// FormatFromPath 根据文件扩展名判断格式，无法判断时返回空字符串
+func FormatFromPath(path string) string {
+	for _, format := range []string{FormatNDJSON, FormatJSON, FormatCSV} {
+		if strings.HasSuffix(strings.ToLower(path), "."+format) {
+			return format
+		}
+	}
+	return ""
+}
+
+// encoder 逐条写出记录，Close时写出结尾
+type encoder interface {
+	Encode(record interface{}) error
+	Close() error
+}
+
+// decoder 逐条读取记录，读完时返回io.EOF
+type decoder interface {
+	Decode(record interface{}) error
+}
+
+func newEncoder(w io.Writer, format string, recordType reflect.Type) (encoder, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonEncoder{w: w}, nil
+	case FormatNDJSON:
+		e := json.NewEncoder(w)
+		e.SetEscapeHTML(false)
+		return ndjsonEncoder{e}, nil
+	case FormatCSV:
+		return newCSVEncoder(w, recordType)
+	}
+	return nil, fmt.Errorf("不支持的格式: %s", format)
+}
+
+func newDecoder(r io.Reader, format string, recordType reflect.Type) (decoder, error) {
+	switch format {
+	case FormatJSON:
+		d := json.NewDecoder(r)
+		token, err := d.Token()
+		if err != nil {
+			return nil, fmt.Errorf("读取JSON失败: %v", err)
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, fmt.Errorf("JSON格式的数据应为数组")
+		}
+		return jsonDecoder{d}, nil
+	case FormatNDJSON:
+		return ndjsonDecoder{json.NewDecoder(r)}, nil
+	case FormatCSV:
+		return newCSVDecoder(r, recordType)
+	}
+	return nil, fmt.Errorf("不支持的格式: %s", format)
+}
+
+// recordError 单条记录格式错误，跳过该记录后可以继续读取
+type recordError struct {
+	msg string
+}
+
+func (e recordError) Error() string {
+	return e.msg
+}
+
+// asRecordError 将JSON字段类型错误转换为recordError
+func asRecordError(err error) error {
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		return recordError{fmt.Sprintf("字段%s类型错误", typeErr.Field)}
+	}
+	return err
+}
+
+// jsonEncoder 以JSON数组输出，每条记录一行，数组可以边查询边输出
+type jsonEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonEncoder) Encode(record interface{}) error {
+	data, err := marshal(record)
+	if err != nil {
+		return err
+	}
+	prefix := ",\n"
+	if e.count == 0 {
+		prefix = "[\n"
+	}
+	e.count++
+	_, err = io.WriteString(e.w, prefix+string(data))
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	end := "\n]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+type ndjsonEncoder struct {
+	e *json.Encoder
+}
+
+func (e ndjsonEncoder) Encode(record interface{}) error {
+	return e.e.Encode(record)
+}
+
+func (e ndjsonEncoder) Close() error {
+	return nil
+}
+
+type jsonDecoder struct {
+	d *json.Decoder
+}
+
+func (d jsonDecoder) Decode(record interface{}) error {
+	if !d.d.More() {
+		return io.EOF
+	}
+	return asRecordError(d.d.Decode(record))
+}
+
+type ndjsonDecoder struct {
+	d *json.Decoder
+}
+
+func (d ndjsonDecoder) Decode(record interface{}) error {
+	return asRecordError(d.d.Decode(record))
+}
+
+// csvEncoder 以CSV输出，表头为记录的JSON字段名，数组和对象字段以JSON文本保存
+type csvEncoder struct {
+	w      *csv.Writer
+	fields []string
+}
+
+func newCSVEncoder(w io.Writer, recordType reflect.Type) (*csvEncoder, error) {
+	e := &csvEncoder{w: csv.NewWriter(w), fields: columns(recordType)}
+	return e, e.w.Write(e.fields)
+}
+
+func (e *csvEncoder) Encode(record interface{}) error {
+	data, err := marshal(record)
+	if err != nil {
+		return err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	row := make([]string, len(e.fields))
+	for i, field := range e.fields {
+		value := values[field]
+		var s string
+		switch {
+		case string(value) == "null":
+		case json.Unmarshal(value, &s) == nil:
+			row[i] = s
+		default:
+			row[i] = string(value)
+		}
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// csvDecoder 读取CSV，空单元格视为未提供该字段
+type csvDecoder struct {
+	r       *csv.Reader
+	header  []string
+	strings map[string]bool // 字符串类型的字段，其余字段的单元格按JSON解析
+}
+
+func newCSVDecoder(r io.Reader, recordType reflect.Type) (*csvDecoder, error) {
+	d := &csvDecoder{r: csv.NewReader(r), strings: map[string]bool{}}
+	// 允许行的列数与表头不同，缺少的列视为未提供
+	d.r.FieldsPerRecord = -1
+	header, err := d.r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取CSV表头失败: %v", err)
+	}
+	d.header = header
+
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+		if field.Type.Kind() == reflect.String {
+			d.strings[jsonName(field)] = true
+		}
+	}
+	return d, nil
+}
+
+func (d *csvDecoder) Decode(record interface{}) error {
+	row, err := d.r.Read()
+	if err != nil {
+		return err
+	}
+
+	values := map[string]json.RawMessage{}
+	for i, field := range d.header {
+		if i >= len(row) || row[i] == "" {
+			continue
+		}
+		if d.strings[field] {
+			value, err := json.Marshal(row[i])
+			if err != nil {
+				return err
+			}
+			values[field] = value
+		} else {
+			values[field] = json.RawMessage(row[i])
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return recordError{fmt.Sprintf("第%d行格式错误: %v", d.line(), err)}
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return recordError{fmt.Sprintf("第%d行格式错误: %v", d.line(), asRecordError(err))}
+	}
+	return nil
+}
+
+func (d *csvDecoder) line() int {
+	line, _ := d.r.FieldPos(0)
+	return line
+}
+
+// columns 返回记录类型的JSON字段名，作为CSV表头
+func columns(recordType reflect.Type) []string {
+	fields := make([]string, 0, recordType.NumField())
+	for i := 0; i < recordType.NumField(); i++ {
+		fields = append(fields, jsonName(recordType.Field(i)))
+	}
+	return fields
+}
+
+func jsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// marshal 编码为JSON，不转义HTML字符，结尾不带换行
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -0,0 +1,411 @@
+package catalog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *MovieRecord) key(mode string) string {
+	return idKey(r.ID)
+}
+
+func (r *MovieRecord) validate(db *gorm.DB, mode string) []string {
+	var errs []string
+	if strings.TrimSpace(r.Title) == "" {
+		errs = append(errs, "title不能为空")
+	}
+	if mode == ModeTMDBID && r.ID == 0 {
+		errs = append(errs, "按TMDB ID导入时id不能为空")
+	}
+	if r.ReleaseDate != "" {
+		if _, err := time.Parse(dateLayout, r.ReleaseDate); err != nil {
+			errs = append(errs, "release_date格式应为YYYY-MM-DD")
+		}
+	}
+	if r.CollectionID != nil {
+		errs = append(errs, missing(db, &models.Collection{}, "合集", []uint{*r.CollectionID})...)
+	}
+	errs = append(errs, missing(db, &models.Genre{}, "类型", r.Genres)...)
+
+	peopleIDs := make([]int, 0, len(r.Credits))
+	for i, credit := range r.Credits {
+		if credit.CreditType != "cast" && credit.CreditType != "crew" {
+			errs = append(errs, fmt.Sprintf("credits[%d].credit_type只能是cast或crew", i))
+		}
+		peopleIDs = append(peopleIDs, credit.PeopleID)
+	}
+	errs = append(errs, missing(db, &models.People{}, "人物", peopleIDs)...)
+	return append(errs, validateImages(r.Images)...)
+}
+
+func (r *MovieRecord) exists(db *gorm.DB, mode string) (bool, error) {
+	return existsByID(db, &models.Movie{}, r.ID)
+}
+
+func (r *MovieRecord) save(tx *gorm.DB, mode, actor string) (bool, error) {
+	movie := r.model()
+	var existing models.Movie
+	found, err := findByID(tx, &existing, movie.ID)
+	if err != nil {
+		return false, err
+	}
+
+	var before interface{}
+	if found {
+		before = &existing
+		movie.Version = existing.Version + 1
+		// 回收站中的电影更新数据后仍在回收站中
+		err = tx.Unscoped().Model(&movie).Select("*").Omit(clause.Associations, "created_at", "deleted_at").Updates(&movie).Error
+	} else {
+		err = tx.Omit(clause.Associations).Create(&movie).Error
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if r.Genres != nil {
+		if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.MovieGenre{}).Error; err != nil {
+			return false, err
+		}
+		links := make([]models.MovieGenre, 0, len(r.Genres))
+		for _, genreID := range r.Genres {
+			links = append(links, models.MovieGenre{MovieID: movie.ID, GenreID: genreID})
+		}
+		if len(links) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if r.Credits != nil {
+		if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.Credit{}).Error; err != nil {
+			return false, err
+		}
+		credits := make([]models.Credit, 0, len(r.Credits))
+		for _, credit := range r.Credits {
+			id := credit.CreditID
+			if id == "" {
+				id = newCreditID()
+			}
+			credits = append(credits, models.Credit{
+				ID:         id,
+				CreditType: credit.CreditType,
+				Department: credit.Department,
+				Job:        credit.Job,
+				Character:  credit.Character,
+				CastID:     credit.CastID,
+				Order:      credit.Order,
+				MovieID:    int(movie.ID),
+				PeopleID:   credit.PeopleID,
+			})
+		}
+		if len(credits) > 0 {
+			// 演职人员ID已属于其他电影时移到当前电影
+			if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).
+				Create(&credits).Error; err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if r.Images != nil {
+		if err := tx.Where("movie_id = ?", movie.ID).Delete(&models.MovieImage{}).Error; err != nil {
+			return false, err
+		}
+		links := make([]models.MovieImage, 0, len(r.Images))
+		for _, image := range r.Images {
+			links = append(links, models.MovieImage{MovieID: int(movie.ID), ImageFilePath: image.FilePath})
+		}
+		if err := saveImages(tx, r.Images, &links); err != nil {
+			return false, err
+		}
+	}
+
+	return !found, revision.Record(tx, actor, revision.Movie, movie.ID, before, &movie)
+}
+
+func (r *PeopleRecord) key(mode string) string {
+	return idKey(r.ID)
+}
+
+func (r *PeopleRecord) validate(db *gorm.DB, mode string) []string {
+	var errs []string
+	if strings.TrimSpace(r.Name) == "" {
+		errs = append(errs, "name不能为空")
+	}
+	if mode == ModeTMDBID && r.ID == 0 {
+		errs = append(errs, "按TMDB ID导入时id不能为空")
+	}
+	if r.Gender < 0 || r.Gender > 3 {
+		errs = append(errs, "gender只能是0到3")
+	}
+	return append(errs, validateImages(r.Images)...)
+}
+
+func (r *PeopleRecord) exists(db *gorm.DB, mode string) (bool, error) {
+	return existsByID(db, &models.People{}, r.ID)
+}
+
+func (r *PeopleRecord) save(tx *gorm.DB, mode, actor string) (bool, error) {
+	people := r.model()
+	var existing models.People
+	found, err := findByID(tx, &existing, people.ID)
+	if err != nil {
+		return false, err
+	}
+
+	var before interface{}
+	if found {
+		before = &existing
+		people.Version = existing.Version + 1
+		people.SyncedAt = existing.SyncedAt
+		err = tx.Unscoped().Model(&people).Select("*").Omit(clause.Associations, "deleted_at").Updates(&people).Error
+	} else {
+		err = tx.Omit(clause.Associations).Create(&people).Error
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if r.Images != nil {
+		if err := tx.Where("people_id = ?", people.ID).Delete(&models.PeopleImage{}).Error; err != nil {
+			return false, err
+		}
+		links := make([]models.PeopleImage, 0, len(r.Images))
+		for _, image := range r.Images {
+			links = append(links, models.PeopleImage{PeopleID: people.ID, ImageFilePath: image.FilePath})
+		}
+		if err := saveImages(tx, r.Images, &links); err != nil {
+			return false, err
+		}
+	}
+
+	return !found, revision.Record(tx, actor, revision.People, people.ID, before, &people)
+}
+
+func (r *GenreRecord) key(mode string) string {
+	return idKey(r.ID)
+}
+
+func (r *GenreRecord) validate(db *gorm.DB, mode string) []string {
+	var errs []string
+	if strings.TrimSpace(r.Name) == "" {
+		errs = append(errs, "name不能为空")
+	}
+	if mode == ModeTMDBID && r.ID == 0 {
+		errs = append(errs, "按TMDB ID导入时id不能为空")
+	}
+	return errs
+}
+
+func (r *GenreRecord) exists(db *gorm.DB, mode string) (bool, error) {
+	return existsByID(db, &models.Genre{}, r.ID)
+}
+
+func (r *GenreRecord) save(tx *gorm.DB, mode, actor string) (bool, error) {
+	genre := models.Genre{ID: r.ID, Name: r.Name}
+	var existing models.Genre
+	found, err := findByID(tx, &existing, genre.ID)
+	if err != nil {
+		return false, err
+	}
+
+	var before interface{}
+	if found {
+		before = &existing
+		genre.Version = existing.Version + 1
+		err = tx.Unscoped().Model(&genre).Select("name", "version").Updates(&genre).Error
+	} else {
+		err = tx.Create(&genre).Error
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return !found, revision.Record(tx, actor, revision.Genre, genre.ID, before, &genre)
+}
+
+func (r *CollectionRecord) key(mode string) string {
+	if mode == ModeTMDBID && r.TMDBID != nil {
+		return "tmdb:" + idKey(*r.TMDBID)
+	}
+	return idKey(r.ID)
+}
+
+func (r *CollectionRecord) validate(db *gorm.DB, mode string) []string {
+	var errs []string
+	if strings.TrimSpace(r.Name) == "" {
+		errs = append(errs, "name不能为空")
+	}
+	if mode == ModeTMDBID && r.TMDBID == nil {
+		errs = append(errs, "按TMDB ID导入时tmdb_id不能为空")
+	}
+	errs = append(errs, missing(db, &models.Movie{}, "电影", r.MovieIDs)...)
+	return append(errs, validateImages(r.Images)...)
+}
+
+// find 按导入方式查找已有的合集
+func (r *CollectionRecord) find(db *gorm.DB, mode string, existing *models.Collection) (bool, error) {
+	if mode == ModeTMDBID {
+		err := db.Unscoped().Where("tmdb_id = ?", *r.TMDBID).First(existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return findByID(db, existing, r.ID)
+}
+
+func (r *CollectionRecord) exists(db *gorm.DB, mode string) (bool, error) {
+	var existing models.Collection
+	return r.find(db, mode, &existing)
+}
+
+func (r *CollectionRecord) save(tx *gorm.DB, mode, actor string) (bool, error) {
+	collection := r.model()
+	var existing models.Collection
+	found, err := r.find(tx, mode, &existing)
+	if err != nil {
+		return false, err
+	}
+
+	var before interface{}
+	if found {
+		before = &existing
+		collection.ID = existing.ID
+		// 回收站中的合集更新数据后仍在回收站中
+		err = tx.Unscoped().Model(&collection).Select("*").Omit(clause.Associations, "created_at", "deleted_at").Updates(&collection).Error
+	} else {
+		if mode == ModeTMDBID {
+			// 按TMDB ID导入时本地ID由数据库生成
+			collection.ID = 0
+		}
+		err = tx.Omit(clause.Associations).Create(&collection).Error
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if r.MovieIDs != nil {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.CollectionMovie{}).Error; err != nil {
+			return false, err
+		}
+		links := make([]models.CollectionMovie, 0, len(r.MovieIDs))
+		for _, movieID := range r.MovieIDs {
+			links = append(links, models.CollectionMovie{CollectionID: collection.ID, MovieID: movieID})
+		}
+		if len(links) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if r.Images != nil {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.CollectionImage{}).Error; err != nil {
+			return false, err
+		}
+		links := make([]models.CollectionImage, 0, len(r.Images))
+		for _, image := range r.Images {
+			links = append(links, models.CollectionImage{CollectionID: collection.ID, ImageFilePath: image.FilePath})
+		}
+		if err := saveImages(tx, r.Images, &links); err != nil {
+			return false, err
+		}
+	}
+
+	return !found, revision.Record(tx, actor, revision.Collection, collection.ID, before, &collection)
+}
+
+// findByID 按ID查找数据，包括回收站中的数据，id为0时视为不存在
+func findByID(db *gorm.DB, dest interface{}, id interface{}) (bool, error) {
+	if idKey(id) == "" {
+		return false, nil
+	}
+	err := db.Unscoped().First(dest, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func existsByID(db *gorm.DB, model interface{}, id interface{}) (bool, error) {
+	if idKey(id) == "" {
+		return false, nil
+	}
+	var count int64
+	err := db.Unscoped().Model(model).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+// missing 检查引用的数据是否存在，返回不存在的ID对应的错误
+func missing[T ~int | ~uint](db *gorm.DB, model interface{}, name string, ids []T) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	var found []T
+	if err := db.Model(model).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return []string{fmt.Sprintf("查询%s失败: %v", name, err)}
+	}
+	exists := make(map[T]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+
+	var absent []string
+	for _, id := range ids {
+		if !exists[id] {
+			absent = append(absent, fmt.Sprint(id))
+		}
+	}
+	if len(absent) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s不存在: %s", name, strings.Join(absent, ","))}
+}
+
+func validateImages(images []Image) []string {
+	for i, image := range images {
+		if image.FilePath == "" {
+			return []string{fmt.Sprintf("images[%d].file_path不能为空", i)}
+		}
+	}
+	return nil
+}
+
+// saveImages 保存图片记录和关联，links为关联记录的切片指针
+func saveImages(tx *gorm.DB, images []Image, links interface{}) error {
+	if len(images) == 0 {
+		return nil
+	}
+	records := imageModels(images)
+	if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).Create(&records).Error; err != nil {
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(links).Error
+}
+
+// idKey 将ID转换为字符串，ID为0时返回空字符串
+func idKey(id interface{}) string {
+	if s := fmt.Sprint(id); s != "0" {
+		return s
+	}
+	return ""
+}
+
+// newCreditID 生成演职人员记录的ID，与TMDB的credit_id格式一致
+func newCreditID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,289 @@
+package catalog
+
+import (
+	"time"
+
+	"github.com/Estella0129/theater/backend/models"
+)
+
+// dateLayout 导入导出中日期的格式
+const dateLayout = "2006-01-02"
+
+// MovieRecord 导入导出的电影数据，genres、credits和images为空时导入不修改已有的关联
+type MovieRecord struct {
+	ID               uint     `json:"id"`
+	Title            string   `json:"title"`
+	OriginalTitle    string   `json:"original_title"`
+	OriginalLanguage string   `json:"original_language"`
+	Overview         string   `json:"overview"`
+	PosterPath       string   `json:"poster_path"`
+	BackdropPath     string   `json:"backdrop_path"`
+	ReleaseDate      string   `json:"release_date"`
+	Adult            bool     `json:"adult"`
+	Popularity       float64  `json:"popularity"`
+	VoteAverage      float64  `json:"vote_average"`
+	VoteCount        int      `json:"vote_count"`
+	Video            bool     `json:"video"`
+	CollectionID     *uint    `json:"collection_id"`
+	Budget           int      `json:"budget"`
+	Homepage         string   `json:"homepage"`
+	IMDBID           string   `json:"imdb_id"`
+	Runtime          int      `json:"runtime"`
+	Tagline          string   `json:"tagline"`
+	Status           string   `json:"status"`
+	Duration         int      `json:"duration"`
+	Genres           []uint   `json:"genres"` // 类型ID
+	Credits          []Credit `json:"credits"`
+	Images           []Image  `json:"images"`
+}
+
+// Credit 电影的演职人员
+type Credit struct {
+	CreditID   string `json:"credit_id"` // 为空时导入生成新的ID
+	PeopleID   int    `json:"people_id"`
+	CreditType string `json:"credit_type"` // cast或crew
+	Department string `json:"department"`
+	Job        string `json:"job"`
+	Character  string `json:"character"`
+	CastID     int    `json:"cast_id"`
+	Order      int    `json:"order"`
+}
+
+// Image 图片信息
+type Image struct {
+	FilePath    string  `json:"file_path"`
+	Type        string  `json:"type"`
+	AspectRatio float64 `json:"aspect_ratio"`
+	Height      int     `json:"height"`
+	Width       int     `json:"width"`
+	Iso6391     string  `json:"iso_639_1"`
+	VoteAverage float64 `json:"vote_average"`
+	VoteCount   int     `json:"vote_count"`
+}
+
+// PeopleRecord 导入导出的人物数据
+type PeopleRecord struct {
+	ID                 int     `json:"id"`
+	Name               string  `json:"name"`
+	OriginalName       string  `json:"original_name"`
+	Gender             int     `json:"gender"`
+	Adult              bool    `json:"adult"`
+	KnownForDepartment string  `json:"known_for_department"`
+	Popularity         float64 `json:"popularity"`
+	ProfilePath        string  `json:"profile_path"`
+	AlsoKnownAs        string  `json:"also_known_as"`
+	Biography          string  `json:"biography"`
+	Birthday           string  `json:"birthday"`
+	Deathday           string  `json:"deathday"`
+	Homepage           string  `json:"homepage"`
+	PlaceOfBirth       string  `json:"place_of_birth"`
+	IMDBID             string  `json:"imdb_id"`
+	WikidataID         string  `json:"wikidata_id"`
+	InstagramID        string  `json:"instagram_id"`
+	TwitterID          string  `json:"twitter_id"`
+	Images             []Image `json:"images"`
+}
+
+// GenreRecord 导入导出的类型数据
+type GenreRecord struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// CollectionRecord 导入导出的合集数据
+type CollectionRecord struct {
+	ID           uint    `json:"id"`
+	TMDBID       *uint   `json:"tmdb_id"`
+	Name         string  `json:"name"`
+	Overview     string  `json:"overview"`
+	PosterPath   string  `json:"poster_path"`
+	BackdropPath string  `json:"backdrop_path"`
+	MovieIDs     []uint  `json:"movie_ids"`
+	Images       []Image `json:"images"`
+}
+
+func newMovieRecord(m models.Movie) MovieRecord {
+	r := MovieRecord{
+		ID:               m.ID,
+		Title:            m.Title,
+		OriginalTitle:    m.OriginalTitle,
+		OriginalLanguage: m.OriginalLanguage,
+		Overview:         m.Overview,
+		PosterPath:       m.PosterPath,
+		BackdropPath:     m.BackdropPath,
+		Adult:            m.Adult,
+		Popularity:       m.Popularity,
+		VoteAverage:      m.VoteAverage,
+		VoteCount:        m.VoteCount,
+		Video:            m.Video,
+		CollectionID:     m.CollectionID,
+		Budget:           m.Budget,
+		Homepage:         m.Homepage,
+		IMDBID:           m.IMDBID,
+		Runtime:          m.Runtime,
+		Tagline:          m.Tagline,
+		Status:           m.Status,
+		Duration:         m.Duration,
+		Genres:           []uint{},
+		Credits:          []Credit{},
+		Images:           newImages(m.Images),
+	}
+	if !m.ReleaseDate.IsZero() {
+		r.ReleaseDate = m.ReleaseDate.Format(dateLayout)
+	}
+	for _, genre := range m.Genres {
+		r.Genres = append(r.Genres, uint(genre.ID))
+	}
+	for _, credit := range m.Credits {
+		r.Credits = append(r.Credits, Credit{
+			CreditID:   credit.ID,
+			PeopleID:   credit.PeopleID,
+			CreditType: credit.CreditType,
+			Department: credit.Department,
+			Job:        credit.Job,
+			Character:  credit.Character,
+			CastID:     credit.CastID,
+			Order:      credit.Order,
+		})
+	}
+	return r
+}
+
+// model 转换为电影模型，不包括关联数据
+func (r MovieRecord) model() models.Movie {
+	releaseDate, _ := time.Parse(dateLayout, r.ReleaseDate)
+	return models.Movie{
+		ID:               r.ID,
+		Title:            r.Title,
+		OriginalTitle:    r.OriginalTitle,
+		OriginalLanguage: r.OriginalLanguage,
+		Overview:         r.Overview,
+		PosterPath:       r.PosterPath,
+		BackdropPath:     r.BackdropPath,
+		ReleaseDate:      releaseDate,
+		Adult:            r.Adult,
+		Popularity:       r.Popularity,
+		VoteAverage:      r.VoteAverage,
+		VoteCount:        r.VoteCount,
+		Video:            r.Video,
+		CollectionID:     r.CollectionID,
+		Budget:           r.Budget,
+		Homepage:         r.Homepage,
+		IMDBID:           r.IMDBID,
+		Runtime:          r.Runtime,
+		Tagline:          r.Tagline,
+		Status:           r.Status,
+		Duration:         r.Duration,
+	}
+}
+
+func newPeopleRecord(p models.People) PeopleRecord {
+	return PeopleRecord{
+		ID:                 p.ID,
+		Name:               p.Name,
+		OriginalName:       p.OriginalName,
+		Gender:             p.Gender,
+		Adult:              p.Adult,
+		KnownForDepartment: p.KnownForDepartment,
+		Popularity:         p.Popularity,
+		ProfilePath:        p.ProfilePath,
+		AlsoKnownAs:        p.AlsoKnownAs,
+		Biography:          p.Biography,
+		Birthday:           p.Birthday,
+		Deathday:           p.Deathday,
+		Homepage:           p.Homepage,
+		PlaceOfBirth:       p.PlaceOfBirth,
+		IMDBID:             p.IMDBID,
+		WikidataID:         p.WikidataID,
+		InstagramID:        p.InstagramID,
+		TwitterID:          p.TwitterID,
+		Images:             newImages(p.Images),
+	}
+}
+
+// model 转换为人物模型，不包括关联数据
+func (r PeopleRecord) model() models.People {
+	return models.People{
+		ID:                 r.ID,
+		Name:               r.Name,
+		OriginalName:       r.OriginalName,
+		Gender:             r.Gender,
+		Adult:              r.Adult,
+		KnownForDepartment: r.KnownForDepartment,
+		Popularity:         r.Popularity,
+		ProfilePath:        r.ProfilePath,
+		AlsoKnownAs:        r.AlsoKnownAs,
+		Biography:          r.Biography,
+		Birthday:           r.Birthday,
+		Deathday:           r.Deathday,
+		Homepage:           r.Homepage,
+		PlaceOfBirth:       r.PlaceOfBirth,
+		IMDBID:             r.IMDBID,
+		WikidataID:         r.WikidataID,
+		InstagramID:        r.InstagramID,
+		TwitterID:          r.TwitterID,
+	}
+}
+
+func newCollectionRecord(c models.Collection) CollectionRecord {
+	r := CollectionRecord{
+		ID:           c.ID,
+		TMDBID:       c.TMDBID,
+		Name:         c.Name,
+		Overview:     c.Overview,
+		PosterPath:   c.PosterPath,
+		BackdropPath: c.BackdropPath,
+		MovieIDs:     []uint{},
+		Images:       newImages(c.Images),
+	}
+	for _, movie := range c.Movies {
+		r.MovieIDs = append(r.MovieIDs, movie.ID)
+	}
+	return r
+}
+
+// model 转换为合集模型，不包括关联数据
+func (r CollectionRecord) model() models.Collection {
+	return models.Collection{
+		ID:           r.ID,
+		TMDBID:       r.TMDBID,
+		Name:         r.Name,
+		Overview:     r.Overview,
+		PosterPath:   r.PosterPath,
+		BackdropPath: r.BackdropPath,
+	}
+}
+
+func newImages(images []models.Image) []Image {
+	result := make([]Image, 0, len(images))
+	for _, image := range images {
+		result = append(result, Image{
+			FilePath:    image.FilePath,
+			Type:        image.Type,
+			AspectRatio: image.AspectRatio,
+			Height:      image.Height,
+			Width:       image.Width,
+			Iso6391:     image.Iso6391,
+			VoteAverage: image.VoteAverage,
+			VoteCount:   image.VoteCount,
+		})
+	}
+	return result
+}
+
+func imageModels(images []Image) []models.Image {
+	result := make([]models.Image, 0, len(images))
+	for _, image := range images {
+		result = append(result, models.Image{
+			FilePath:    image.FilePath,
+			Type:        image.Type,
+			AspectRatio: image.AspectRatio,
+			Height:      image.Height,
+			Width:       image.Width,
+			Iso6391:     image.Iso6391,
+			VoteAverage: image.VoteAverage,
+			VoteCount:   image.VoteCount,
+		})
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+// Package dbtest 在SQLite、PostgreSQL和MySQL上运行同一组测试。
+// SQLite使用临时文件；PostgreSQL和MySQL需要通过环境变量提供DSN，未设置时跳过。
+// 测试会在外部数据库中建表和删表，DSN应指向专用的空测试库
+package dbtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 外部数据库测试DSN的环境变量
+const (
+	PostgresEnv = "THEATER_TEST_POSTGRES_DSN" // 如 host=localhost user=theater password=theater dbname=theater_test sslmode=disable
+	MySQLEnv    = "THEATER_TEST_MYSQL_DSN"    // 如 theater:theater@tcp(localhost:3306)/theater_test?charset=utf8mb4&parseTime=True
+)
+
+// Drivers 参与测试的数据库驱动
+var Drivers = []string{dialect.SQLite, dialect.Postgres, dialect.MySQL}
+
+// Open 打开driver对应的测试数据库，配置与config.InitDB一致（开启TranslateError），外部数据库未配置DSN时跳过测试
+func Open(t *testing.T, driver string) *gorm.DB {
+	t.Helper()
+	var dialector gorm.Dialector
+	switch driver {
+	case dialect.SQLite:
+		dialector = sqlite.Open(filepath.Join(t.TempDir(), "theater.db"))
+	case dialect.Postgres:
+		dialector = postgres.Open(dsn(t, PostgresEnv))
+	case dialect.MySQL:
+		dialector = mysql.Open(dsn(t, MySQLEnv))
+	default:
+		t.Fatalf("不支持的数据库驱动: %s", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:         logger.Discard,
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatalf("连接%s失败: %v", driver, err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// Run 对每种数据库驱动运行一次子测试
+func Run(t *testing.T, fn func(t *testing.T, db *gorm.DB)) {
+	for _, driver := range Drivers {
+		t.Run(driver, func(t *testing.T) {
+			fn(t, Open(t, driver))
+		})
+	}
+}
+
+func dsn(t *testing.T, env string) string {
+	t.Helper()
+	value := os.Getenv(env)
+	if value == "" {
+		t.Skipf("未设置%s，跳过", env)
+	}
+	return value
+}
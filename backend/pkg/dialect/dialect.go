@@ -0,0 +1,51 @@
+// Package dialect 屏蔽SQLite、PostgreSQL和MySQL之间的差异
+package dialect
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 支持的数据库驱动，与gorm Dialector.Name()一致
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+	MySQL    = "mysql"
+)
+
+// likeEscape LIKE中使用的转义字符，不使用反斜杠以避免MySQL字符串转义的差异
+const likeEscape = "!"
+
+// IsDuplicateKey 是否为唯一约束冲突，需要在gorm.Config中开启TranslateError
+func IsDuplicateKey(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// Search 在columns中做不区分大小写的包含匹配，任一列匹配即可。
+// 查询词中的%和_按普通字符处理
+func Search(db *gorm.DB, query string, columns ...string) *gorm.DB {
+	if len(columns) == 0 {
+		return db
+	}
+
+	// SQLite的LIKE对ASCII字母不区分大小写，MySQL默认排序规则不区分大小写，PostgreSQL需要ILIKE
+	operator := "LIKE"
+	if db.Dialector.Name() == Postgres {
+		operator = "ILIKE"
+	}
+
+	conditions := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns))
+	pattern := "%" + escapeLike(query) + "%"
+	for _, column := range columns {
+		conditions = append(conditions, column+" "+operator+" ? ESCAPE '"+likeEscape+"'")
+		args = append(args, pattern)
+	}
+	return db.Where(strings.Join(conditions, " OR "), args...)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(s)
+}
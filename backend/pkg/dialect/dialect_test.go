@@ -0,0 +1,134 @@
+package dialect_test
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Estella0129/theater/backend/pkg/dbtest"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type dialectItem struct {
+	ID    uint   `gorm:"primaryKey"`
+	Name  string `gorm:"type:varchar(64);uniqueIndex"`
+	Notes string `gorm:"type:varchar(255)"`
+}
+
+func setupItems(t *testing.T, db *gorm.DB, items ...dialectItem) {
+	t.Helper()
+	if err := db.AutoMigrate(&dialectItem{}); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	t.Cleanup(func() { db.Migrator().DropTable(&dialectItem{}) })
+	for i := range items {
+		if err := db.Create(&items[i]).Error; err != nil {
+			t.Fatalf("插入%q失败: %v", items[i].Name, err)
+		}
+	}
+}
+
+func TestIsDuplicateKey(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		setupItems(t, db, dialectItem{Name: "Alien"})
+
+		err := db.Create(&dialectItem{Name: "Alien"}).Error
+		if err == nil {
+			t.Fatal("插入重复的名称应该失败")
+		}
+		if !dialect.IsDuplicateKey(err) {
+			t.Errorf("唯一约束冲突应该被识别: %v", err)
+		}
+
+		err = db.First(&dialectItem{}, "name = ?", "missing").Error
+		if dialect.IsDuplicateKey(err) {
+			t.Errorf("记录不存在不应被识别为唯一约束冲突: %v", err)
+		}
+		if dialect.IsDuplicateKey(errors.New("other")) || dialect.IsDuplicateKey(nil) {
+			t.Error("其他错误不应被识别为唯一约束冲突")
+		}
+	})
+}
+
+func TestSearch(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		setupItems(t, db,
+			dialectItem{Name: "Hello World"},
+			dialectItem{Name: "100% Pure"},
+			dialectItem{Name: "100 Pure"},
+			dialectItem{Name: "snake_case"},
+			dialectItem{Name: "snakeXcase"},
+			dialectItem{Name: "a!b"},
+			dialectItem{Name: "Other", Notes: "hello again"},
+		)
+
+		tests := []struct {
+			name    string
+			query   string
+			columns []string
+			want    []string
+		}{
+			{"小写匹配大写", "hello world", []string{"name"}, []string{"Hello World"}},
+			{"大写匹配小写", "SNAKE_CASE", []string{"name"}, []string{"snake_case"}},
+			{"百分号按普通字符", "100%", []string{"name"}, []string{"100% Pure"}},
+			{"下划线按普通字符", "e_c", []string{"name"}, []string{"snake_case"}},
+			{"转义字符按普通字符", "a!b", []string{"name"}, []string{"a!b"}},
+			{"任一列匹配", "HELLO", []string{"name", "notes"}, []string{"Hello World", "Other"}},
+			{"没有匹配", "nothing", []string{"name"}, nil},
+			{"没有列时不过滤", "nothing", nil, []string{"100 Pure", "100% Pure", "Hello World", "Other", "a!b", "snakeXcase", "snake_case"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var names []string
+				err := dialect.Search(db.Model(&dialectItem{}), tt.query, tt.columns...).Pluck("name", &names).Error
+				if err != nil {
+					t.Fatalf("查询失败: %v", err)
+				}
+				sort.Strings(names)
+				if len(names) != len(tt.want) {
+					t.Fatalf("查询%q结果为%v，应为%v", tt.query, names, tt.want)
+				}
+				for i := range names {
+					if names[i] != tt.want[i] {
+						t.Fatalf("查询%q结果为%v，应为%v", tt.query, names, tt.want)
+					}
+				}
+			})
+		}
+	})
+}
+
+// TestSearchSQL 不连接数据库，检查各驱动生成的条件，没有PostgreSQL时也能覆盖ILIKE分支
+func TestSearchSQL(t *testing.T) {
+	tests := []struct {
+		driver    string
+		dialector gorm.Dialector
+		want      string
+	}{
+		{dialect.SQLite, sqlite.Open("file::memory:"), "name LIKE ? ESCAPE '!' OR notes LIKE ? ESCAPE '!'"},
+		{dialect.Postgres, postgres.New(postgres.Config{DSN: "host=localhost"}), "name ILIKE $1 ESCAPE '!' OR notes ILIKE $2 ESCAPE '!'"},
+		{dialect.MySQL, mysql.New(mysql.Config{DSN: "user@tcp(localhost)/test", SkipInitializeWithVersion: true}), "name LIKE ? ESCAPE '!' OR notes LIKE ? ESCAPE '!'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			db, err := gorm.Open(tt.dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+			if err != nil {
+				t.Fatalf("创建%s的Dialector失败: %v", tt.driver, err)
+			}
+			stmt := dialect.Search(db.Model(&dialectItem{}), "50%_off", "name", "notes").Find(&[]dialectItem{}).Statement
+			if sql := stmt.SQL.String(); !strings.Contains(sql, tt.want) {
+				t.Errorf("生成的SQL为%s，应包含%s", sql, tt.want)
+			}
+			for _, arg := range stmt.Vars {
+				if arg != "%50!%!_off%" {
+					t.Errorf("参数为%v，应为%%50!%%!_off%%", arg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// Package fieldlock 字段锁，保护编辑手动修改过的字段不被TMDB同步覆盖
+package fieldlock
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lockable 返回实体可以锁定的字段，即修改记录中比较的基本类型字段，不包括关联数据
+func Lockable(model interface{}) map[string]bool {
+	lockable := map[string]bool{}
+	modelType := reflect.TypeOf(model)
+	if modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		name := jsonName(field)
+		if name == "" || name == "id" || revision.Ignored(name) || !field.IsExported() {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		switch fieldType.Kind() {
+		case reflect.Slice, reflect.Map:
+			continue
+		case reflect.Struct:
+			if fieldType != reflect.TypeOf(time.Time{}) {
+				continue
+			}
+		}
+		lockable[name] = true
+	}
+	return lockable
+}
+
+// Locked 返回实体已锁定的字段
+func Locked(db *gorm.DB, entityType string, entityID interface{}) (map[string]bool, error) {
+	locked, err := LockedIn(db, entityType, []string{fmt.Sprint(entityID)})
+	if err != nil {
+		return nil, err
+	}
+	return locked[fmt.Sprint(entityID)], nil
+}
+
+// LockedIn 批量返回实体已锁定的字段，按实体ID分组，没有锁的实体不在结果中
+func LockedIn(db *gorm.DB, entityType string, entityIDs []string) (map[string]map[string]bool, error) {
+	result := map[string]map[string]bool{}
+	if len(entityIDs) == 0 {
+		return result, nil
+	}
+
+	var locks []models.FieldLock
+	if err := db.Where("entity_type = ? AND entity_id IN ?", entityType, entityIDs).Find(&locks).Error; err != nil {
+		return nil, fmt.Errorf("查询字段锁失败: %v", err)
+	}
+	for _, lock := range locks {
+		if result[lock.EntityID] == nil {
+			result[lock.EntityID] = map[string]bool{}
+		}
+		result[lock.EntityID][lock.Field] = true
+	}
+	return result, nil
+}
+
+// Lock 锁定实体的字段，已锁定的字段保持不变
+func Lock(tx *gorm.DB, actor, entityType string, entityID interface{}, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	locks := make([]models.FieldLock, 0, len(fields))
+	for _, field := range fields {
+		locks = append(locks, models.FieldLock{
+			EntityType: entityType,
+			EntityID:   fmt.Sprint(entityID),
+			Field:      field,
+			Actor:      actor,
+		})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&locks).Error
+}
+
+// LockChanged 锁定修改前后值不同的字段，用于编辑时自动加锁。类型、演职人员等关联数据不能锁定
+func LockChanged(tx *gorm.DB, actor, entityType string, entityID interface{}, before, after interface{}) error {
+	oldFields, err := revision.Fields(before)
+	if err != nil {
+		return err
+	}
+	newFields, err := revision.Fields(after)
+	if err != nil {
+		return err
+	}
+
+	lockable := Lockable(after)
+	var fields []string
+	for field := range revision.Diff(oldFields, newFields) {
+		if lockable[field] {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return Lock(tx, actor, entityType, entityID, fields)
+}
+
+// Unlock 解除字段锁，同时清除这些字段的同步冲突记录
+func Unlock(tx *gorm.DB, entityType string, entityID interface{}, fields []string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	id := fmt.Sprint(entityID)
+	result := tx.Where("entity_type = ? AND entity_id = ? AND field IN ?", entityType, id, fields).Delete(&models.FieldLock{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if err := tx.Where("entity_type = ? AND entity_id = ? AND field IN ?", entityType, id, fields).
+		Delete(&models.SyncConflict{}).Error; err != nil {
+		return 0, err
+	}
+	return result.RowsAffected, nil
+}
+
+// Clear 清除实体的所有字段锁和同步冲突记录，用于永久删除实体
+func Clear(tx *gorm.DB, entityType string, entityID interface{}) error {
+	id := fmt.Sprint(entityID)
+	if err := tx.Where("entity_type = ? AND entity_id = ?", entityType, id).Delete(&models.FieldLock{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("entity_type = ? AND entity_id = ?", entityType, id).Delete(&models.SyncConflict{}).Error
+}
+
+// Keep 将remote中被锁定的字段恢复为local中的值，local和remote为同一类型的结构体指针，
+// 返回值不同而被跳过的字段
+func Keep(entityType string, entityID interface{}, locked map[string]bool, local, remote interface{}) ([]models.SyncConflict, error) {
+	if len(locked) == 0 {
+		return nil, nil
+	}
+
+	localValue := reflect.ValueOf(local).Elem()
+	remoteValue := reflect.ValueOf(remote).Elem()
+	if localValue.Type() != remoteValue.Type() {
+		return nil, fmt.Errorf("字段锁比较的类型不一致: %s, %s", localValue.Type(), remoteValue.Type())
+	}
+
+	var conflicts []models.SyncConflict
+	for i := 0; i < remoteValue.NumField(); i++ {
+		name := jsonName(remoteValue.Type().Field(i))
+		if !locked[name] || !remoteValue.Field(i).CanSet() {
+			continue
+		}
+
+		localJSON, err := json.Marshal(localValue.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		remoteJSON, err := json.Marshal(remoteValue.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if string(localJSON) == string(remoteJSON) {
+			continue
+		}
+
+		remoteValue.Field(i).Set(localValue.Field(i))
+		conflicts = append(conflicts, models.SyncConflict{
+			EntityType:  entityType,
+			EntityID:    fmt.Sprint(entityID),
+			Field:       name,
+			LocalValue:  string(localJSON),
+			RemoteValue: string(remoteJSON),
+		})
+	}
+	return conflicts, nil
+}
+
+// Report 保存同步时跳过的冲突，同一字段的冲突更新为最新的值
+func Report(db *gorm.DB, job string, conflicts []models.SyncConflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	for i := range conflicts {
+		conflicts[i].Job = job
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "entity_type"}, {Name: "entity_id"}, {Name: "field"}},
+		DoUpdates: clause.AssignmentColumns([]string{"local_value", "remote_value", "job", "updated_at"}),
+	}).Create(&conflicts).Error
+}
+
+// jsonName 结构体字段在JSON中的名称
+func jsonName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
@@ -0,0 +1,212 @@
+// Package job 管理在后台运行的任务，任务只保存在内存中，服务重启后丢失
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 任务状态
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// 单项处理结果
+const (
+	ResultOK      = "ok"
+	ResultSkipped = "skipped"
+	ResultFailed  = "failed"
+)
+
+// retention 已结束任务的保留时间
+const retention = 24 * time.Hour
+
+// ErrStopped 服务停止时中断的任务返回的错误
+var ErrStopped = errors.New("服务停止，任务已中断")
+
+// Result 任务中单项数据的处理结果
+type Result struct {
+	ID      interface{} `json:"id"`
+	Status  string      `json:"status"`
+	Message string      `json:"message,omitempty"` // 跳过的原因
+	Error   string      `json:"error,omitempty"`   // 失败的原因
+}
+
+// Count 统计成功、跳过和失败的数量
+func Count(results []Result) (succeeded, skipped, failed int) {
+	for _, r := range results {
+		switch r.Status {
+		case ResultOK:
+			succeeded++
+		case ResultSkipped:
+			skipped++
+		case ResultFailed:
+			failed++
+		}
+	}
+	return
+}
+
+// Job 后台任务
+type Job struct {
+	mu         sync.Mutex
+	id         string
+	jobType    string
+	status     string
+	total      int
+	results    []Result
+	err        string
+	createdAt  time.Time
+	finishedAt *time.Time
+}
+
+// Info 任务的状态快照，用于接口返回
+type Info struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Succeeded  int        `json:"succeeded"`
+	Skipped    int        `json:"skipped"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	Results    []Result   `json:"results"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+var (
+	mu   sync.Mutex
+	jobs = map[string]*Job{}
+
+	// running 正在运行的任务，stop在服务停止时取消所有任务的Context
+	running   sync.WaitGroup
+	ctx, stop = context.WithCancelCause(context.Background())
+)
+
+// ID 返回任务ID
+func (j *Job) ID() string {
+	return j.id
+}
+
+// Context 服务停止时取消，长时间运行的任务应在处理每批数据前检查，
+// 已取消时返回context.Cause(ctx)即ErrStopped
+func (j *Job) Context() context.Context {
+	return ctx
+}
+
+// Add 记录单项数据的处理结果
+func (j *Job) Add(results ...Result) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.results = append(j.results, results...)
+}
+
+// Info 返回任务当前的状态
+func (j *Job) Info() Info {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info := Info{
+		ID:         j.id,
+		Type:       j.jobType,
+		Status:     j.status,
+		Total:      j.total,
+		Processed:  len(j.results),
+		Error:      j.err,
+		Results:    append([]Result(nil), j.results...),
+		CreatedAt:  j.createdAt,
+		FinishedAt: j.finishedAt,
+	}
+	info.Succeeded, info.Skipped, info.Failed = Count(j.results)
+	return info
+}
+
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.finishedAt = &now
+	j.status = StatusDone
+	if err != nil {
+		j.status = StatusFailed
+		j.err = err.Error()
+	}
+}
+
+// Start 创建任务并在后台运行，total为要处理的数据数量
+func Start(jobType string, total int, run func(j *Job) error) *Job {
+	j := &Job{
+		id:        newID(),
+		jobType:   jobType,
+		status:    StatusRunning,
+		total:     total,
+		createdAt: time.Now(),
+	}
+
+	mu.Lock()
+	prune()
+	jobs[j.id] = j
+	mu.Unlock()
+
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		j.finish(run(j))
+	}()
+	return j
+}
+
+// Shutdown 通知所有任务停止并等待正在运行的任务结束，ctx结束时不再等待并返回错误
+func Shutdown(shutdownCtx context.Context) error {
+	stop(ErrStopped)
+
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-shutdownCtx.Done():
+		return fmt.Errorf("等待后台任务结束超时: %v", shutdownCtx.Err())
+	}
+}
+
+// Get 根据ID获取任务
+func Get(id string) (*Job, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	j, ok := jobs[id]
+	return j, ok
+}
+
+// prune 清理超过保留时间的已结束任务，调用时需持有mu
+func prune() {
+	expired := time.Now().Add(-retention)
+	for id, j := range jobs {
+		j.mu.Lock()
+		finishedAt := j.finishedAt
+		j.mu.Unlock()
+		if finishedAt != nil && finishedAt.Before(expired) {
+			delete(jobs, id)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
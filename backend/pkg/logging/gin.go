@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求ID的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey 请求ID在gin.Context中的键
+const RequestIDKey = "request_id"
+
+// validRequestID 沿用客户端或反向代理传入的请求ID时，只接受较短的字母、数字和-_.:
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9\-_.:]{1,128}$`)
+
+// Middleware 为每个请求分配请求ID并记录访问日志，请求头中有合法的X-Request-ID时沿用。
+// 请求ID保存在请求的context中，handler中使用c.Request.Context()的日志和数据库查询都会带有请求ID
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Set(RequestIDKey, id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.Duration("elapsed", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.Any("errors", c.Errors.Errors()))
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(c.Request.Context(), level, "请求", attrs...)
+	}
+}
+
+// Recovery 处理handler中的panic，记录带请求ID的错误日志并返回500
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err interface{}) {
+		slog.ErrorContext(c.Request.Context(), "请求处理panic", "error", err, "stack", string(debug.Stack()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// GormLogger 将gorm日志写入slog：所有SQL为Debug级别，慢查询为Warn级别，查询错误为Error级别，
+// 使用WithContext传入请求的context时日志带有请求ID
+type GormLogger struct {
+	SlowThreshold time.Duration // 超过该时间的查询记录为慢查询，0为不记录
+}
+
+// LogMode gorm的日志级别由slog的级别控制，这里不做处理
+func (l GormLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// Trace 记录一次SQL执行，记录不存在不视为错误
+func (l GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	slow := l.SlowThreshold > 0 && elapsed > l.SlowThreshold
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+
+	level := slog.LevelDebug
+	msg := "SQL"
+	switch {
+	case failed:
+		level, msg = slog.LevelError, "SQL执行失败"
+	case slow:
+		level, msg = slog.LevelWarn, "慢查询"
+	}
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Duration("elapsed", elapsed),
+	}
+	if failed {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	slog.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,88 @@
+// Package logging 基于log/slog的结构化日志，请求ID从context中自动添加到日志中
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// 日志格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+)
+
+// ParseLevel 解析日志级别：debug、info、warn、error
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("日志级别应为debug、info、warn或error: %q", level)
+	}
+	return l, nil
+}
+
+// Setup 设置默认的slog日志，日志输出到标准错误，标准输出留给命令的结果。
+// 设置后标准库log的输出也转为slog的Info级别日志
+func Setup(level, format string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(contextHandler{newHandler(os.Stderr, l, format)}))
+	return nil
+}
+
+func newHandler(w io.Writer, level slog.Level, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(format, FormatJSON) {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// WithRequestID 在context中保存请求ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID 获取context中的请求ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Fatal 输出Error级别的日志后以状态码1退出，用于命令行命令无法继续执行的错误
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler 将context中的请求ID添加到每条日志
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
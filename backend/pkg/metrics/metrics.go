@@ -0,0 +1,150 @@
+// Package metrics Prometheus监控指标，包括HTTP请求、数据库查询、TMDB请求、图片缓存和同步状态
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// namespace 所有指标名称的前缀
+const namespace = "theater"
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP请求处理时间，route为路由模板",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "数据库查询时间",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	dbQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "数据库查询错误次数，不包括记录不存在",
+	}, []string{"operation", "table"})
+
+	// TMDBRequests TMDB API请求次数，code为响应状态码，请求失败时为error
+	TMDBRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tmdb_requests_total",
+		Help:      "TMDB API请求次数，endpoint中的数字ID替换为:id",
+	}, []string{"endpoint", "code"})
+
+	// TMDBErrors TMDB API请求失败或返回非2xx状态码的次数
+	TMDBErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tmdb_request_errors_total",
+		Help:      "TMDB API请求失败或返回非2xx状态码的次数",
+	}, []string{"endpoint"})
+
+	// TMDBRateLimited TMDB API返回429的次数
+	TMDBRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tmdb_rate_limited_total",
+		Help:      "TMDB API返回429的次数",
+	}, []string{"endpoint"})
+
+	// ImageCacheRequests /images请求的本地缓存结果：hit、miss(从TMDB下载)或error
+	ImageCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_cache_requests_total",
+		Help:      "/images请求的本地缓存结果，hit、miss或error",
+	}, []string{"result"})
+
+	// RateLimited 超过限流返回429的次数，rule为限流规则
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "超过限流返回429的次数，rule为限流规则，登录锁定为lockout",
+	}, []string{"rule"})
+
+	syncLastSuccess = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sync", "last_success_timestamp_seconds"),
+		"每种同步任务最近一次成功的时间",
+		[]string{"type"}, nil,
+	)
+	syncLastFailure = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sync", "last_failure_timestamp_seconds"),
+		"每种同步任务最近一次失败的时间",
+		[]string{"type"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(httpRequestDuration, dbQueryDuration, dbQueryErrors,
+		TMDBRequests, TMDBErrors, TMDBRateLimited, ImageCacheRequests, RateLimited)
+}
+
+// Handler 输出所有指标的/metrics处理器
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware 记录每个请求的处理时间，未匹配路由的请求route为unmatched，避免路径成为高基数标签
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDB 注册数据库连接池和同步状态指标，同步状态在每次采集时从sync_runs表读取
+func RegisterDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return errors.Join(
+		prometheus.Register(collectors.NewDBStatsCollector(sqlDB, namespace)),
+		prometheus.Register(syncCollector{db: db}),
+	)
+}
+
+// syncCollector 从sync_runs表读取同步状态，同步可能在其他进程中执行，不能只记录在内存中
+type syncCollector struct {
+	db *gorm.DB
+}
+
+func (s syncCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- syncLastSuccess
+	ch <- syncLastFailure
+}
+
+func (s syncCollector) Collect(ch chan<- prometheus.Metric) {
+	var runs []models.SyncRun
+	if err := s.db.Find(&runs).Error; err != nil {
+		ch <- prometheus.NewInvalidMetric(syncLastSuccess, err)
+		return
+	}
+	for _, run := range runs {
+		if run.LastSuccessAt != nil {
+			ch <- prometheus.MustNewConstMetric(syncLastSuccess, prometheus.GaugeValue, float64(run.LastSuccessAt.Unix()), run.Type)
+		}
+		if run.LastFailureAt != nil {
+			ch <- prometheus.MustNewConstMetric(syncLastFailure, prometheus.GaugeValue, float64(run.LastFailureAt.Unix()), run.Type)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Package baseline 基线迁移（版本1）创建时的表结构快照，之后models中的修改不会影响基线迁移。
+// 表名、索引名和外键约束名由类型名和字段名生成，不能修改，只能由新的迁移修改表结构
+package baseline
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Models 基线迁移创建的表，User的many2many关联同时创建user_favorite_movies表
+var Models = []interface{}{
+	&MovieImage{},
+	&Movie{},
+	&User{},
+	&Genre{},
+	&MovieGenre{},
+	&Image{},
+	&People{},
+	&Credit{},
+	&PeopleImage{},
+	&Collection{},
+	&CollectionMovie{},
+	&CollectionImage{},
+	&Revision{},
+	&FieldLock{},
+	&SyncConflict{},
+}
+
+// Tables 回滚时删除的表，关联表在前，被引用的表在后
+var Tables = []string{
+	"user_favorite_movies",
+	"movie_images",
+	"movie_genres",
+	"people_images",
+	"collection_movies",
+	"collection_images",
+	"credits",
+	"users",
+	"movies",
+	"genres",
+	"images",
+	"peoples",
+	"collections",
+	"revisions",
+	"field_locks",
+	"sync_conflicts",
+}
+
+type Movie struct {
+	gorm.Model
+	ID                  uint `gorm:"primaryKey"`
+	Title               string
+	OriginalTitle       string
+	OriginalLanguage    string
+	Overview            string
+	PosterPath          string
+	BackdropPath        string
+	ReleaseDate         time.Time
+	Adult               bool
+	Popularity          float64
+	VoteAverage         float64
+	VoteCount           int
+	Video               bool
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	DeletedAt           gorm.DeletedAt `gorm:"index"`
+	BelongsToCollection *Collection    `gorm:"foreignKey:CollectionID"`
+	CollectionID        *uint
+	Budget              int
+	Homepage            string
+	IMDBID              string
+	Runtime             int
+	Tagline             string
+	Status              string
+	Duration            int
+	Version             int `gorm:"not null;default:1"`
+
+	Director *Credit  `gorm:"foreignKey:MovieID;references:ID;association_autocreate:false"`
+	Credits  []Credit `gorm:"foreignKey:MovieID;references:ID;association_autocreate:false"`
+	Cast     []Credit `gorm:"foreignKey:MovieID;references:ID;association_autocreate:false"`
+
+	Images []Image `gorm:"many2many:movie_images;foreignKey:ID;joinForeignKey:MovieID;References:FilePath;joinReferences:ImageFilePath;association_autocreate:false"`
+	Genres []Genre `gorm:"many2many:movie_genres;foreignKey:ID;joinForeignKey:MovieID;References:ID;joinReferences:GenreID;association_autocreate:true"`
+}
+
+type MovieGenre struct {
+	MovieID uint `gorm:"primaryKey;type:int;column:movie_id"`
+	GenreID uint `gorm:"primaryKey;type:int;column:genre_id"`
+}
+
+type User struct {
+	ID        uint   `gorm:"primaryKey"`
+	Username  string `gorm:"unique;not null"`
+	Name      string `gorm:"default:''"`
+	Password  string `gorm:"not null"`
+	Email     string `gorm:"unique;not null"`
+	Role      string `gorm:"default:'user'"`
+	Gender    string `gorm:"default:''"`
+	IsFrozen  bool   `gorm:"default:false"`
+	Version   int    `gorm:"not null;default:1"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	FavoriteMovies []Movie `gorm:"many2many:user_favorite_movies;"`
+}
+
+type Genre struct {
+	ID        int            `gorm:"column:id;primaryKey;autoIncrement;not null"`
+	Name      string         `gorm:"column:name;not null"`
+	Version   int            `gorm:"column:version;not null;default:1"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+type Image struct {
+	Type        string  `gorm:"type:varchar(32);column:type"`
+	AspectRatio float64 `gorm:"type:double;column:aspect_ratio"`
+	Height      int     `gorm:"type:int;column:height"`
+	Width       int     `gorm:"type:int;column:width"`
+	Iso6391     string  `gorm:"type:varchar(32);column:iso_639_1"`
+	FilePath    string  `gorm:"primaryKey;type:varchar(255);column:file_path"`
+	VoteAverage float64 `gorm:"type:double;column:vote_average"`
+	VoteCount   int     `gorm:"type:int;column:vote_count"`
+
+	Movies []Movie `gorm:"many2many:movie_images;foreignKey:FilePath;joinForeignKey:ImageFilePath;References:ID;joinReferences:MovieID"`
+}
+
+type MovieImage struct {
+	MovieID       int    `gorm:"primaryKey;type:int;column:movie_id"`
+	ImageFilePath string `gorm:"primaryKey;type:varchar(255);column:image_file_path"`
+}
+
+type PeopleImage struct {
+	PeopleID      int    `gorm:"primaryKey;type:int;column:people_id"`
+	ImageFilePath string `gorm:"primaryKey;type:varchar(255);column:image_file_path"`
+}
+
+type People struct {
+	ID                 int     `gorm:"primaryKey;column:id"`
+	Name               string  `gorm:"type:varchar(255);column:name"`
+	OriginalName       string  `gorm:"type:varchar(255);column:original_name"`
+	Gender             int     `gorm:"type:int;column:gender"`
+	Adult              bool    `gorm:"type:boolean;column:adult"`
+	KnownForDepartment string  `gorm:"type:varchar(255);column:known_for_department"`
+	Popularity         float64 `gorm:"type:double;column:popularity"`
+	ProfilePath        string  `gorm:"type:varchar(255);column:profile_path"`
+	AlsoKnownAs        string  `gorm:"type:text;column:also_known_as"`
+	Biography          string  `gorm:"type:text;column:biography"`
+	Birthday           string  `gorm:"type:date;column:birthday"`
+	Deathday           string  `gorm:"type:date;column:deathday"`
+	Homepage           string  `gorm:"type:varchar(255);column:homepage"`
+	PlaceOfBirth       string  `gorm:"type:varchar(255);column:place_of_birth"`
+
+	IMDBID      string `gorm:"type:varchar(32);column:imdb_id"`
+	WikidataID  string `gorm:"type:varchar(32);column:wikidata_id"`
+	InstagramID string `gorm:"type:varchar(255);column:instagram_id"`
+	TwitterID   string `gorm:"type:varchar(255);column:twitter_id"`
+
+	SyncedAt *time.Time `gorm:"column:synced_at"`
+	Version  int        `gorm:"column:version;not null;default:1"`
+
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+
+	Credits []Credit `gorm:"foreignKey:PeopleID;references:ID"`
+	Images  []Image  `gorm:"many2many:people_images;foreignKey:ID;joinForeignKey:PeopleID;References:FilePath;joinReferences:ImageFilePath;association_autocreate:false"`
+}
+
+type Credit struct {
+	ID         string `gorm:"primaryKey;type:varchar(255);column:credit_id"`
+	CreditType string `gorm:"type:varchar(255);column:credit_type"`
+	Department string `gorm:"type:varchar(255);column:department"`
+	Job        string `gorm:"type:varchar(255);column:job"`
+	Character  string `gorm:"type:varchar(255);column:character"`
+	CastID     int    `gorm:"type:int;column:cast_id"`
+	Order      int    `gorm:"type:int;column:order"`
+
+	MovieID int    `gorm:"type:int;column:movie_id"`
+	Movie   *Movie `gorm:"foreignKey:MovieID;references:ID"`
+
+	PeopleID int     `gorm:"type:int;column:people_id"`
+	People   *People `gorm:"foreignKey:PeopleID;references:ID"`
+}
+
+type Collection struct {
+	ID           uint  `gorm:"primaryKey"`
+	TMDBID       *uint `gorm:"uniqueIndex"`
+	Name         string
+	Overview     string
+	PosterPath   string
+	BackdropPath string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+
+	Movies []Movie `gorm:"many2many:collection_movies;foreignKey:ID;joinForeignKey:CollectionID;References:ID;joinReferences:MovieID"`
+	Images []Image `gorm:"many2many:collection_images;foreignKey:ID;joinForeignKey:CollectionID;References:FilePath;joinReferences:ImageFilePath;association_autocreate:false"`
+}
+
+type CollectionMovie struct {
+	CollectionID uint `gorm:"primaryKey;type:int;column:collection_id"`
+	MovieID      uint `gorm:"primaryKey;type:int;column:movie_id"`
+}
+
+type CollectionImage struct {
+	CollectionID  uint   `gorm:"primaryKey;type:int;column:collection_id"`
+	ImageFilePath string `gorm:"primaryKey;type:varchar(255);column:image_file_path"`
+}
+
+type Revision struct {
+	ID         uint      `gorm:"primaryKey"`
+	EntityType string    `gorm:"type:varchar(32);index:idx_revision_entity;not null"`
+	EntityID   string    `gorm:"type:varchar(64);index:idx_revision_entity;not null"`
+	Action     string    `gorm:"type:varchar(16);not null"`
+	Actor      string    `gorm:"type:varchar(64);not null"`
+	Diff       string    `gorm:"type:text"`
+	Snapshot   string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+type FieldLock struct {
+	ID         uint   `gorm:"primaryKey"`
+	EntityType string `gorm:"type:varchar(32);uniqueIndex:idx_field_lock;not null"`
+	EntityID   string `gorm:"type:varchar(64);uniqueIndex:idx_field_lock;not null"`
+	Field      string `gorm:"type:varchar(64);uniqueIndex:idx_field_lock;not null"`
+	Actor      string `gorm:"type:varchar(64);not null"`
+	CreatedAt  time.Time
+}
+
+type SyncConflict struct {
+	ID          uint   `gorm:"primaryKey"`
+	EntityType  string `gorm:"type:varchar(32);uniqueIndex:idx_sync_conflict;not null"`
+	EntityID    string `gorm:"type:varchar(64);uniqueIndex:idx_sync_conflict;not null"`
+	Field       string `gorm:"type:varchar(64);uniqueIndex:idx_sync_conflict;not null"`
+	LocalValue  string `gorm:"type:text"`
+	RemoteValue string `gorm:"type:text"`
+	Job         string `gorm:"type:varchar(64)"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
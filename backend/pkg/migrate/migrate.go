@@ -0,0 +1,269 @@
+// Package migrate 带版本号的数据库迁移，已执行的版本记录在schema_migrations表中
+package migrate
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一次数据库迁移，Up和Down在同一个事务中执行。
+// MySQL的DDL会隐式提交，迁移失败时可能只执行了一部分
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // 为空表示不能回滚
+}
+
+// SchemaMigration 已执行的迁移
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"type:varchar(255);not null" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
+
+// TableName 迁移记录表
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 迁移的执行状态
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Unknown   bool       `json:"unknown,omitempty"` // 数据库中有记录，但当前程序中没有该迁移，通常是数据库由更新的版本迁移过
+}
+
+// PendingError 有未执行的迁移或数据库版本比程序新
+type PendingError struct {
+	NoTable bool  // 迁移记录表不存在，数据库从未执行过迁移
+	Pending []int // 未执行的迁移版本
+	Unknown []int // 程序中不存在的已执行版本
+}
+
+func (e *PendingError) Error() string {
+	if e.NoTable {
+		return "数据库中没有迁移记录表schema_migrations，请先运行 migrate up"
+	}
+	if len(e.Unknown) > 0 {
+		return fmt.Sprintf("数据库中有当前程序不认识的迁移版本 %v，请使用更新的程序", e.Unknown)
+	}
+	return fmt.Sprintf("有%d个未执行的数据库迁移 %v，请先运行 migrate up", len(e.Pending), e.Pending)
+}
+
+// Logger 输出迁移进度，为空时不输出
+var Logger *slog.Logger
+
+func logMigration(msg string, m Migration) {
+	if Logger != nil {
+		Logger.Info(msg, "version", m.Version, "name", m.Name)
+	}
+}
+
+// sorted 按版本号排序的迁移列表
+func sorted() []Migration {
+	list := append([]Migration(nil), migrations...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// hasTable 迁移记录表是否存在
+func hasTable(db *gorm.DB) bool {
+	return db.Migrator().HasTable(&SchemaMigration{})
+}
+
+// applied 返回已执行的迁移，迁移记录表不存在时返回空，不会创建表
+func applied(db *gorm.DB) (map[int]SchemaMigration, error) {
+	if !hasTable(db) {
+		return map[int]SchemaMigration{}, nil
+	}
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[int]SchemaMigration, len(records))
+	for _, record := range records {
+		result[record.Version] = record
+	}
+	return result, nil
+}
+
+// Statuses 返回所有迁移的执行状态，按版本号排序
+func Statuses(db *gorm.DB) ([]Status, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	known := map[int]bool{}
+	for _, m := range sorted() {
+		known[m.Version] = true
+		status := Status{Version: m.Version, Name: m.Name}
+		if record, ok := done[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for version, record := range done {
+		if !known[version] {
+			appliedAt := record.AppliedAt
+			statuses = append(statuses, Status{Version: version, Name: record.Name, Applied: true, AppliedAt: &appliedAt, Unknown: true})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Check 检查数据库是否已执行所有迁移，否则返回*PendingError，只读取不修改数据库
+func Check(db *gorm.DB) error {
+	statuses, err := Statuses(db)
+	if err != nil {
+		return err
+	}
+	pendingErr := &PendingError{NoTable: !hasTable(db)}
+	for _, status := range statuses {
+		switch {
+		case status.Unknown:
+			pendingErr.Unknown = append(pendingErr.Unknown, status.Version)
+		case !status.Applied:
+			pendingErr.Pending = append(pendingErr.Pending, status.Version)
+		}
+	}
+	if pendingErr.NoTable || len(pendingErr.Pending) > 0 || len(pendingErr.Unknown) > 0 {
+		return pendingErr
+	}
+	return nil
+}
+
+// Current 返回已执行的最大迁移版本，未执行任何迁移时返回0
+func Current(db *gorm.DB) (int, error) {
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for version := range done {
+		if version > current {
+			current = version
+		}
+	}
+	return current, nil
+}
+
+// Latest 返回程序中最新的迁移版本
+func Latest() int {
+	list := sorted()
+	if len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Version
+}
+
+// Up 执行所有未执行的迁移，返回执行的数量
+func Up(db *gorm.DB) (int, error) {
+	return To(db, Latest())
+}
+
+// Down 回滚最近执行的steps个迁移，返回回滚的数量
+func Down(db *gorm.DB, steps int) (int, error) {
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+	list := sorted()
+	count := 0
+	for i := len(list) - 1; i >= 0 && count < steps; i-- {
+		if _, ok := done[list[i].Version]; !ok {
+			continue
+		}
+		if err := rollback(db, list[i]); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// To 迁移到指定版本：执行不超过该版本的未执行迁移，回滚高于该版本的已执行迁移，返回处理的数量。
+// 迁移记录表不存在时创建
+func To(db *gorm.DB, version int) (int, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return 0, fmt.Errorf("创建迁移记录表失败: %v", err)
+	}
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+	list := sorted()
+	if version != 0 && !hasVersion(list, version) {
+		return 0, fmt.Errorf("迁移版本%d不存在", version)
+	}
+
+	count := 0
+	for i := len(list) - 1; i >= 0; i-- {
+		m := list[i]
+		if _, ok := done[m.Version]; ok && m.Version > version {
+			if err := rollback(db, m); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	for _, m := range list {
+		if _, ok := done[m.Version]; !ok && m.Version <= version {
+			if err := apply(db, m); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func hasVersion(list []Migration, version int) bool {
+	for _, m := range list {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func apply(db *gorm.DB, m Migration) error {
+	logMigration("执行迁移", m)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("执行迁移%d_%s失败: %v", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+func rollback(db *gorm.DB, m Migration) error {
+	if m.Down == nil {
+		return fmt.Errorf("迁移%d_%s不能回滚", m.Version, m.Name)
+	}
+	logMigration("回滚迁移", m)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("回滚迁移%d_%s失败: %v", m.Version, m.Name, err)
+	}
+	return nil
+}
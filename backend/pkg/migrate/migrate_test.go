@@ -0,0 +1,126 @@
+package migrate_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Estella0129/theater/backend/pkg/dbtest"
+	"github.com/Estella0129/theater/backend/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// tables 所有迁移执行后应存在的表
+var tables = []string{
+	"movies", "users", "user_favorite_movies", "genres", "movie_genres", "images", "movie_images",
+	"peoples", "people_images", "credits", "collections", "collection_movies", "collection_images",
+	"revisions", "field_locks", "sync_conflicts", "production_companies", "sync_runs", "rate_limits",
+}
+
+// cleanup 测试结束后回滚所有迁移并删除迁移记录表，外部数据库可以重复测试
+func cleanup(t *testing.T, db *gorm.DB) {
+	t.Cleanup(func() {
+		if _, err := migrate.To(db, 0); err != nil {
+			t.Errorf("回滚所有迁移失败: %v", err)
+		}
+		db.Migrator().DropTable(&migrate.SchemaMigration{})
+	})
+}
+
+func TestCheckWithoutTable(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		err := migrate.Check(db)
+		var pendingErr *migrate.PendingError
+		if !errors.As(err, &pendingErr) {
+			t.Fatalf("空数据库检查应返回PendingError，实际为%v", err)
+		}
+		if !pendingErr.NoTable || len(pendingErr.Pending) != migrate.Latest() {
+			t.Errorf("应报告迁移记录表不存在且所有迁移未执行: %+v", pendingErr)
+		}
+		if db.Migrator().HasTable(&migrate.SchemaMigration{}) {
+			t.Error("Check不应创建迁移记录表")
+		}
+	})
+}
+
+func TestUpDown(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		cleanup(t, db)
+
+		count, err := migrate.Up(db)
+		if err != nil {
+			t.Fatalf("执行迁移失败: %v", err)
+		}
+		if count != migrate.Latest() {
+			t.Errorf("执行了%d个迁移，应为%d个", count, migrate.Latest())
+		}
+		if err := migrate.Check(db); err != nil {
+			t.Errorf("执行所有迁移后检查失败: %v", err)
+		}
+		if current, _ := migrate.Current(db); current != migrate.Latest() {
+			t.Errorf("当前版本为%d，应为%d", current, migrate.Latest())
+		}
+		for _, table := range tables {
+			if !db.Migrator().HasTable(table) {
+				t.Errorf("缺少表%s", table)
+			}
+		}
+
+		if !db.Migrator().HasColumn("collections", "deleted_at") || !db.Migrator().HasIndex("collections", "idx_collections_deleted_at") {
+			t.Error("collections表缺少deleted_at字段或索引")
+		}
+
+		// 再次执行不做任何事
+		if count, err := migrate.Up(db); err != nil || count != 0 {
+			t.Errorf("重复执行迁移应不做任何事，执行了%d个: %v", count, err)
+		}
+
+		if count, err := migrate.Down(db, 1); err != nil || count != 1 {
+			t.Fatalf("回滚1个迁移失败，回滚了%d个: %v", count, err)
+		}
+		var pendingErr *migrate.PendingError
+		if err := migrate.Check(db); !errors.As(err, &pendingErr) || len(pendingErr.Pending) != 1 || pendingErr.Pending[0] != migrate.Latest() {
+			t.Errorf("回滚后应只有最新的迁移未执行: %v", err)
+		}
+		if db.Migrator().HasColumn("collections", "deleted_at") {
+			t.Error("回滚迁移6后collections表仍有deleted_at字段")
+		}
+
+		if _, err := migrate.To(db, 0); err != nil {
+			t.Fatalf("回滚所有迁移失败: %v", err)
+		}
+		for _, table := range tables {
+			if db.Migrator().HasTable(table) {
+				t.Errorf("回滚所有迁移后表%s仍然存在", table)
+			}
+		}
+
+		// 回滚后可以重新执行
+		if _, err := migrate.Up(db); err != nil {
+			t.Fatalf("回滚后重新执行迁移失败: %v", err)
+		}
+		if err := migrate.Check(db); err != nil {
+			t.Errorf("重新执行迁移后检查失败: %v", err)
+		}
+	})
+}
+
+func TestCheckUnknownVersion(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, db *gorm.DB) {
+		cleanup(t, db)
+
+		if _, err := migrate.Up(db); err != nil {
+			t.Fatalf("执行迁移失败: %v", err)
+		}
+		future := migrate.SchemaMigration{Version: migrate.Latest() + 100, Name: "future", AppliedAt: time.Now()}
+		if err := db.Create(&future).Error; err != nil {
+			t.Fatalf("插入迁移记录失败: %v", err)
+		}
+		t.Cleanup(func() { db.Delete(&future) })
+
+		var pendingErr *migrate.PendingError
+		if err := migrate.Check(db); !errors.As(err, &pendingErr) || len(pendingErr.Unknown) != 1 {
+			t.Errorf("应报告程序不认识的迁移版本: %v", err)
+		}
+	})
+}
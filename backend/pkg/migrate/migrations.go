@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"time"
+
+	"github.com/Estella0129/theater/backend/pkg/migrate/baseline"
+	"gorm.io/gorm"
+)
+
+// migrations 所有迁移，版本号只能递增，已发布的迁移不要修改。
+// 迁移使用迁移内定义的表结构快照，不能引用models中的类型，否则修改模型会改变已发布迁移的结果；
+// 新的表结构变化需要新增迁移，用Migrator的AddColumn、CreateIndex等明确写出每一步，Down按相反的顺序撤销
+var migrations = []Migration{
+	{Version: 1, Name: "initial_schema", Up: initialSchemaUp, Down: initialSchemaDown},
+	{Version: 2, Name: "drop_movies_cast", Up: dropMoviesCastUp, Down: dropMoviesCastDown},
+	{Version: 3, Name: "create_production_companies", Up: createProductionCompaniesUp, Down: createProductionCompaniesDown},
+	{Version: 4, Name: "create_sync_runs", Up: createSyncRunsUp, Down: createSyncRunsDown},
+	{Version: 5, Name: "create_rate_limits", Up: createRateLimitsUp, Down: createRateLimitsDown},
+	{Version: 6, Name: "add_collections_deleted_at", Up: addCollectionsDeletedAtUp, Down: addCollectionsDeletedAtDown},
+}
+
+// initialSchemaUp 基线表结构，与之前每次启动时AutoMigrate的结果一致，已有的数据库执行时只补充缺少的表和字段
+func initialSchemaUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(baseline.Models...)
+}
+
+func initialSchemaDown(tx *gorm.DB) error {
+	for _, table := range baseline.Tables {
+		if err := tx.Migrator().DropTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropMoviesCastUp 旧版本的movies.cast字段已由credits表中的演员数据取代
+func dropMoviesCastUp(tx *gorm.DB) error {
+	if tx.Migrator().HasColumn("movies", "cast") {
+		return tx.Migrator().DropColumn("movies", "cast")
+	}
+	return nil
+}
+
+// dropMoviesCastDown 旧字段已不再使用，回滚时不恢复
+func dropMoviesCastDown(tx *gorm.DB) error {
+	return nil
+}
+
+// productionCompany 迁移3创建的production_companies表结构
+type productionCompany struct {
+	ID            uint `gorm:"primaryKey"`
+	MovieID       uint
+	Name          string
+	LogoPath      string
+	OriginCountry string
+}
+
+func (productionCompany) TableName() string {
+	return "production_companies"
+}
+
+func createProductionCompaniesUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&productionCompany{})
+}
+
+func createProductionCompaniesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("production_companies")
+}
+
+// syncRun 迁移4创建的sync_runs表结构
+type syncRun struct {
+	Type          string `gorm:"type:varchar(32);primaryKey"`
+	LastSuccessAt *time.Time
+	LastFailureAt *time.Time
+	LastError     string `gorm:"type:text"`
+	UpdatedAt     time.Time
+}
+
+func (syncRun) TableName() string {
+	return "sync_runs"
+}
+
+func createSyncRunsUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&syncRun{})
+}
+
+func createSyncRunsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("sync_runs")
+}
+
+// rateLimit 迁移5创建的rate_limits表结构
+type rateLimit struct {
+	Key         string `gorm:"type:varchar(191);primaryKey"`
+	Tokens      float64
+	RefilledAt  time.Time
+	Failures    int
+	LockedUntil time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+}
+
+func (rateLimit) TableName() string {
+	return "rate_limits"
+}
+
+func createRateLimitsUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&rateLimit{})
+}
+
+func createRateLimitsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable("rate_limits")
+}
+
+// collectionDeletedAt 迁移6为collections表添加的软删除字段
+type collectionDeletedAt struct {
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (collectionDeletedAt) TableName() string {
+	return "collections"
+}
+
+func addCollectionsDeletedAtUp(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if !m.HasColumn(&collectionDeletedAt{}, "DeletedAt") {
+		if err := m.AddColumn(&collectionDeletedAt{}, "DeletedAt"); err != nil {
+			return err
+		}
+	}
+	if !m.HasIndex(&collectionDeletedAt{}, "DeletedAt") {
+		return m.CreateIndex(&collectionDeletedAt{}, "DeletedAt")
+	}
+	return nil
+}
+
+func addCollectionsDeletedAtDown(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if m.HasIndex(&collectionDeletedAt{}, "DeletedAt") {
+		if err := m.DropIndex(&collectionDeletedAt{}, "DeletedAt"); err != nil {
+			return err
+		}
+	}
+	if m.HasColumn(&collectionDeletedAt{}, "DeletedAt") {
+		return m.DropColumn(&collectionDeletedAt{}, "DeletedAt")
+	}
+	return nil
+}
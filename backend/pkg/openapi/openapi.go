@@ -0,0 +1,358 @@
+// Package openapi 生成OpenAPI 3.0文档，请求和响应的结构由Go类型通过反射生成
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version 生成的文档使用的OpenAPI版本
+const Version = "3.0.3"
+
+// Document OpenAPI文档
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	schemas *generator
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem 同一路径下各请求方法的接口，键为小写的请求方法
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 认证方式
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Operation 一个接口
+type Operation struct {
+	Tags         []string              `json:"tags,omitempty"`
+	Summary      string                `json:"summary,omitempty"`
+	Description  string                `json:"description,omitempty"`
+	OperationID  string                `json:"operationId"`
+	Parameters   []*Parameter          `json:"parameters,omitempty"`
+	RequestBody  *RequestBody          `json:"requestBody,omitempty"`
+	Responses    map[string]*Response  `json:"responses"`
+	Requirements []map[string][]string `json:"security,omitempty"` // 需要的认证方式
+
+	doc *Document
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// New 创建文档，serverURL为接口路径的前缀
+func New(title, version, serverURL string) *Document {
+	schemas := map[string]*Schema{}
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Servers: []Server{{URL: serverURL}},
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas:   schemas,
+			Responses: map[string]*Response{},
+		},
+		schemas: newGenerator(schemas),
+	}
+}
+
+// Schema 生成Go值对应的Schema，具名结构体放入components并返回引用
+func (d *Document) Schema(v interface{}) *Schema {
+	return d.schemas.schemaOf(v)
+}
+
+// Tag 添加标签说明
+func (d *Document) Tag(name, description string) {
+	d.Tags = append(d.Tags, Tag{Name: name, Description: description})
+}
+
+// Route 添加接口，path使用gin的路由格式，如/movies/:id，路径参数自动加入参数列表
+func (d *Document) Route(method, path string) *Operation {
+	specPath, params := convertPath(path)
+	op := &Operation{
+		OperationID: operationID(method, path),
+		Responses:   map[string]*Response{},
+		doc:         d,
+	}
+	for _, name := range params {
+		schema := String()
+		if name == "id" {
+			schema = Integer()
+		}
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+
+	item, ok := d.Paths[specPath]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[specPath] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+	return op
+}
+
+// Has 文档中是否有该接口，path使用gin的路由格式
+func (d *Document) Has(method, path string) bool {
+	specPath, _ := convertPath(path)
+	item, ok := d.Paths[specPath]
+	if !ok {
+		return false
+	}
+	_, ok = (*item)[strings.ToLower(method)]
+	return ok
+}
+
+// Operations 文档中的全部接口，格式为"METHOD /path"，路径为OpenAPI格式
+func (d *Document) Operations() []string {
+	var operations []string
+	for path, item := range d.Paths {
+		for method := range *item {
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// Doc 设置接口摘要，可以附带更详细的说明
+func (o *Operation) Doc(summary string, description ...string) *Operation {
+	o.Summary = summary
+	o.Description = strings.Join(description, "\n\n")
+	return o
+}
+
+// Tag 设置接口标签
+func (o *Operation) Tag(tags ...string) *Operation {
+	o.Tags = append(o.Tags, tags...)
+	return o
+}
+
+// ID 覆盖自动生成的operationId
+func (o *Operation) ID(id string) *Operation {
+	o.OperationID = id
+	return o
+}
+
+// Param 设置路径参数的类型和说明
+func (o *Operation) Param(name string, schema *Schema, description string) *Operation {
+	for _, p := range o.Parameters {
+		if p.In == "path" && p.Name == name {
+			p.Schema = schema
+			p.Description = description
+			return o
+		}
+	}
+	panic(fmt.Sprintf("openapi: %s没有路径参数%s", o.OperationID, name))
+}
+
+// Query 添加查询参数
+func (o *Operation) Query(name string, schema *Schema, description string) *Operation {
+	o.Parameters = append(o.Parameters, &Parameter{Name: name, In: "query", Schema: schema, Description: description})
+	return o
+}
+
+// Header 添加请求头参数
+func (o *Operation) Header(name string, required bool, description string) *Operation {
+	o.Parameters = append(o.Parameters, &Parameter{Name: name, In: "header", Required: required, Schema: String(), Description: description})
+	return o
+}
+
+// Body 设置JSON请求体
+func (o *Operation) Body(v interface{}) *Operation {
+	return o.BodyContent("application/json", o.doc.schemaFor(v))
+}
+
+// BodyContent 设置指定Content-Type的请求体，可以多次调用添加多种格式
+func (o *Operation) BodyContent(contentType string, schema *Schema) *Operation {
+	if o.RequestBody == nil {
+		o.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{}}
+	}
+	o.RequestBody.Content[contentType] = &MediaType{Schema: schema}
+	return o
+}
+
+// Returns 添加JSON响应，v为nil时响应没有内容
+func (o *Operation) Returns(status int, v interface{}, description ...string) *Operation {
+	if v == nil {
+		return o.ReturnsContent(status, "", nil, description...)
+	}
+	return o.ReturnsContent(status, "application/json", o.doc.schemaFor(v), description...)
+}
+
+// ReturnsContent 添加指定Content-Type的响应，可以多次调用添加多种格式
+func (o *Operation) ReturnsContent(status int, contentType string, schema *Schema, description ...string) *Operation {
+	key := strconv.Itoa(status)
+	response, ok := o.Responses[key]
+	if !ok {
+		response = &Response{Description: http.StatusText(status)}
+		o.Responses[key] = response
+	}
+	if len(description) > 0 {
+		response.Description = strings.Join(description, "\n\n")
+	}
+	if contentType != "" {
+		if response.Content == nil {
+			response.Content = map[string]*MediaType{}
+		}
+		response.Content[contentType] = &MediaType{Schema: schema}
+	}
+	return o
+}
+
+// ResponseHeader 为已添加的响应设置响应头
+func (o *Operation) ResponseHeader(status int, name, description string) *Operation {
+	response, ok := o.Responses[strconv.Itoa(status)]
+	if !ok {
+		panic(fmt.Sprintf("openapi: %s没有%d响应", o.OperationID, status))
+	}
+	if response.Headers == nil {
+		response.Headers = map[string]*Header{}
+	}
+	response.Headers[name] = &Header{Description: description, Schema: String()}
+	return o
+}
+
+// Errors 添加引用components中公共响应的错误响应，公共响应以状态码命名
+func (o *Operation) Errors(statuses ...int) *Operation {
+	for _, status := range statuses {
+		key := strconv.Itoa(status)
+		if _, ok := o.doc.Components.Responses[key]; !ok {
+			panic(fmt.Sprintf("openapi: 没有公共响应%d", status))
+		}
+		o.Responses[key] = &Response{Ref: "#/components/responses/" + key}
+	}
+	return o
+}
+
+// BearerAuth 添加Authorization: Bearer <token>形式的认证方式
+func (d *Document) BearerAuth(name, format, description string) {
+	if d.Components.SecuritySchemes == nil {
+		d.Components.SecuritySchemes = map[string]*SecurityScheme{}
+	}
+	d.Components.SecuritySchemes[name] = &SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: format, Description: description}
+}
+
+// Security 接口需要components中名为name的认证方式
+func (o *Operation) Security(name string) *Operation {
+	if _, ok := o.doc.Components.SecuritySchemes[name]; !ok {
+		panic(fmt.Sprintf("openapi: 没有认证方式%s", name))
+	}
+	o.Requirements = append(o.Requirements, map[string][]string{name: {}})
+	return o
+}
+
+// ErrorResponse 添加公共错误响应，v为错误响应体
+func (d *Document) ErrorResponse(status int, v interface{}, description string) {
+	d.Components.Responses[strconv.Itoa(status)] = &Response{
+		Description: description,
+		Content:     map[string]*MediaType{"application/json": {Schema: d.schemaFor(v)}},
+	}
+}
+
+// schemaFor v为*Schema时直接使用，否则由Go类型生成
+func (d *Document) schemaFor(v interface{}) *Schema {
+	if schema, ok := v.(*Schema); ok {
+		return schema
+	}
+	return d.Schema(v)
+}
+
+// Path 将gin的路由格式转换为OpenAPI格式，如/movies/:id为/movies/{id}
+func Path(path string) string {
+	specPath, _ := convertPath(path)
+	return specPath
+}
+
+// convertPath 将gin的路由格式转换为OpenAPI格式，并返回路径参数名
+func convertPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID 由请求方法和路径生成operationId，如GET /movies/:id为getMoviesById
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			b.WriteString("By")
+			segment = segment[1:]
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
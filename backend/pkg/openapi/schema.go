@@ -0,0 +1,340 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Schema OpenAPI 3.0的Schema，只包含用到的字段
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// String 字符串
+func String() *Schema {
+	return &Schema{Type: "string"}
+}
+
+// Integer 整数
+func Integer() *Schema {
+	return &Schema{Type: "integer"}
+}
+
+// Boolean 布尔值
+func Boolean() *Schema {
+	return &Schema{Type: "boolean"}
+}
+
+// Binary 二进制文件
+func Binary() *Schema {
+	return &Schema{Type: "string", Format: "binary"}
+}
+
+// Any 任意JSON值
+func Any() *Schema {
+	return &Schema{}
+}
+
+// Enum 只能取给定值之一的字符串
+func Enum(values ...string) *Schema {
+	schema := String()
+	for _, v := range values {
+		schema.Enum = append(schema.Enum, v)
+	}
+	return schema
+}
+
+// Object 由属性组成的对象，required为必有的属性
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// ArrayOf 数组
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// OneOf 符合其中之一的值
+func OneOf(schemas ...*Schema) *Schema {
+	return &Schema{OneOf: schemas}
+}
+
+// MapOf 值类型相同、键为字符串的对象
+func MapOf(values *Schema) *Schema {
+	return &Schema{Type: "object", AdditionalProperties: values}
+}
+
+// WithDefault 设置默认值
+func (s *Schema) WithDefault(value interface{}) *Schema {
+	s.Default = value
+	return s
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+)
+
+// generator 由Go类型生成Schema，具名结构体生成一次后放入components
+type generator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+	used       map[string]reflect.Type
+}
+
+func newGenerator(components map[string]*Schema) *generator {
+	return &generator{
+		components: components,
+		names:      map[reflect.Type]string{},
+		used:       map[string]reflect.Type{},
+	}
+}
+
+func (g *generator) schemaOf(v interface{}) *Schema {
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return Any()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case deletedAtType:
+		return &Schema{Type: "string", Format: "date-time", Nullable: true}
+	case rawJSONType:
+		return Any()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(g.schema(t.Elem()))
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return Integer()
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return String()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return ArrayOf(g.schema(t.Elem()))
+	case reflect.Map:
+		return MapOf(g.schema(t.Elem()))
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.ref(t)
+	}
+	return Any()
+}
+
+// ref 具名结构体放入components并返回引用，先登记名称以支持相互引用的结构体
+func (g *generator) ref(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = g.componentName(t)
+		g.names[t] = name
+		g.used[name] = t
+		g.components[name] = g.structSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// componentName models和handlers中的类型直接使用类型名，其他包的类型加上包名，如JobInfo
+func (g *generator) componentName(t reflect.Type) string {
+	name := exported(t.Name())
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	if pkg != "models" && pkg != "handlers" && !strings.HasPrefix(strings.ToLower(name), pkg) {
+		name = exported(pkg) + name
+	}
+	for i := 2; ; i++ {
+		if other, ok := g.used[name]; !ok || other == t {
+			return name
+		}
+		name = strings.TrimRight(name, "0123456789") + strconv.Itoa(i)
+	}
+}
+
+// structSchema 按encoding/json的规则展开结构体字段，嵌入结构体的字段提升到外层，
+// 同名时外层字段优先
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	schema := Object(map[string]*Schema{})
+	g.addFields(schema, t, map[string]bool{})
+	return schema
+}
+
+func (g *generator) addFields(schema *Schema, t reflect.Type, seen map[string]bool) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				embedded = append(embedded, fieldType)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		property := g.schema(field.Type)
+		if strings.Contains(options, "string") {
+			property = String()
+		}
+		if applyBinding(property, field) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+	for _, e := range embedded {
+		g.addFields(schema, e, seen)
+	}
+}
+
+// applyBinding 将binding标签中的校验规则写入Schema，返回字段是否必填
+func applyBinding(schema *Schema, field reflect.StructField) bool {
+	tag := field.Tag.Get("binding")
+	if tag == "" {
+		return false
+	}
+	// 引用的Schema不能添加约束，包装在allOf中
+	if schema.Ref != "" {
+		*schema = Schema{AllOf: []*Schema{{Ref: schema.Ref}}}
+	}
+
+	kind := field.Type.Kind()
+	if kind == reflect.Pointer {
+		kind = field.Type.Elem().Kind()
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				if n, err := strconv.Atoi(value); err == nil && kind != reflect.String {
+					schema.Enum = append(schema.Enum, n)
+				} else {
+					schema.Enum = append(schema.Enum, value)
+				}
+			}
+		case "min", "max", "gte", "lte", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			lower := key == "min" || key == "gte" || key == "len"
+			upper := key == "max" || key == "lte" || key == "len"
+			switch kind {
+			case reflect.String:
+				if lower {
+					schema.MinLength = integer(n)
+				}
+				if upper {
+					schema.MaxLength = integer(n)
+				}
+			case reflect.Slice, reflect.Array, reflect.Map:
+				if lower {
+					schema.MinItems = integer(n)
+				}
+				if upper {
+					schema.MaxItems = integer(n)
+				}
+			default:
+				if lower {
+					schema.Minimum = float(n)
+				}
+				if upper {
+					schema.Maximum = float(n)
+				}
+			}
+		}
+	}
+	return required
+}
+
+// nullable 允许为null，引用的Schema在OpenAPI 3.0中需要包装在allOf中
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+	}
+	schema.Nullable = true
+	return schema
+}
+
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func integer(n float64) *int {
+	i := int(n)
+	return &i
+}
+
+func float(n float64) *float64 {
+	return &n
+}
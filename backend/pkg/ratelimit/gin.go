@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/Estella0129/theater/backend/pkg/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc 返回请求在令牌桶中的key，为空时不限流
+type KeyFunc func(c *gin.Context) string
+
+// Middleware 按key限流的中间件，超过限制时返回429
+func Middleware(b *Bucket, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Allow(c, b, key(c)) {
+			c.Next()
+		}
+	}
+}
+
+// Allow 消耗key的一个令牌，超过限制时返回429和Retry-After并返回false。
+// 读写限流状态失败时记录日志后放行，避免数据库故障时所有请求都被拒绝
+func Allow(c *gin.Context, b *Bucket, key string) bool {
+	if key == "" {
+		return true
+	}
+	wait, err := b.Allow(c.Request.Context(), key)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "读取限流状态失败", "rule", b.Name, "error", err)
+		return true
+	}
+	if wait > 0 {
+		metrics.RateLimited.WithLabelValues(b.Name).Inc()
+		Reject(c, wait, apierror.New(http.StatusTooManyRequests, apierror.CodeTooManyRequests,
+			"Too many requests, please try again later", "请求过于频繁，请稍后再试"))
+		return false
+	}
+	return true
+}
+
+// Reject 返回429，Retry-After和retry_after为需要等待的秒数
+func Reject(c *gin.Context, wait time.Duration, err *apierror.Error) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	apierror.Respond(c, err.With("retry_after", seconds))
+}
+
+// RetryMessage 等待时间的中文描述，如3分钟、45秒
+func RetryMessage(wait time.Duration) string {
+	if wait >= time.Minute {
+		return fmt.Sprintf("%d分钟", int(math.Ceil(wait.Minutes())))
+	}
+	return fmt.Sprintf("%d秒", int(math.Ceil(wait.Seconds())))
+}
+
+// ClientIP 请求的客户端IP。gin只在连接来自server.trusted_proxies时使用X-Forwarded-For；
+// 通过Unix socket连接时没有远端地址，连接只能来自本机的反向代理，使用代理传入的客户端IP
+func ClientIP(c *gin.Context) string {
+	if ip := c.ClientIP(); ip != "" {
+		return ip
+	}
+	if ip := strings.TrimSpace(c.GetHeader("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	forwarded := strings.Split(c.GetHeader("X-Forwarded-For"), ",")
+	// 反向代理追加在最后的是直接连接它的客户端
+	if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return "unix"
+}
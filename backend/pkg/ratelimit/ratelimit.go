@@ -0,0 +1,131 @@
+// Package ratelimit 令牌桶限流和登录失败锁定，状态保存在内存中，多个实例共享限制时保存在数据库中
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// State 一个key的限流状态
+type State struct {
+	Tokens      float64   // 剩余令牌数
+	RefilledAt  time.Time // 上次补充令牌的时间，为零时桶是满的
+	Failures    int       // 连续失败次数
+	LockedUntil time.Time // 锁定的到期时间
+	ExpiresAt   time.Time // 过期后状态与零值相同，不晚于当前时间时不再保存
+}
+
+// Store 保存限流状态。Update读取key的状态交给fn修改后保存，同一个key的Update串行执行，
+// 状态不存在或已过期时fn收到零值
+type Store interface {
+	Update(ctx context.Context, key string, fn func(s *State)) error
+}
+
+// Bucket 令牌桶，每Period补充Rate个令牌，最多积累Burst个，每次请求消耗一个。Rate为0时不限制
+type Bucket struct {
+	Name   string // 规则名称，作为key的前缀和监控指标的标签
+	Rate   int
+	Period time.Duration
+	Burst  int
+
+	store Store
+}
+
+// NewBucket 创建保存在store中的令牌桶
+func NewBucket(store Store, name string, rate int, period time.Duration, burst int) *Bucket {
+	return &Bucket{Name: name, Rate: rate, Period: period, Burst: burst, store: store}
+}
+
+// Allow 消耗key的一个令牌，没有令牌时不消耗并返回需要等待的时间
+func (b *Bucket) Allow(ctx context.Context, key string) (time.Duration, error) {
+	if b == nil || b.Rate <= 0 {
+		return 0, nil
+	}
+	// 补充一个令牌的时间
+	interval := float64(b.Period) / float64(b.Rate)
+	burst := float64(b.Burst)
+
+	var wait time.Duration
+	err := b.store.Update(ctx, b.Name+":"+key, func(s *State) {
+		now := time.Now()
+		if s.RefilledAt.IsZero() {
+			s.Tokens = burst
+		} else if elapsed := now.Sub(s.RefilledAt); elapsed > 0 {
+			// 多个实例的时钟可能不一致，时间倒退时不补充
+			s.Tokens = min(burst, s.Tokens+float64(elapsed)/interval)
+		}
+		s.RefilledAt = now
+
+		if s.Tokens >= 1 {
+			s.Tokens--
+			wait = 0
+		} else {
+			wait = time.Duration((1 - s.Tokens) * interval)
+		}
+		// 令牌补满后与新的桶相同
+		s.ExpiresAt = now.Add(time.Duration((burst - s.Tokens) * interval))
+	})
+	return wait, err
+}
+
+// Lockout 连续失败MaxFailures次后锁定Duration，之后每次失败锁定时间翻倍，最长MaxDuration。
+// MaxFailures为0时不锁定
+type Lockout struct {
+	MaxFailures int
+	Duration    time.Duration
+	MaxDuration time.Duration
+
+	store Store
+}
+
+// lockoutPrefix 锁定状态的key前缀
+const lockoutPrefix = "lockout:"
+
+// NewLockout 创建状态保存在store中的登录锁定
+func NewLockout(store Store, maxFailures int, duration, maxDuration time.Duration) *Lockout {
+	return &Lockout{MaxFailures: maxFailures, Duration: duration, MaxDuration: maxDuration, store: store}
+}
+
+// Check 返回key剩余的锁定时间，没有锁定时为0
+func (l *Lockout) Check(ctx context.Context, key string) (time.Duration, error) {
+	if l == nil || l.MaxFailures <= 0 {
+		return 0, nil
+	}
+	var wait time.Duration
+	err := l.store.Update(ctx, lockoutPrefix+key, func(s *State) {
+		wait = max(time.Until(s.LockedUntil), 0)
+	})
+	return wait, err
+}
+
+// Fail 记录一次失败，达到MaxFailures后返回锁定时间
+func (l *Lockout) Fail(ctx context.Context, key string) (time.Duration, error) {
+	if l == nil || l.MaxFailures <= 0 {
+		return 0, nil
+	}
+	var locked time.Duration
+	err := l.store.Update(ctx, lockoutPrefix+key, func(s *State) {
+		now := time.Now()
+		s.Failures++
+		if over := s.Failures - l.MaxFailures; over >= 0 {
+			locked = l.MaxDuration
+			if over < 32 && l.Duration<<over < l.MaxDuration {
+				locked = l.Duration << over
+			}
+			s.LockedUntil = now.Add(locked)
+		}
+		// 锁定结束后的MaxDuration内再失败继续累计
+		s.ExpiresAt = now.Add(locked + l.MaxDuration)
+	})
+	return locked, err
+}
+
+// Reset 清除key的失败记录，如登录成功后
+func (l *Lockout) Reset(ctx context.Context, key string) error {
+	if l == nil || l.MaxFailures <= 0 {
+		return nil
+	}
+	return l.store.Update(ctx, lockoutPrefix+key, func(s *State) {
+		*s = State{}
+	})
+}
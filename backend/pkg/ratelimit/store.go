@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sweepInterval 清理过期状态的间隔
+const sweepInterval = time.Minute
+
+// MemoryStore 保存在内存中的限流状态，只在当前进程内有效
+type MemoryStore struct {
+	mu        sync.Mutex
+	states    map[string]State
+	nextSweep time.Time
+}
+
+// NewMemoryStore 创建内存中的限流状态
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: map[string]State{}}
+}
+
+// Update 见Store
+func (m *MemoryStore) Update(ctx context.Context, key string, fn func(s *State)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.After(m.nextSweep) {
+		for k, s := range m.states {
+			if !s.ExpiresAt.After(now) {
+				delete(m.states, k)
+			}
+		}
+		m.nextSweep = now.Add(sweepInterval)
+	}
+
+	s := m.states[key]
+	if !s.ExpiresAt.After(now) {
+		s = State{}
+	}
+	fn(&s)
+	if s.ExpiresAt.After(now) {
+		m.states[key] = s
+	} else {
+		delete(m.states, key)
+	}
+	return nil
+}
+
+// DBStore 保存在rate_limits表中的限流状态，多个实例共享
+type DBStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	nextSweep time.Time
+}
+
+// NewDBStore 创建保存在数据库中的限流状态
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Update 见Store，在事务中锁定记录后修改。同一个key第一次并发写入时唯一约束冲突，重试一次
+func (d *DBStore) Update(ctx context.Context, key string, fn func(s *State)) error {
+	d.sweep(ctx)
+
+	err := d.update(ctx, key, fn)
+	if dialect.IsDuplicateKey(err) {
+		err = d.update(ctx, key, fn)
+	}
+	return err
+}
+
+func (d *DBStore) update(ctx context.Context, key string, fn func(s *State)) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var record models.RateLimit
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&models.RateLimit{Key: key}).First(&record).Error
+		exists := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		now := time.Now()
+		var s State
+		if exists && record.ExpiresAt.After(now) {
+			s = State{
+				Tokens:      record.Tokens,
+				RefilledAt:  record.RefilledAt,
+				Failures:    record.Failures,
+				LockedUntil: record.LockedUntil,
+				ExpiresAt:   record.ExpiresAt,
+			}
+		}
+		fn(&s)
+
+		if !s.ExpiresAt.After(now) {
+			if exists {
+				return tx.Delete(&record).Error
+			}
+			return nil
+		}
+		record = models.RateLimit{
+			Key:         key,
+			Tokens:      s.Tokens,
+			RefilledAt:  s.RefilledAt,
+			Failures:    s.Failures,
+			LockedUntil: s.LockedUntil,
+			ExpiresAt:   s.ExpiresAt,
+		}
+		if exists {
+			return tx.Save(&record).Error
+		}
+		return tx.Create(&record).Error
+	})
+}
+
+// sweep 定期删除过期的记录，失败时下次再试
+func (d *DBStore) sweep(ctx context.Context) {
+	d.mu.Lock()
+	now := time.Now()
+	if now.Before(d.nextSweep) {
+		d.mu.Unlock()
+		return
+	}
+	d.nextSweep = now.Add(sweepInterval)
+	d.mu.Unlock()
+
+	d.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.RateLimit{})
+}
@@ -0,0 +1,299 @@
+// Package revision 记录电影、人物、类型和合集的修改历史
+package revision
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Estella0129/theater/backend/models"
+	"gorm.io/gorm"
+)
+
+// 实体类型
+const (
+	Movie      = "movie"
+	People     = "people"
+	Genre      = "genre"
+	Collection = "collection"
+)
+
+// 修改类型
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionRollback = "rollback"
+	ActionRestore  = "restore" // 从回收站恢复
+	ActionPurge    = "purge"   // 从回收站永久删除
+)
+
+// ActorAdmin 无法识别具体用户时的管理后台操作者
+const ActorAdmin = "admin"
+
+// ignoredFields 不参与比较的字段，这些字段在每次保存时都会变化
+var ignoredFields = map[string]bool{
+	"ID":         true,
+	"CreatedAt":  true,
+	"UpdatedAt":  true,
+	"DeletedAt":  true,
+	"created_at": true,
+	"updated_at": true,
+	"deleted_at": true,
+	"version":    true,
+	"synced_at":  true,
+}
+
+// FieldChange 单个字段的变化
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// New 根据修改前后的实体生成修改记录，before为nil表示创建，after为nil表示删除，
+// 字段没有变化时返回nil
+func New(actor, entityType string, entityID interface{}, before, after interface{}) (*models.Revision, error) {
+	action := ActionUpdate
+	switch {
+	case isNil(before) && isNil(after):
+		return nil, fmt.Errorf("修改前后的实体不能同时为空")
+	case isNil(before):
+		action = ActionCreate
+	case isNil(after):
+		action = ActionDelete
+	}
+
+	oldFields, err := Fields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := Fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := Diff(oldFields, newFields)
+	if action == ActionUpdate && len(diff) == 0 {
+		return nil, nil
+	}
+
+	snapshot := newFields
+	if action == ActionDelete {
+		snapshot = oldFields
+	}
+
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return nil, err
+	}
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Revision{
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Action:     action,
+		Actor:      actor,
+		Diff:       string(diffJSON),
+		Snapshot:   string(snapshotJSON),
+	}, nil
+}
+
+// Record 生成修改记录并在tx中保存
+func Record(tx *gorm.DB, actor, entityType string, entityID interface{}, before, after interface{}) error {
+	revision, err := New(actor, entityType, entityID, before, after)
+	if err != nil || revision == nil {
+		return err
+	}
+	return tx.Create(revision).Error
+}
+
+// Fields 将实体转换为字段名到值的映射，只保留基本类型字段。
+// 关联数据中只有电影的类型和演职人员计入，见associationFields
+func Fields(entity interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if isNil(entity) {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	nested := nestedFields(entity)
+	for key, value := range all {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		}
+		// 没有加载的关联数据为null
+		if nested[key] {
+			continue
+		}
+		fields[key] = value
+	}
+
+	associations, err := associationFields(entity)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range associations {
+		fields[key] = value
+	}
+	return fields, nil
+}
+
+// nestedFields 实体中关联数据对应的JSON字段名，即切片、map和除时间以外的结构体字段
+func nestedFields(entity interface{}) map[string]bool {
+	nested := map[string]bool{}
+	entityType := reflect.TypeOf(entity)
+	if entityType.Kind() == reflect.Ptr {
+		entityType = entityType.Elem()
+	}
+	if entityType.Kind() != reflect.Struct {
+		return nested
+	}
+	for i := 0; i < entityType.NumField(); i++ {
+		field := entityType.Field(i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		switch fieldType.Kind() {
+		case reflect.Slice, reflect.Map:
+		case reflect.Struct:
+			if fieldType == reflect.TypeOf(time.Time{}) || fieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+				continue
+			}
+		default:
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		nested[name] = true
+	}
+	return nested
+}
+
+// associationKeys 关联数据在修改记录中的字段
+var associationKeys = map[string]bool{
+	"genre_ids": true,
+	"credits":   true,
+}
+
+// creditField 修改记录中的演职人员
+type creditField struct {
+	ID         string `json:"credit_id"`
+	PeopleID   int    `json:"people_id"`
+	CreditType string `json:"credit_type"`
+	Department string `json:"department"`
+	Job        string `json:"job"`
+	Character  string `json:"character"`
+	Order      int    `json:"order"`
+}
+
+// associationFields 电影的类型ID和演职人员，按ID排序。
+// 关联数据为nil表示没有加载，此时不计入，比较时也跳过另一方的对应字段
+func associationFields(entity interface{}) (map[string]interface{}, error) {
+	movie, ok := entity.(*models.Movie)
+	if !ok {
+		if value, isValue := entity.(models.Movie); isValue {
+			movie, ok = &value, true
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	associations := map[string]interface{}{}
+	if movie.Genres != nil {
+		ids := make([]int, 0, len(movie.Genres))
+		for _, genre := range movie.Genres {
+			ids = append(ids, genre.ID)
+		}
+		sort.Ints(ids)
+		associations["genre_ids"] = ids
+	}
+	if movie.Credits != nil {
+		credits := make([]creditField, 0, len(movie.Credits))
+		for _, credit := range movie.Credits {
+			peopleID := credit.PeopleID
+			if peopleID == 0 && credit.People != nil {
+				peopleID = credit.People.ID
+			}
+			credits = append(credits, creditField{
+				ID:         credit.ID,
+				PeopleID:   peopleID,
+				CreditType: credit.CreditType,
+				Department: credit.Department,
+				Job:        credit.Job,
+				Character:  credit.Character,
+				Order:      credit.Order,
+			})
+		}
+		sort.Slice(credits, func(i, j int) bool { return credits[i].ID < credits[j].ID })
+		associations["credits"] = credits
+	}
+
+	// 与其他字段一样转换为JSON中的类型，保证比较时类型一致
+	data, err := json.Marshal(associations)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// Diff 比较两组字段，返回有变化的字段。
+// 两组字段都不为空时，只有一方加载了的关联数据不参与比较
+func Diff(oldFields, newFields map[string]interface{}) map[string]FieldChange {
+	bothLoaded := len(oldFields) > 0 && len(newFields) > 0
+	diff := map[string]FieldChange{}
+	for key, newValue := range newFields {
+		if ignoredFields[key] {
+			continue
+		}
+		oldValue, ok := oldFields[key]
+		if !ok && bothLoaded && associationKeys[key] {
+			continue
+		}
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			diff[key] = FieldChange{Old: oldValue, New: newValue}
+		}
+	}
+	for key, oldValue := range oldFields {
+		if ignoredFields[key] {
+			continue
+		}
+		if _, ok := newFields[key]; !ok && !(bothLoaded && associationKeys[key]) {
+			diff[key] = FieldChange{Old: oldValue, New: nil}
+		}
+	}
+	return diff
+}
+
+// Ignored 字段是否不参与比较
+func Ignored(field string) bool {
+	return ignoredFields[field]
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
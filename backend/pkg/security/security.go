@@ -0,0 +1,140 @@
+// Package security 跨域访问、安全响应头和CSRF检查的中间件
+package security
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// Headers 为每个响应设置安全响应头，Strict-Transport-Security只在HTTPS请求中设置，
+// 在反向代理后由X-Forwarded-Proto判断
+func Headers(cfg config.SecurityHeaders) gin.HandlerFunc {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(cfg.HSTSMaxAge.Seconds()), 10)
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if cfg.ContentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		}
+		if cfg.FrameOptions != "" {
+			h.Set("X-Frame-Options", cfg.FrameOptions)
+		}
+		if cfg.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		}
+		if hsts != "" && (c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// CORS 处理跨域请求和预检请求，只有allowed_origins中的来源得到跨域响应头。
+// 预检请求在这里直接返回204，不需要为每个路由注册OPTIONS
+func CORS(cfg config.CORS) gin.HandlerFunc {
+	origins := newOriginSet(cfg.AllowedOrigins)
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.FormatInt(int64(cfg.MaxAge.Seconds()), 10)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if origins.allows(origin) {
+			if origins.any && !cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if preflight {
+				h.Set("Access-Control-Allow-Methods", methods)
+				h.Set("Access-Control-Allow-Headers", headers)
+				h.Set("Access-Control-Max-Age", maxAge)
+			} else if exposed != "" {
+				h.Set("Access-Control-Expose-Headers", exposed)
+			}
+		}
+		// 不允许的来源也正常结束预检，浏览器因为没有跨域响应头而拒绝
+		if preflight {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// CSRF 拒绝带有Cookie的跨站修改请求。浏览器的Sec-Fetch-Site为same-origin时直接通过，
+// 否则Origin需要与请求的Host相同或在allowed_origins中；没有这两个请求头的不是浏览器发出的请求
+func CSRF(cfg config.CORS) gin.HandlerFunc {
+	origins := newOriginSet(cfg.AllowedOrigins)
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if c.GetHeader("Cookie") == "" {
+			c.Next()
+			return
+		}
+
+		site := c.GetHeader("Sec-Fetch-Site")
+		origin := c.GetHeader("Origin")
+		allowed := site == "same-origin" || site == "none" ||
+			(origin == "" && site == "") ||
+			(origin != "" && (sameHost(origin, c.Request.Host) || origins.allows(origin)))
+		if !allowed {
+			apierror.Respond(c, apierror.Forbidden("Cross-site request rejected", "拒绝跨站请求").With("origin", origin))
+			return
+		}
+		c.Next()
+	}
+}
+
+// originSet 允许的来源，比较时不区分大小写
+type originSet struct {
+	any     bool
+	origins map[string]bool
+}
+
+func newOriginSet(allowed []string) originSet {
+	s := originSet{origins: map[string]bool{}}
+	for _, origin := range allowed {
+		if origin == "*" {
+			s.any = true
+			continue
+		}
+		s.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	return s
+}
+
+func (s originSet) allows(origin string) bool {
+	return s.any || s.origins[strings.ToLower(origin)]
+}
+
+// sameHost Origin的主机和端口是否与请求的Host相同
+func sameHost(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, host)
+}
@@ -0,0 +1,173 @@
+// Package server 按配置运行HTTP服务：监听TCP地址或Unix socket，可选TLS证书或ACME自动证书，
+// 停止时等待处理中的请求和后台任务结束
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/pkg/job"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Run 运行服务直到ctx取消或服务出错。ctx取消后停止接收新连接，
+// 在server.shutdown_timeout内等待处理中的请求和后台任务结束
+func Run(ctx context.Context, handler http.Handler) error {
+	cfg := config.AppConfig.Server
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	// ACME的HTTP-01验证服务，同时将HTTP请求重定向到HTTPS
+	var challenge *http.Server
+	switch {
+	case cfg.TLS.CertFile != "":
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return fmt.Errorf("加载TLS证书失败: %v", err)
+		}
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	case len(cfg.TLS.ACME.Domains) > 0:
+		manager, err := newACMEManager()
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = manager.TLSConfig()
+		srv.TLSConfig.MinVersion = tls.VersionTLS12
+		if cfg.TLS.ACME.HTTPAddr != "" {
+			challenge = &http.Server{
+				Addr:              cfg.TLS.ACME.HTTPAddr,
+				Handler:           manager.HTTPHandler(nil),
+				ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			}
+		}
+	}
+
+	listener, err := listen()
+	if err != nil {
+		return err
+	}
+
+	scheme := "http"
+	if srv.TLSConfig != nil {
+		scheme = "https"
+		listener = tls.NewListener(listener, srv.TLSConfig)
+	}
+	slog.Info("服务已启动", "addr", scheme+"://"+listener.Addr().String())
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- srv.Serve(listener)
+	}()
+	if challenge != nil {
+		go func() {
+			slog.Info("ACME验证服务已启动", "addr", "http://"+challenge.Addr)
+			errs <- challenge.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, http.ErrServerClosed) {
+			// 另一个服务仍在运行，停止后再返回
+			shutdown(srv, challenge)
+			return fmt.Errorf("服务运行失败: %v", err)
+		}
+	case <-ctx.Done():
+	}
+	return shutdown(srv, challenge)
+}
+
+// shutdown 停止接收新请求，等待处理中的请求和后台任务结束
+func shutdown(srv, challenge *http.Server) error {
+	slog.Info("正在停止服务", "timeout", config.AppConfig.Server.ShutdownTimeout)
+	ctx := context.Background()
+	if timeout := config.AppConfig.Server.ShutdownTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var errs []error
+	if challenge != nil {
+		if err := challenge.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("停止ACME验证服务失败: %v", err))
+		}
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("等待处理中的请求结束失败: %v", err))
+	}
+	if err := job.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// listen 监听Unix socket或TCP地址
+func listen() (net.Listener, error) {
+	cfg := config.AppConfig.Server
+	if cfg.Socket == "" {
+		listener, err := net.Listen("tcp", config.GetServerAddr())
+		if err != nil {
+			return nil, fmt.Errorf("监听%s失败: %v", cfg.Addr, err)
+		}
+		return listener, nil
+	}
+
+	// 上次异常退出时留下的socket文件会导致监听失败，只删除socket文件，不删除同名的普通文件
+	if info, err := os.Lstat(cfg.Socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(cfg.Socket); err != nil {
+			return nil, fmt.Errorf("删除旧的socket文件失败: %v", err)
+		}
+	}
+	listener, err := net.Listen("unix", cfg.Socket)
+	if err != nil {
+		return nil, fmt.Errorf("监听%s失败: %v", cfg.Socket, err)
+	}
+	if err := os.Chmod(cfg.Socket, config.GetServerSocketMode()); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("设置socket文件权限失败: %v", err)
+	}
+	return listener, nil
+}
+
+// newACMEManager 创建自动申请和续期证书的autocert.Manager，只为配置的域名申请证书
+func newACMEManager() (*autocert.Manager, error) {
+	cfg := config.AppConfig.Server.TLS.ACME
+	client := &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取ACME根证书失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ACME根证书%s中没有有效的证书", cfg.CAFile)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		client.HTTPClient = &http.Client{Transport: transport}
+	}
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Cache:      autocert.DirCache(cfg.CacheDir),
+		Email:      cfg.Email,
+		Client:     client,
+	}, nil
+}
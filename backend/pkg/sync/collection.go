@@ -0,0 +1,180 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TmdbCollection TMDB API返回的合集详情
+type TmdbCollection struct {
+	ID           int         `json:"id"`
+	Name         string      `json:"name"`
+	Overview     string      `json:"overview"`
+	PosterPath   string      `json:"poster_path"`
+	BackdropPath string      `json:"backdrop_path"`
+	Parts        []TmdbMovie `json:"parts"`
+}
+
+// maxCollectionImages 每种类型同步的合集图片数量
+const maxCollectionImages = 5
+
+// ErrCollectionDeleted 合集在回收站中，不再同步
+var ErrCollectionDeleted = errors.New("合集已删除，不再同步")
+
+// SyncCollection 同步TMDB合集及其包含的电影和图片，返回本地合集ID
+func SyncCollection(tmdbID int) (uint, error) {
+	url := config.GetTMDBAPIURL(fmt.Sprintf("/collection/%d?language=%s", tmdbID, config.GetTMDBLanguage()))
+
+	var data TmdbCollection
+	if err := getTMDB(url, &data); err != nil {
+		return 0, fmt.Errorf("获取合集%d失败: %v", tmdbID, err)
+	}
+
+	var images struct {
+		Backdrops []models.Image `json:"backdrops"`
+		Posters   []models.Image `json:"posters"`
+	}
+	imagesURL := config.GetTMDBAPIURL(fmt.Sprintf("/collection/%d/images", tmdbID))
+	if err := getTMDB(imagesURL, &images); err != nil {
+		// 图片获取失败不影响合集本身的同步
+		logger().Warn("获取合集图片失败", "collection_id", tmdbID, "error", err)
+	}
+
+	var collection models.Collection
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		id := uint(data.ID)
+		if err := tx.Unscoped().Where(models.Collection{TMDBID: &id}).FirstOrInit(&collection).Error; err != nil {
+			return err
+		}
+		if collection.DeletedAt.Valid {
+			return ErrCollectionDeleted
+		}
+		var before *models.Collection
+		if collection.ID != 0 {
+			existing := collection
+			before = &existing
+		}
+		collection.TMDBID = &id
+		collection.Name = data.Name
+		collection.Overview = data.Overview
+		collection.PosterPath = data.PosterPath
+		collection.BackdropPath = data.BackdropPath
+		if before != nil {
+			if err := keepLockedFields(tx, revision.Collection, collection.ID, before, &collection); err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit(clause.Associations).Save(&collection).Error; err != nil {
+			return err
+		}
+		if err := revision.Record(tx, actor, revision.Collection, collection.ID, before, &collection); err != nil {
+			return err
+		}
+
+		// 合集中的电影，本地不存在的先创建基础记录
+		var links []models.CollectionMovie
+		for _, part := range data.Parts {
+			releaseDate, _ := time.Parse("2006-01-02", part.ReleaseDate)
+			movie := models.Movie{
+				ID:               uint(part.ID),
+				Title:            part.Title,
+				OriginalTitle:    part.OriginalTitle,
+				OriginalLanguage: part.OriginalLanguage,
+				Overview:         part.Overview,
+				PosterPath:       part.PosterPath,
+				BackdropPath:     part.BackdropPath,
+				ReleaseDate:      releaseDate,
+				Adult:            part.Adult,
+				Popularity:       part.Popularity,
+				VoteAverage:      part.VoteAverage,
+				VoteCount:        part.VoteCount,
+				Video:            part.Video,
+			}
+			var existing models.Movie
+			isNew := tx.Unscoped().First(&existing, part.ID).Error != nil
+			if existing.DeletedAt.Valid {
+				// 回收站中的电影不再同步，也不加入合集
+				continue
+			}
+			if err := tx.Omit(clause.Associations).FirstOrCreate(&movie).Error; err != nil {
+				return err
+			}
+			if isNew {
+				if err := revision.Record(tx, actor, revision.Movie, movie.ID, nil, &movie); err != nil {
+					return err
+				}
+			}
+			if movie.CollectionID == nil || *movie.CollectionID != collection.ID {
+				before := movie
+				synced := movie
+				synced.CollectionID = &collection.ID
+				if err := keepLockedFields(tx, revision.Movie, movie.ID, &before, &synced); err != nil {
+					return err
+				}
+				if synced.CollectionID == nil || *synced.CollectionID != collection.ID {
+					// 电影所属合集已被锁定，只记录合集包含该电影
+					links = append(links, models.CollectionMovie{CollectionID: collection.ID, MovieID: movie.ID})
+					continue
+				}
+				if err := tx.Model(&movie).Updates(map[string]interface{}{
+					"collection_id": collection.ID,
+					"version":       gorm.Expr("version + 1"),
+				}).Error; err != nil {
+					return err
+				}
+				movie.CollectionID = &collection.ID
+				if err := revision.Record(tx, actor, revision.Movie, movie.ID, &before, &movie); err != nil {
+					return err
+				}
+			}
+			links = append(links, models.CollectionMovie{CollectionID: collection.ID, MovieID: movie.ID})
+		}
+		if len(links) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+				return err
+			}
+		}
+
+		var collectionImages []models.Image
+		for _, list := range []struct {
+			imageType string
+			items     []models.Image
+		}{{"backdrop", images.Backdrops}, {"poster", images.Posters}} {
+			count := 0
+			for _, item := range list.items {
+				if item.Iso6391 != "" && item.Iso6391 != "zh" {
+					continue
+				}
+				if count >= maxCollectionImages {
+					break
+				}
+				item.Type = list.imageType
+				collectionImages = append(collectionImages, item)
+				count++
+			}
+		}
+		if len(collectionImages) == 0 {
+			return nil
+		}
+		if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).Create(&collectionImages).Error; err != nil {
+			return err
+		}
+		imageLinks := make([]models.CollectionImage, 0, len(collectionImages))
+		for _, image := range collectionImages {
+			imageLinks = append(imageLinks, models.CollectionImage{CollectionID: collection.ID, ImageFilePath: image.FilePath})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&imageLinks).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("保存合集%d失败: %v", tmdbID, err)
+	}
+
+	return collection.ID, nil
+}
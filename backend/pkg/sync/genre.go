@@ -8,11 +8,13 @@ import (
 
 	"github.com/Estella0129/theater/backend/config"
 	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/revision"
 )
 
 func Genre() (err error) {
+	defer func() { recordRun(RunGenres, err) }()
 
-	url := "https://api.themoviedb.org/3/genre/movie/list?language=zh"
+	url := config.GetTMDBAPIURL("/genre/movie/list?language=" + config.GetTMDBLanguage())
 
 	req, _ := http.NewRequest("GET", url, nil)
 
@@ -23,7 +25,7 @@ func Genre() (err error) {
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := tmdbClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("HTTP请求失败: %v", err)
 	}
@@ -51,11 +53,23 @@ func Genre() (err error) {
 
 	for _, genre := range response.Genres {
 
+		var existing models.Genre
+		isNew := config.DB.Unscoped().First(&existing, genre.ID).Error != nil
+		if existing.DeletedAt.Valid {
+			// 回收站中的类型不再同步
+			continue
+		}
+
 		result := config.DB.FirstOrCreate(&genre)
 
 		if result.Error != nil {
 			return result.Error
 		}
+		if isNew {
+			if err := revision.Record(config.DB, actor, revision.Genre, genre.ID, nil, &genre); err != nil {
+				return err
+			}
+		}
 	}
 
 	return
@@ -11,9 +11,9 @@ import (
 )
 
 func Images(movieID int) (err error) {
-	url := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d/images", movieID)
+	url := config.GetTMDBAPIURL(fmt.Sprintf("/movie/%d/images", movieID))
 
-	fmt.Println("request: ", url)
+	logger().Debug("请求TMDB", "url", url, "movie_id", movieID)
 
 	req, _ := http.NewRequest("GET", url, nil)
 
@@ -25,7 +25,7 @@ func Images(movieID int) (err error) {
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
 
-	res, _ := http.DefaultClient.Do(req)
+	res, _ := tmdbClient.Do(req)
 
 	if res != nil {
 		defer res.Body.Close()
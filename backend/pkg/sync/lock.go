@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"sync"
+
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/fieldlock"
+	"gorm.io/gorm"
+)
+
+// syncConflicts 本次同步中因字段锁定而跳过的修改数量
+var syncConflicts int
+
+// runMu 同一时间只运行一个同步任务，同步任务共享actor、合集和冲突计数等状态
+var runMu sync.Mutex
+
+// startRun 开始一次同步任务，job为修改记录中的操作者，返回结束时调用的函数
+func startRun(job string) func() {
+	runMu.Lock()
+	actor = job
+	// 本次同步中已处理的合集，TMDB合集ID到本地合集ID
+	syncedCollections = map[int]uint{}
+	syncConflicts = 0
+
+	return func() {
+		if syncConflicts > 0 {
+			logger().Info("同步完成，锁定字段的修改已跳过", "conflicts", syncConflicts)
+		}
+		runMu.Unlock()
+	}
+}
+
+// keepLockedFields 将remote中被锁定的字段恢复为local的值，并记录跳过的冲突
+func keepLockedFields(db *gorm.DB, entityType string, entityID interface{}, local, remote interface{}) error {
+	locked, err := fieldlock.Locked(db, entityType, entityID)
+	if err != nil {
+		return err
+	}
+	conflicts, err := fieldlock.Keep(entityType, entityID, locked, local, remote)
+	if err != nil {
+		return err
+	}
+	return reportConflicts(db, conflicts)
+}
+
+// reportConflicts 输出并保存跳过的冲突
+func reportConflicts(db *gorm.DB, conflicts []models.SyncConflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	syncConflicts += len(conflicts)
+	for _, conflict := range conflicts {
+		logger().Info("字段已锁定，跳过TMDB的修改", "entity_type", conflict.EntityType, "entity_id", conflict.EntityID,
+			"field", conflict.Field, "local", conflict.LocalValue, "remote", conflict.RemoteValue)
+	}
+	return fieldlock.Report(db, actor, conflicts)
+}
@@ -1,7 +1,9 @@
 package sync
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +11,9 @@ import (
 
 	"github.com/Estella0129/theater/backend/config"
 	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TmdbMovie struct {
@@ -28,14 +33,21 @@ type TmdbMovie struct {
 	GenreIDs         []int   `json:"genre_ids"`
 
 	Runtime int `json:"runtime"`
+
+	// 仅电影详情接口返回
+	BelongsToCollection *struct {
+		ID int `json:"id"`
+	} `json:"belongs_to_collection"`
+	Genres []struct {
+		ID int `json:"id"`
+	} `json:"genres"`
 }
 
 // SyncMovies 从TiDB同步电影信息并写入本地数据库
-func SyncMovies() error {
-	// 1. 从TMDB API获取电影数据
-	var err error
+func SyncMovies() (err error) {
+	defer func() { recordRun(RunMovies, err) }()
 
-	fmt.Println(err)
+	// 1. 从TMDB API获取电影数据
 	// 分页获取所有电影数据
 	page := 1
 	totalPages := 1
@@ -46,7 +58,7 @@ func SyncMovies() error {
 		// 添加适当的延迟避免API限流
 		time.Sleep(500 * time.Millisecond)
 
-		url := fmt.Sprintf("https://api.themoviedb.org/3/discover/movie?include_adult=false&include_video=false&language=zh-CN&page=%d&sort_by=popularity.desc", page)
+		url := config.GetTMDBAPIURL(fmt.Sprintf("/discover/movie?include_adult=false&include_video=false&language=%s&page=%d&sort_by=popularity.desc", config.GetTMDBLanguage(), page))
 
 		var req *http.Request
 		req, err = http.NewRequest("GET", url, nil)
@@ -61,17 +73,13 @@ func SyncMovies() error {
 		}
 		req.Header.Add("Authorization", "Bearer "+token)
 
-		fmt.Println("request", url)
-		// 创建自定义HTTP客户端，设置超时
-		client := &http.Client{
-			Timeout: 30 * time.Second,
-		}
+		logger().Debug("请求TMDB", "url", url, "page", page)
 
 		// 添加重试机制
 		maxRetries := 3
 		var res *http.Response
 		for i := 0; i < maxRetries; i++ {
-			res, err = client.Do(req)
+			res, err = tmdbClient.Do(req)
 			if err == nil {
 				break
 			}
@@ -105,104 +113,224 @@ func SyncMovies() error {
 		}
 
 		totalPages = tmdbResponse.TotalPages
-		if totalPages > 10 {
-			totalPages = 10
+		if maxPages := config.GetSyncMaxPages(); totalPages > maxPages {
+			totalPages = maxPages
 		}
 		allResults = append(allResults, tmdbResponse.Results...)
 		page++
 	}
 
+	defer startRun("sync:" + time.Now().Format("20060102150405"))()
+
 	// 使用收集到的所有结果进行处理
 	for _, tmdbMovie := range allResults {
+		err := syncMovie(tmdbMovie, nil)
+		if err == ErrMovieDeleted {
+			logger().Info("电影已删除，跳过同步", "movie_id", tmdbMovie.ID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ErrMovieDeleted 电影在回收站中，不再同步
+var ErrMovieDeleted = errors.New("电影已删除，不再同步")
+
+// syncMovie 同步一部电影及其类型、图片和演职人员，detail为nil且本地没有时长时请求电影详情
+func syncMovie(tmdbMovie TmdbMovie, detail *TmdbMovie) error {
+	logger().Info("同步电影", "movie_id", tmdbMovie.ID, "title", tmdbMovie.Title)
+
+	releaseDate, _ := time.Parse("2006-01-02", tmdbMovie.ReleaseDate)
+
+	movie := models.Movie{
+		ID:               uint(tmdbMovie.ID),
+		Title:            tmdbMovie.Title,
+		OriginalTitle:    tmdbMovie.OriginalTitle,
+		OriginalLanguage: tmdbMovie.OriginalLanguage,
+		Overview:         tmdbMovie.Overview,
+		PosterPath:       tmdbMovie.PosterPath,
+		BackdropPath:     tmdbMovie.BackdropPath,
+		ReleaseDate:      releaseDate,
+		Adult:            tmdbMovie.Adult,
+		Popularity:       tmdbMovie.Popularity,
+		VoteAverage:      tmdbMovie.VoteAverage,
+		VoteCount:        tmdbMovie.VoteCount,
+		Video:            tmdbMovie.Video,
+		//GenreIDs:         tmdbMovie.GenreIDs,
+	}
 
-		fmt.Println("sync movie", tmdbMovie.ID, tmdbMovie.Title)
-
-		releaseDate, _ := time.Parse("2006-01-02", tmdbMovie.ReleaseDate)
-
-		movie := models.Movie{
-			ID:               uint(tmdbMovie.ID),
-			Title:            tmdbMovie.Title,
-			OriginalTitle:    tmdbMovie.OriginalTitle,
-			OriginalLanguage: tmdbMovie.OriginalLanguage,
-			Overview:         tmdbMovie.Overview,
-			PosterPath:       tmdbMovie.PosterPath,
-			BackdropPath:     tmdbMovie.BackdropPath,
-			ReleaseDate:      releaseDate,
-			Adult:            tmdbMovie.Adult,
-			Popularity:       tmdbMovie.Popularity,
-			VoteAverage:      tmdbMovie.VoteAverage,
-			VoteCount:        tmdbMovie.VoteCount,
-			Video:            tmdbMovie.Video,
-			//GenreIDs:         tmdbMovie.GenreIDs,
-		}
-
-		// 3. 使用GORM保存到SQLite
-		result := config.DB.FirstOrCreate(&movie)
-		if movie.Runtime == 0 {
-			movieDetail, _ := GetMovieDetail(tmdbMovie.ID)
-			movie.Runtime = movieDetail.Runtime
-			result = config.DB.Save(&movie)
-		}
-
-		for _, genreID := range tmdbMovie.GenreIDs {
-			// 先检查关联是否已存在
-			var existingRelation models.MovieGenre
-			if error := config.DB.Where("movie_id = ? AND genre_id = ?", tmdbMovie.ID, genreID).First(&existingRelation).Error; error != nil {
-				// 不存在则创建
-				relation := models.MovieGenre{MovieID: uint(tmdbMovie.ID), GenreID: uint(genreID)}
-				if error := config.DB.Create(&relation).Error; err != nil {
-					return fmt.Errorf("创建电影类型关联失败: %v", error)
-				}
+	// 3. 使用GORM保存到SQLite
+	var existing models.Movie
+	isNew := config.DB.Unscoped().First(&existing, tmdbMovie.ID).Error != nil
+	if existing.DeletedAt.Valid {
+		// 回收站中的电影不再同步
+		return ErrMovieDeleted
+	}
+	if isNew {
+		if err := config.DB.Omit(clause.Associations).FirstOrCreate(&movie).Error; err != nil {
+			return err
+		}
+		if err := revision.Record(config.DB, actor, revision.Movie, movie.ID, nil, &movie); err != nil {
+			logger().Error("记录修改历史失败", "movie_id", movie.ID, "error", err)
+		}
+		existing = movie
+	}
+
+	var collectionID *uint
+	runtime := existing.Runtime
+	if runtime == 0 && detail == nil {
+		movieDetail, detailErr := GetMovieDetail(tmdbMovie.ID)
+		if detailErr == nil {
+			detail = movieDetail
+		} else {
+			logger().Warn("获取电影详情失败", "movie_id", tmdbMovie.ID, "error", detailErr)
+		}
+	}
+	if detail != nil {
+		runtime = detail.Runtime
+		if detail.BelongsToCollection != nil {
+			id, err := syncCollectionOnce(detail.BelongsToCollection.ID)
+			if err != nil {
+				logger().Warn("同步合集失败", "movie_id", tmdbMovie.ID, "collection_id", detail.BelongsToCollection.ID, "error", err)
+			} else {
+				collectionID = &id
 			}
 		}
+	}
 
-		if result.Error != nil {
-			return result.Error
+	if err := saveSyncedMovie(movie, runtime, collectionID); err != nil {
+		logger().Error("保存电影失败", "movie_id", tmdbMovie.ID, "error", err)
+	}
+
+	for _, genreID := range tmdbMovie.GenreIDs {
+		// 先检查关联是否已存在
+		var existingRelation models.MovieGenre
+		if error := config.DB.Where("movie_id = ? AND genre_id = ?", tmdbMovie.ID, genreID).First(&existingRelation).Error; error != nil {
+			// 不存在则创建
+			relation := models.MovieGenre{MovieID: uint(tmdbMovie.ID), GenreID: uint(genreID)}
+			if error := config.DB.Create(&relation).Error; error != nil {
+				return fmt.Errorf("创建电影类型关联失败: %v", error)
+			}
 		}
+	}
 
-		_ = Images(tmdbMovie.ID)
+	_ = Images(tmdbMovie.ID)
 
-		_ = SyncPeople(tmdbMovie.ID)
+	_ = SyncPeople(tmdbMovie.ID)
 
-		// 同步电影类型关联关系
-		if len(tmdbMovie.GenreIDs) > 0 {
-			var genres []models.Genre
-			config.DB.Where("id IN ?", tmdbMovie.GenreIDs).Find(&genres)
-			if len(genres) > 0 {
-				err = config.DB.Model(&movie).Association("Genres").Replace(genres)
-				if err != nil {
-					return fmt.Errorf("更新电影类型关联失败: %v", err)
-				}
+	// 同步电影类型关联关系
+	if len(tmdbMovie.GenreIDs) > 0 {
+		var genres []models.Genre
+		config.DB.Where("id IN ?", tmdbMovie.GenreIDs).Find(&genres)
+		if len(genres) > 0 {
+			if err := config.DB.Model(&movie).Association("Genres").Replace(genres); err != nil {
+				return fmt.Errorf("更新电影类型关联失败: %v", err)
 			}
 		}
 	}
+	return nil
+}
 
+// ResyncMovies 从TMDB重新获取指定电影的详情并同步，每部电影处理完成后调用done，
+// ctx取消时不再处理剩余的电影并返回context.Cause(ctx)
+func ResyncMovies(ctx context.Context, job string, movieIDs []int, done func(movieID int, err error)) error {
+	defer startRun(job)()
+
+	for _, movieID := range movieIDs {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		detail, err := GetMovieDetail(movieID)
+		if err == nil {
+			for _, genre := range detail.Genres {
+				detail.GenreIDs = append(detail.GenreIDs, genre.ID)
+			}
+			err = syncMovie(*detail, detail)
+		}
+		done(movieID, err)
+	}
 	return nil
 }
 
-func GetMovieDetail(movieID int) (*TmdbMovie, error) {
-	url := "https://api.themoviedb.org/3/movie/2?language=zh-CN"
+// saveSyncedMovie 用TMDB数据更新已保存的电影，被锁定的字段保留本地的值，
+// runtime为0或collectionID为nil时保持原值
+func saveSyncedMovie(remote models.Movie, runtime int, collectionID *uint) error {
+	// 合集同步可能已修改电影，更新前重新读取
+	var existing models.Movie
+	if err := config.DB.First(&existing, remote.ID).Error; err != nil {
+		return fmt.Errorf("查询电影%d失败: %v", remote.ID, err)
+	}
 
-	req, _ := http.NewRequest("GET", url, nil)
+	movie := existing
+	movie.Title = remote.Title
+	movie.OriginalTitle = remote.OriginalTitle
+	movie.OriginalLanguage = remote.OriginalLanguage
+	movie.Overview = remote.Overview
+	movie.PosterPath = remote.PosterPath
+	movie.BackdropPath = remote.BackdropPath
+	movie.ReleaseDate = remote.ReleaseDate
+	movie.Adult = remote.Adult
+	movie.Popularity = remote.Popularity
+	movie.VoteAverage = remote.VoteAverage
+	movie.VoteCount = remote.VoteCount
+	movie.Video = remote.Video
+	if runtime != 0 {
+		movie.Runtime = runtime
+	}
+	if collectionID != nil {
+		movie.CollectionID = collectionID
+	}
 
-	req.Header.Add("accept", "application/json")
+	if err := keepLockedFields(config.DB, revision.Movie, movie.ID, &existing, &movie); err != nil {
+		return err
+	}
 
-	token, err := config.GetTMDBToken()
-	if err != nil {
-		return nil, fmt.Errorf("获取TMDB Token失败: %v", err)
+	r, err := revision.New(actor, revision.Movie, movie.ID, &existing, &movie)
+	if err != nil || r == nil {
+		return err
 	}
-	req.Header.Add("Authorization", "Bearer "+token)
+	movie.Version = existing.Version + 1
 
-	res, _ := http.DefaultClient.Do(req)
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		// 只有版本号未变时才更新，避免覆盖编辑同时进行的修改
+		result := tx.Model(&movie).Where("version = ?", existing.Version).
+			Select("*").Omit(clause.Associations, "created_at").Updates(&movie)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("电影%d已被修改，跳过本次同步", movie.ID)
+		}
+		return tx.Create(r).Error
+	})
+}
 
-	if res != nil {
-		defer res.Body.Close()
+// syncedCollections 本次同步中已处理的合集，避免同一合集重复请求
+var syncedCollections = map[int]uint{}
+
+// syncCollectionOnce 同步合集，同一次同步中每个合集只请求一次
+func syncCollectionOnce(tmdbID int) (uint, error) {
+	if id, ok := syncedCollections[tmdbID]; ok {
+		return id, nil
 	}
-	body, _ := io.ReadAll(res.Body)
-	var movie TmdbMovie
-	err = json.Unmarshal(body, &movie)
+	id, err := SyncCollection(tmdbID)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	syncedCollections[tmdbID] = id
+	return id, nil
+}
+
+func GetMovieDetail(movieID int) (*TmdbMovie, error) {
+	url := config.GetTMDBAPIURL(fmt.Sprintf("/movie/%d?language=%s", movieID, config.GetTMDBLanguage()))
+
+	var movie TmdbMovie
+	if err := getTMDB(url, &movie); err != nil {
+		return nil, fmt.Errorf("获取电影%d详情失败: %v", movieID, err)
 	}
 	return &movie, nil
 }
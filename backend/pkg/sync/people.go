@@ -1,14 +1,18 @@
 package sync
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Estella0129/theater/backend/config"
 	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/revision"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Cast 结构体对应TMDB API返回的演员数据
@@ -47,212 +51,423 @@ type PeoplesResponse struct {
 	Crew []Crew `json:"crew"`
 }
 
+// SyncPeople 同步电影的演职人员，演职人员记录直接由电影的credits数据生成，
+// 人物详情只请求本地不存在或已过期的人物
 func SyncPeople(movieID int) (err error) {
+	url := config.GetTMDBAPIURL(fmt.Sprintf("/movie/%d/credits?language=%s", movieID, config.GetTMDBLanguage()))
 
-	var body []byte
-
-	url := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d/credits?language=zh-CN", movieID)
-
-	req, _ := http.NewRequest("GET", url, nil)
-
-	req.Header.Add("accept", "application/json")
-	token, err := config.GetTMDBToken()
-	if err != nil {
-		return fmt.Errorf("获取TMDB Token失败: %v", err)
+	var data PeoplesResponse
+	if err = getTMDB(url, &data); err != nil {
+		return err
 	}
-	req.Header.Add("Authorization", "Bearer "+token)
 
-	res, _ := http.DefaultClient.Do(req)
+	maxCast, maxCrew := config.GetSyncCreditLimits()
 
-	if res != nil {
-		if res.StatusCode >= 200 && res.StatusCode < 300 {
-			defer res.Body.Close()
-		} else {
-			// 处理非成功状态码
-			return fmt.Errorf("请求失败，状态码: %d", res.StatusCode)
-		}
-	} else {
-		// 处理请求未成功发送的情况
-		return fmt.Errorf("请求未成功发送")
-	}
-	body, _ = io.ReadAll(res.Body)
+	var credits []models.Credit
+	// 人物基础信息，人物详情获取失败时用于创建人物记录
+	basics := map[int]models.People{}
 
-	var data PeoplesResponse
+	// 职员按部门分别排序，每个部门最多同步maxCrew个
+	departmentOrder := map[string]int{}
+	for _, item := range data.Crew {
+		if maxCrew >= 0 && departmentOrder[item.Department] >= maxCrew {
+			continue
+		}
 
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		return fmt.Errorf("解析JSON失败: %v", err)
+		credits = append(credits, models.Credit{
+			ID:         item.CreditID,
+			CreditType: "crew",
+			Department: item.Department,
+			Job:        item.Job,
+			Order:      departmentOrder[item.Department],
+			MovieID:    movieID,
+			PeopleID:   item.ID,
+		})
+		departmentOrder[item.Department]++
+
+		basics[item.ID] = basicPeople(item.ID, item.Name, item.OriginalName, item.Gender, item.Adult,
+			item.KnownForDepartment, item.Popularity, item.ProfilePath)
 	}
 
-	for index, item := range data.Crew {
-
-		err = syncCredit(movieID, item.CreditID, index)
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		if index > 10 {
+	for index, item := range data.Cast {
+		if maxCast >= 0 && index >= maxCast {
 			break
 		}
-	}
 
-	for index, item := range data.Cast {
+		credits = append(credits, models.Credit{
+			ID:         item.CreditID,
+			CreditType: "cast",
+			Department: "Acting",
+			Job:        "Actor",
+			Character:  item.Character,
+			CastID:     item.CastID,
+			Order:      item.Order,
+			MovieID:    movieID,
+			PeopleID:   item.ID,
+		})
+
+		basics[item.ID] = basicPeople(item.ID, item.Name, item.OriginalName, item.Gender, item.Adult,
+			item.KnownForDepartment, item.Popularity, item.ProfilePath)
+	}
 
-		err = syncCredit(movieID, item.CreditID, index)
-		if err != nil {
-			fmt.Println(err)
-		}
+	if len(credits) == 0 {
+		return nil
+	}
 
-		if index > 10 {
-			break
-		}
+	if err = syncPeoples(basics); err != nil {
+		return err
 	}
 
-	return
+	ids := make([]string, len(credits))
+	for i, credit := range credits {
+		ids[i] = credit.ID
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		// 已存在的演职人员记录更新为最新数据
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "credit_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"credit_type", "department", "job", "character", "cast_id", "order", "movie_id", "people_id"}),
+		}).CreateInBatches(&credits, syncBatchSize).Error; err != nil {
+			return err
+		}
+		// 删除TMDB中已不存在或超出数量上限的演职人员
+		return tx.Where("movie_id = ? AND credit_id NOT IN ?", movieID, ids).Delete(&models.Credit{}).Error
+	})
 }
 
-type CreditResponse struct {
-	CreditType string `json:"credit_type"`
-	Department string `json:"department"`
-	Job        string `json:"job"`
-	Media      struct {
-		BackdropPath     string  `json:"backdrop_path"`
-		ID               int     `json:"id"`
-		Title            string  `json:"title"`
-		OriginalTitle    string  `json:"original_title"`
-		Overview         string  `json:"overview"`
-		PosterPath       string  `json:"poster_path"`
-		MediaType        string  `json:"media_type"`
-		Adult            bool    `json:"adult"`
-		OriginalLanguage string  `json:"original_language"`
-		GenreIds         []int   `json:"genre_ids"`
-		Popularity       float64 `json:"popularity"`
-		ReleaseDate      string  `json:"release_date"`
-		Video            bool    `json:"video"`
-		VoteAverage      float64 `json:"vote_average"`
-		VoteCount        int     `json:"vote_count"`
-		Character        string  `json:"character"`
-	} `json:"media"`
-	MediaType string `json:"media_type"`
-	ID        string `json:"id"`
-	Person    struct {
-		ID                 int         `json:"id"`
-		Name               string      `json:"name"`
-		OriginalName       string      `json:"original_name"`
-		MediaType          string      `json:"media_type"`
-		Adult              bool        `json:"adult"`
-		Popularity         float64     `json:"popularity"`
-		Gender             int         `json:"gender"`
-		KnownForDepartment string      `json:"known_for_department"`
-		ProfilePath        interface{} `json:"profile_path"`
-	} `json:"Person"`
+// syncBatchSize 批量写入数据库时每批的记录数
+const syncBatchSize = 100
+
+// peopleWorkers 并发请求人物详情的数量
+const peopleWorkers = 8
+
+// TmdbPerson TMDB API返回的人物详情
+type TmdbPerson struct {
+	ID                 int      `json:"id"`
+	Name               string   `json:"name"`
+	Gender             int      `json:"gender"`
+	Adult              bool     `json:"adult"`
+	KnownForDepartment string   `json:"known_for_department"`
+	Popularity         float64  `json:"popularity"`
+	ProfilePath        *string  `json:"profile_path"`
+	AlsoKnownAs        []string `json:"also_known_as"`
+	Biography          string   `json:"biography"`
+	Birthday           *string  `json:"birthday"`
+	Deathday           *string  `json:"deathday"`
+	Homepage           *string  `json:"homepage"`
+	PlaceOfBirth       *string  `json:"place_of_birth"`
+
+	// 通过append_to_response一并返回
+	Images struct {
+		Profiles []models.Image `json:"profiles"`
+	} `json:"images"`
+	ExternalIDs struct {
+		IMDBID      *string `json:"imdb_id"`
+		WikidataID  *string `json:"wikidata_id"`
+		InstagramID *string `json:"instagram_id"`
+		TwitterID   *string `json:"twitter_id"`
+	} `json:"external_ids"`
 }
 
-func syncCredit(movieID int, id string, index int) (err error) {
-	var dbCredit models.Credit
-	config.DB.Where("credit_id = ?", id).First(&dbCredit)
+// maxPeopleImages 每个人物同步的图片数量
+const maxPeopleImages = 10
+
+// basicPeople 由电影credits中的人物信息生成人物记录
+func basicPeople(id int, name, originalName string, gender int, adult bool, department string, popularity float64, profilePath *string) models.People {
+	return models.People{
+		ID:                 id,
+		Name:               name,
+		OriginalName:       originalName,
+		Gender:             gender,
+		Adult:              adult,
+		KnownForDepartment: department,
+		Popularity:         popularity,
+		ProfilePath:        stringValue(profilePath),
+	}
+}
 
-	if dbCredit.ID != "" {
-		return
+// syncPeoples 为本地不存在或已过期的人物批量请求详情并写入数据库
+func syncPeoples(basics map[int]models.People) error {
+	ids := make([]int, 0, len(basics))
+	for id := range basics {
+		ids = append(ids, id)
 	}
-	fmt.Println(fmt.Printf("syncCredit: %s\n", id))
 
-	var response *CreditResponse
-	response, err = getCreditResponse(id)
-	if err != nil {
-		return
+	var existing []models.People
+	if err := config.DB.Unscoped().Select("id", "synced_at", "deleted_at").Where("id IN ?", ids).Find(&existing).Error; err != nil {
+		return fmt.Errorf("查询人员失败: %v", err)
 	}
 
-	err = syncPeople(response.Person.ID)
-	if err != nil {
-		return
+	staleBefore := time.Now().Add(-config.GetPeopleRefreshInterval())
+	exists := map[int]bool{}
+	fresh := map[int]bool{}
+	for _, p := range existing {
+		exists[p.ID] = true
+		// 回收站中的人物不再同步
+		if p.DeletedAt.Valid || p.SyncedAt != nil && p.SyncedAt.After(staleBefore) {
+			fresh[p.ID] = true
+		}
 	}
 
-	dbCredit = models.Credit{
-		ID:         response.ID,
-		MovieID:    movieID,
-		CreditType: response.CreditType,
-		Department: response.Department,
-		Job:        response.Job,
-		PeopleID:   response.Person.ID,
-		Order:      index,
+	var pending []int
+	for _, id := range ids {
+		if !fresh[id] {
+			pending = append(pending, id)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
 	}
 
-	err = config.DB.Create(&dbCredit).Error
-	return
-}
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		peoples     []models.People
+		workerLimit = make(chan struct{}, peopleWorkers)
+	)
+	for _, id := range pending {
+		wg.Add(1)
+		workerLimit <- struct{}{}
+
+		go func(id int) {
+			defer func() {
+				<-workerLimit
+				wg.Done()
+			}()
+
+			people := basics[id]
+			if err := getPeopleDetail(&people); err != nil {
+				logger().Warn("获取人物详情失败", "people_id", id, "error", err)
+				// 已存在的人物保留原有数据；新人物先使用基础信息，下次同步时重新请求
+				if exists[id] {
+					return
+				}
+			}
+
+			mu.Lock()
+			peoples = append(peoples, people)
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
 
-func syncPeople(id int) (err error) {
+	if len(peoples) == 0 {
+		return nil
+	}
+	logger().Info("同步人物详情", "requested", len(pending), "total", len(ids))
 
-	var People models.People
-	if error := config.DB.Where("id = ?", id).First(&People).Error; error != nil {
-		if !strings.Contains(error.Error(), "record not found") {
-			return fmt.Errorf("查询人员失败: %v", error)
+	// 新人物批量插入，已存在的人物逐个按版本号更新，避免覆盖编辑同时进行的修改
+	var created, updated []models.People
+	for _, people := range peoples {
+		if exists[people.ID] {
+			updated = append(updated, people)
+		} else {
+			created = append(created, people)
 		}
-		// 记录不存在，继续创建
 	}
-	fmt.Println(fmt.Printf("syncPeople: %d\n", id))
 
-	url := fmt.Sprintf("https://api.themoviedb.org/3/person/%d?language=zh-CN", id)
+	if len(created) > 0 {
+		// 同时运行的同步可能已经插入了该人物，此时保留已有的数据
+		if err := config.DB.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).
+			CreateInBatches(&created, syncBatchSize).Error; err != nil {
+			return err
+		}
+		revisions := make([]*models.Revision, 0, len(created))
+		for i := range created {
+			r, err := revision.New(actor, revision.People, created[i].ID, nil, &created[i])
+			if err != nil {
+				return err
+			}
+			if r != nil {
+				revisions = append(revisions, r)
+			}
+		}
+		if len(revisions) > 0 {
+			if err := config.DB.CreateInBatches(revisions, syncBatchSize).Error; err != nil {
+				return err
+			}
+		}
+	}
 
-	req, _ := http.NewRequest("GET", url, nil)
+	saved := created
+	for i := range updated {
+		if err := saveSyncedPeople(&updated[i]); err != nil {
+			if errors.Is(err, ErrPeopleDeleted) || errors.Is(err, errPeopleModified) {
+				logger().Warn("跳过人物同步", "people_id", updated[i].ID, "error", err)
+				continue
+			}
+			return err
+		}
+		saved = append(saved, updated[i])
+	}
 
-	req.Header.Add("accept", "application/json")
+	return syncPeopleImages(saved)
+}
 
-	token, err := config.GetTMDBToken()
-	if err != nil {
-		return fmt.Errorf("获取TMDB Token失败: %v", err)
-	}
-	req.Header.Add("Authorization", "Bearer "+token)
+// ErrPeopleDeleted 人物在回收站中，不再同步
+var ErrPeopleDeleted = errors.New("人物已删除，不再同步")
 
-	res, _ := http.DefaultClient.Do(req)
+// ResyncPeople 从TMDB重新获取指定人物的详情并同步，不考虑上次同步的时间，每个人物处理完成后调用done，
+// ctx取消时不再处理剩余的人物并返回context.Cause(ctx)
+func ResyncPeople(ctx context.Context, job string, peopleIDs []int, done func(peopleID int, err error)) error {
+	defer startRun(job)()
 
-	if res != nil {
-		if res.StatusCode >= 200 && res.StatusCode < 300 {
-			defer res.Body.Close()
-		} else {
-			// 处理非成功状态码
-			return fmt.Errorf("请求失败，状态码: %d", res.StatusCode)
+	for _, peopleID := range peopleIDs {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
 		}
-	} else {
-		// 处理请求未成功发送的情况
-		return fmt.Errorf("请求未成功发送")
+		done(peopleID, resyncPeople(peopleID))
 	}
-	body, _ := io.ReadAll(res.Body)
-
-	err = json.Unmarshal(body, &People)
+	return nil
+}
 
-	err = config.DB.Create(&People).Error
+// resyncPeople 用TMDB详情更新一个已保存的人物，被锁定的字段保留本地的值；人物不存在时返回的错误包含gorm.ErrRecordNotFound
+func resyncPeople(peopleID int) error {
+	var existing models.People
+	if err := config.DB.Unscoped().First(&existing, peopleID).Error; err != nil {
+		return fmt.Errorf("查询人物%d失败: %w", peopleID, err)
+	}
+	if existing.DeletedAt.Valid {
+		return ErrPeopleDeleted
+	}
 
-	return
+	people := existing
+	if err := getPeopleDetail(&people); err != nil {
+		return err
+	}
+	if err := saveSyncedPeople(&people); err != nil {
+		return err
+	}
+	return syncPeopleImages([]models.People{people})
 }
 
-func getCreditResponse(id string) (response *CreditResponse, err error) {
+// peopleSyncColumns 同步已存在的人物时更新的字段，本地独有的字段保持不变
+var peopleSyncColumns = []string{
+	"name", "original_name", "gender", "adult", "known_for_department", "popularity", "profile_path",
+	"also_known_as", "biography", "birthday", "deathday", "homepage", "place_of_birth",
+	"imdb_id", "wikidata_id", "instagram_id", "twitter_id", "synced_at", "version",
+}
 
-	url := fmt.Sprintf("https://api.themoviedb.org/3/credit/%s", id)
+// errPeopleModified 同步期间人物被编辑，跳过本次同步
+var errPeopleModified = errors.New("人物已被修改，跳过本次同步")
 
-	req, _ := http.NewRequest("GET", url, nil)
+// saveSyncedPeople 用同步得到的数据更新已保存的人物。在事务中重新读取人物和锁定字段，
+// 被锁定的字段保留本地的值；只有版本号未变时才更新，避免覆盖编辑同时进行的修改
+func saveSyncedPeople(people *models.People) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var existing models.People
+		if err := tx.Unscoped().First(&existing, people.ID).Error; err != nil {
+			return fmt.Errorf("查询人物%d失败: %w", people.ID, err)
+		}
+		if existing.DeletedAt.Valid {
+			return ErrPeopleDeleted
+		}
+		if err := keepLockedFields(tx, revision.People, people.ID, &existing, people); err != nil {
+			return err
+		}
 
-	req.Header.Add("accept", "application/json")
+		r, err := revision.New(actor, revision.People, people.ID, &existing, people)
+		if err != nil {
+			return err
+		}
+		people.Version = existing.Version + 1
+		result := tx.Model(people).Where("version = ?", existing.Version).
+			Select(peopleSyncColumns).Updates(people)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("人物%d: %w", people.ID, errPeopleModified)
+		}
+		if r == nil {
+			return nil
+		}
+		return tx.Create(r).Error
+	})
+}
 
-	token, err := config.GetTMDBToken()
-	if err != nil {
-		return nil, fmt.Errorf("获取TMDB Token失败: %v", err)
+// syncPeopleImages 用最新获取的图片替换人物的图片关联，未获取到详情的人物保持不变
+func syncPeopleImages(peoples []models.People) error {
+	var (
+		synced []int
+		images []models.Image
+		links  []models.PeopleImage
+		seen   = map[string]bool{}
+	)
+	for _, people := range peoples {
+		if people.SyncedAt == nil {
+			continue
+		}
+		synced = append(synced, people.ID)
+		for _, image := range people.Images {
+			if !seen[image.FilePath] {
+				seen[image.FilePath] = true
+				images = append(images, image)
+			}
+			links = append(links, models.PeopleImage{PeopleID: people.ID, ImageFilePath: image.FilePath})
+		}
+	}
+	if len(synced) == 0 {
+		return nil
 	}
-	req.Header.Add("Authorization", "Bearer "+token)
 
-	res, _ := http.DefaultClient.Do(req)
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("people_id IN ?", synced).Delete(&models.PeopleImage{}).Error; err != nil {
+			return err
+		}
+		if len(images) == 0 {
+			return nil
+		}
+		if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).
+			CreateInBatches(&images, syncBatchSize).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&links, syncBatchSize).Error
+	})
+}
+
+// getPeopleDetail 从TMDB获取人物详情并填充到people中
+func getPeopleDetail(people *models.People) error {
+	url := config.GetTMDBAPIURL(fmt.Sprintf("/person/%d?language=%s&append_to_response=images,external_ids", people.ID, config.GetTMDBLanguage()))
 
-	if res != nil {
-		defer res.Body.Close()
+	var person TmdbPerson
+	if err := getTMDB(url, &person); err != nil {
+		return fmt.Errorf("获取人物%d详情失败: %v", people.ID, err)
 	}
-	body, _ := io.ReadAll(res.Body)
 
-	response = &CreditResponse{}
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return nil, err
+	syncedAt := time.Now()
+	people.Name = person.Name
+	people.Gender = person.Gender
+	people.Adult = person.Adult
+	people.KnownForDepartment = person.KnownForDepartment
+	people.Popularity = person.Popularity
+	people.ProfilePath = stringValue(person.ProfilePath)
+	people.AlsoKnownAs = strings.Join(person.AlsoKnownAs, ",")
+	people.Biography = person.Biography
+	people.Birthday = stringValue(person.Birthday)
+	people.Deathday = stringValue(person.Deathday)
+	people.Homepage = stringValue(person.Homepage)
+	people.PlaceOfBirth = stringValue(person.PlaceOfBirth)
+	people.IMDBID = stringValue(person.ExternalIDs.IMDBID)
+	people.WikidataID = stringValue(person.ExternalIDs.WikidataID)
+	people.InstagramID = stringValue(person.ExternalIDs.InstagramID)
+	people.TwitterID = stringValue(person.ExternalIDs.TwitterID)
+	people.SyncedAt = &syncedAt
+
+	people.Images = nil
+	for index, item := range person.Images.Profiles {
+		if index >= maxPeopleImages {
+			break
+		}
+		item.Type = "profile"
+		people.Images = append(people.Images, item)
 	}
+	return nil
+}
 
-	return
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
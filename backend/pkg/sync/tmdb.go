@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Estella0129/theater/backend/config"
+	"github.com/Estella0129/theater/backend/models"
+	"github.com/Estella0129/theater/backend/pkg/metrics"
+	"gorm.io/gorm/clause"
+)
+
+// actor 同步任务在修改记录中的操作者，每次同步开始时更新
+var actor = "sync"
+
+// logger 带有当前同步任务ID的日志
+func logger() *slog.Logger {
+	return slog.With("job", actor)
+}
+
+// 同步任务的类型，记录在sync_runs表中
+const (
+	RunMovies = "movies"
+	RunGenres = "genres"
+)
+
+// tmdbClient 访问TMDB API使用的HTTP客户端
+var tmdbClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: tmdbTransport{http.DefaultTransport},
+}
+
+// numericSegment 路径中的数字ID
+var numericSegment = regexp.MustCompile(`/\d+(/|$)`)
+
+// tmdbTransport 统计TMDB请求次数、错误和限流
+type tmdbTransport struct {
+	base http.RoundTripper
+}
+
+func (t tmdbTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := tmdbEndpoint(req.URL.Path)
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		metrics.TMDBRequests.WithLabelValues(endpoint, "error").Inc()
+		metrics.TMDBErrors.WithLabelValues(endpoint).Inc()
+		return nil, err
+	}
+	metrics.TMDBRequests.WithLabelValues(endpoint, strconv.Itoa(res.StatusCode)).Inc()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		metrics.TMDBErrors.WithLabelValues(endpoint).Inc()
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		metrics.TMDBRateLimited.WithLabelValues(endpoint).Inc()
+	}
+	return res, nil
+}
+
+// tmdbEndpoint 去掉API地址中的路径前缀(如/3)，并将数字ID替换为:id，用作指标标签
+func tmdbEndpoint(path string) string {
+	if base, err := url.Parse(config.GetTMDBAPIURL("")); err == nil {
+		path = strings.TrimPrefix(path, strings.TrimRight(base.Path, "/"))
+	}
+	return numericSegment.ReplaceAllString(path, "/:id$1")
+}
+
+// recordRun 记录同步任务的结果，用于监控最近一次成功同步的时间
+func recordRun(runType string, runErr error) {
+	now := time.Now()
+	run := models.SyncRun{Type: runType}
+	columns := []string{"updated_at"}
+	if runErr == nil {
+		run.LastSuccessAt = &now
+		columns = append(columns, "last_success_at")
+	} else {
+		run.LastFailureAt = &now
+		run.LastError = runErr.Error()
+		columns = append(columns, "last_failure_at", "last_error")
+	}
+	err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "type"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&run).Error
+	if err != nil {
+		logger().Error("记录同步结果失败", "type", runType, "error", err)
+	}
+}
+
+// getTMDB 请求TMDB API并将JSON响应解析到out中
+func getTMDB(url string, out interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	req.Header.Add("accept", "application/json")
+	token, err := config.GetTMDBToken()
+	if err != nil {
+		return fmt.Errorf("获取TMDB Token失败: %v", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	res, err := tmdbClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP请求失败: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("请求失败，状态码: %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应体失败: %v", err)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("解析JSON失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build embedfrontend
+
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist 编译前从frontend/dist复制的前端页面
+//
+//go:embed all:dist
+var dist embed.FS
+
+func init() {
+	Embedded, _ = fs.Sub(dist, "dist")
+}
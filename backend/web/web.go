@@ -0,0 +1,179 @@
+// Package web 提供编译好的前端页面。页面可以在编译时嵌入(-tags embedfrontend，需要先将
+// frontend/dist复制到web/dist，见仓库根目录的make build)，也可以由frontend.dir配置的目录提供，修改页面后不需要重新编译
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/Estella0129/theater/backend/pkg/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// Embedded 编译时嵌入的前端页面，没有使用embedfrontend编译时为nil
+var Embedded fs.FS
+
+// indexFile 前端路由的页面，未知的页面路径都返回该文件
+const indexFile = "index.html"
+
+// assetsDir Vite构建的带哈希的文件所在目录，内容变化时文件名也变化，可以长期缓存
+const assetsDir = "assets/"
+
+// 缓存时间：index.html每次都向服务端确认，带哈希的文件缓存一年，其他文件如favicon缓存一小时
+const (
+	cacheIndex  = "no-cache"
+	cacheAssets = "public, max-age=31536000, immutable"
+	cacheOther  = "public, max-age=3600"
+)
+
+// 服务端的路径，不存在时返回JSON的404而不是页面。reservedPrefixes按前缀匹配，
+// reservedPaths只匹配完整路径，如/metrics-dashboard仍由前端路由处理
+var (
+	reservedPrefixes = []string{"api/", "images/"}
+	reservedPaths    = map[string]bool{"api": true, "images": true, "metrics": true, "healthz": true, "readyz": true}
+)
+
+// encodings 支持的预压缩文件，按优先顺序，如app.js.br、app.js.gz
+var encodings = []struct{ name, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// reserved name（不带开头的/）是否为服务端的路径
+func reserved(name string) bool {
+	if reservedPaths[name] {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FS 返回前端页面的文件：dir不为空时使用该目录，否则使用嵌入的页面，都没有时返回nil
+func FS(dir string) (fs.FS, error) {
+	if dir == "" {
+		return Embedded, nil
+	}
+	fsys := os.DirFS(dir)
+	if _, err := fs.Stat(fsys, indexFile); err != nil {
+		return nil, fmt.Errorf("前端目录%s中没有%s: %v", dir, indexFile, err)
+	}
+	return fsys, nil
+}
+
+// Handler 作为路由的NoRoute提供前端页面。存在的文件直接返回，浏览器支持时优先返回预压缩的文件；
+// 没有扩展名的未知路径返回index.html，由前端路由处理
+func Handler(fsys fs.FS) gin.HandlerFunc {
+	etags := &sync.Map{}
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			NotFound(c)
+			return
+		}
+		name := strings.TrimPrefix(path.Clean("/"+c.Request.URL.Path), "/")
+		if reserved(name) {
+			NotFound(c)
+			return
+		}
+		if name == "" {
+			name = indexFile
+		}
+
+		if serveFile(c, fsys, name, etags) {
+			return
+		}
+		// 缺少的js、css等文件返回404，避免浏览器把页面当作脚本执行
+		if path.Ext(name) != "" {
+			NotFound(c)
+			return
+		}
+		if !serveFile(c, fsys, indexFile, etags) {
+			NotFound(c)
+		}
+	}
+}
+
+// NotFound 未匹配任何路由时的响应
+func NotFound(c *gin.Context) {
+	apierror.Respond(c, apierror.NotFound("route"))
+}
+
+// serveFile 返回文件，文件不存在时返回false
+func serveFile(c *gin.Context, fsys fs.FS, name string, etags *sync.Map) bool {
+	info, err := fs.Stat(fsys, name)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	served, encoding := name, ""
+	accepted := c.GetHeader("Accept-Encoding")
+	for _, e := range encodings {
+		if !acceptsEncoding(accepted, e.name) {
+			continue
+		}
+		if compressed, err := fs.Stat(fsys, name+e.ext); err == nil && !compressed.IsDir() {
+			served, encoding, info = name+e.ext, e.name, compressed
+			break
+		}
+	}
+	data, err := fs.ReadFile(fsys, served)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			apierror.Respond(c, apierror.Internal(err, "Failed to read file", "读取文件失败"))
+			return true
+		}
+		return false
+	}
+
+	h := c.Writer.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
+	switch {
+	case name == indexFile:
+		h.Set("Cache-Control", cacheIndex)
+	case strings.HasPrefix(name, assetsDir):
+		h.Set("Cache-Control", cacheAssets)
+	default:
+		h.Set("Cache-Control", cacheOther)
+	}
+	// 嵌入的文件没有修改时间，使用内容的哈希作为ETag
+	if info.ModTime().IsZero() {
+		etag, ok := etags.Load(served)
+		if !ok {
+			sum := sha256.Sum256(data)
+			etag, _ = etags.LoadOrStore(served, `"`+hex.EncodeToString(sum[:8])+`"`)
+		}
+		h.Set("ETag", etag.(string))
+	}
+	// 使用原文件名判断Content-Type
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), bytes.NewReader(data))
+	c.Abort()
+	return true
+}
+
+// acceptsEncoding Accept-Encoding中是否包含编码且q不为0
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package web
+
+import "testing"
+
+func TestReserved(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"api", true},
+		{"api/v1/movies", true},
+		{"images/poster.jpg", true},
+		{"metrics", true},
+		{"healthz", true},
+		{"readyz", true},
+		{"", false},
+		{"apis", false},
+		{"metrics-dashboard", false},
+		{"healthz/details", false},
+		{"readyzone", false},
+		{"movies/1", false},
+	}
+	for _, tt := range tests {
+		if got := reserved(tt.name); got != tt.want {
+			t.Errorf("reserved(%q) = %v，应为%v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,6 @@
 import { ref } from 'vue'
 import { defineStore } from 'pinia'
+import { ifMatch } from '../utils/etag'
 
 export const useGenreStore = defineStore('genre', () => {
   const genres = ref([])
@@ -53,7 +54,8 @@ export const useGenreStore = defineStore('genre', () => {
       const response = await fetch(`/api/v1/admin/genres/${genre.id}`, {
         method: 'PUT',
         headers: {
-          'Content-Type': 'application/json'
+          'Content-Type': 'application/json',
+          'If-Match': await ifMatch(genre.version, `/api/v1/admin/genres/${genre.id}`)
         },
         body: JSON.stringify(jsonData)
       })
@@ -65,10 +67,13 @@ export const useGenreStore = defineStore('genre', () => {
     }
   }
 
-  const deleteGenre = async (id) => {
+  const deleteGenre = async (id, version) => {
     try {
       const response = await fetch(`/api/v1/admin/genres/${id}`, {
-        method: 'DELETE'
+        method: 'DELETE',
+        headers: {
+          'If-Match': await ifMatch(version, `/api/v1/admin/genres/${id}`)
+        }
       })
       return response.ok
     } catch (error) {
@@ -1,5 +1,6 @@
 import { defineStore } from 'pinia'
 import { ref } from 'vue'
+import { ifMatch } from '../utils/etag'
 
 export const useMovieStore = defineStore('movie', () => {
   const movies = ref([])
@@ -88,7 +89,8 @@ export const useMovieStore = defineStore('movie', () => {
       const response = await fetch(`/api/v1/admin/movies/${movieData.id}`, {
         method: 'PUT',
         headers: {
-          'Content-Type': 'application/json'
+          'Content-Type': 'application/json',
+          'If-Match': await ifMatch(movieData.version, `/api/v1/admin/movies/${movieData.id}`)
         },
         body: JSON.stringify(movieData)
       })
@@ -123,13 +125,13 @@ export const useMovieStore = defineStore('movie', () => {
       const response = await fetch(`/api/v1/frontend/movies/${id}`)
       const data = await response.json()
 
-      // 确保Credits数组存在
-      if (!data.Credits) {
-        data.Credits = []
-      }
+      // 确保演员和职员数组存在
+      data.castList = data.cast || []
+      data.crew = data.crew || []
 
-      const director = data.Credits.find(c => c.credit_type == "crew" && c.order == 0)
-      const cast = data.Credits.find(c => c.credit_type == "cast" && c.order == 0)
+      const directing = data.crew.find(d => d.department == "Directing")
+      const director = directing && directing.crew.find(c => c.job == "Director")
+      const cast = data.castList[0]
 
       data.director = director && director.People ? director.People.name : "暂无导演信息"
       data.cast = cast && cast.People ? cast.People.name : "暂无主演信息"
@@ -1,5 +1,6 @@
 import { defineStore } from 'pinia';
 import { ref } from 'vue';
+import { ifMatch } from '../utils/etag';
 
 export const usePeopleStore = defineStore('people', () => {
     const Peoples = ref([]);
@@ -46,6 +47,7 @@ export const usePeopleStore = defineStore('people', () => {
                 method: 'PUT',
                 headers: {
                     'Content-Type': 'application/json',
+                    'If-Match': await ifMatch(peopleData.version, `/api/v1/admin/people/${id}`),
                 },
                 body: JSON.stringify(peopleData)
             });
@@ -60,10 +62,13 @@ export const usePeopleStore = defineStore('people', () => {
         }
     }
 
-    async function deletePeople(id) {
+    async function deletePeople(id, version) {
         try {
             const response = await fetch(`/api/v1/admin/people/${id}`, {
                 method: 'DELETE',
+                headers: {
+                    'If-Match': await ifMatch(version, `/api/v1/admin/people/${id}`),
+                },
             });
             if (!response.ok) {
                 throw new Error(`HTTP error! status: ${response.status}`);
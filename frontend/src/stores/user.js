@@ -1,5 +1,6 @@
 import { defineStore } from 'pinia'
 import axios from 'axios'
+import { ifMatch } from '../utils/etag'
 
 export const useUserStore = defineStore('user', {
   state: () => {
@@ -26,7 +27,9 @@ export const useUserStore = defineStore('user', {
   actions: {
     async UpdatePassword(passwordData) {
       try {
-        const response = await axios.patch(`/api/v1/admin/users/${this.user.id}/update_password`, passwordData)
+        const response = await axios.patch(`/api/v1/admin/users/${this.user.id}/update_password`, passwordData, {
+          headers: { 'If-Match': await ifMatch(this.user.version, `/api/v1/admin/users/${this.user.id}`) }
+        })
         return response.data
       } catch (error) {
         throw new Error(error.response?.data?.error || '密码修改失败')
@@ -42,6 +45,16 @@ export const useUserStore = defineStore('user', {
       }
     },
 
+    // 管理员创建用户，可以指定角色
+    async createUser(userData) {
+      try {
+        const response = await axios.post('/api/v1/admin/users', userData)
+        return response.data
+      } catch (error) {
+        throw new Error(error.response?.data?.error || '创建用户失败')
+      }
+    },
+
     async login(credentials) {
       try {
         const response = await axios.post('/api/v1/frontend/users/login', credentials)
@@ -79,7 +92,9 @@ export const useUserStore = defineStore('user', {
 
     async updateUser(userId, userData) {
       try {
-        const response = await axios.put(`/api/v1/admin/users/${userId}`, userData)
+        const response = await axios.put(`/api/v1/admin/users/${userId}`, userData, {
+          headers: { 'If-Match': await ifMatch(userData.version, `/api/v1/admin/users/${userId}`) }
+        })
         return response.data
       } catch (error) {
         throw new Error(error.response?.data?.error || '更新用户信息失败')
@@ -106,18 +121,22 @@ export const useUserStore = defineStore('user', {
       }
     },
 
-    async deleteUser(userId) {
+    async deleteUser(userId, version) {
       try {
-        const response = await axios.delete(`/api/v1/admin/users/${userId}`)
+        const response = await axios.delete(`/api/v1/admin/users/${userId}`, {
+          headers: { 'If-Match': await ifMatch(version, `/api/v1/admin/users/${userId}`) }
+        })
         return response.data
       } catch (error) {
         throw new Error(error.response?.data?.error || '删除用户失败')
       }
     },
     
-    async toggleFreezeUser(userId) {
+    async toggleFreezeUser(userId, version) {
       try {
-        const response = await axios.patch(`/api/v1/admin/users/${userId}/toggle-freeze`)
+        const response = await axios.patch(`/api/v1/admin/users/${userId}/toggle-freeze`, null, {
+          headers: { 'If-Match': await ifMatch(version, `/api/v1/admin/users/${userId}`) }
+        })
         return response.data
       } catch (error) {
         throw new Error(error.response?.data?.error || '操作失败')
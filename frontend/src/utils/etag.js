@@ -0,0 +1,15 @@
+import axios from 'axios'
+
+// 生成If-Match请求头。版本号未知时重新获取资源的ETag，不发送*跳过并发校验；
+// url为资源的管理接口地址，获取不到ETag时拒绝提交
+export const ifMatch = async (version, url) => {
+  if (version) {
+    return `"${version}"`
+  }
+  const response = await axios.get(url)
+  const etag = response.headers.etag
+  if (!etag) {
+    throw new Error('无法获取数据的当前版本，请刷新后重试')
+  }
+  return etag
+}